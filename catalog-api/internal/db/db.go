@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
 	_ "github.com/lib/pq"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -52,6 +53,10 @@ func Connect(dsn string) (*gorm.DB, error) {
 
 	log.Println("Connected to DB successfully")
 
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %v", err)
+	}
+
 	if os.Getenv("ENV") == "dev" {
 		db.AutoMigrate(&models.Category{}, &models.Course{})
 		log.Println("Seeding database...")
@@ -15,6 +15,9 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/alexandredsa/learning-rewards/observability/gqlext"
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
 	"github.com/gorilla/mux"
 )
 
@@ -48,6 +51,11 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
+	shutdownTracing, err := tracing.Init(context.Background(), "catalog-api")
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+
 	db, err := db.Connect(os.Getenv("DATABASE_DSN"))
 	if err != nil {
 		log.Fatalf("failed to open db: %v", err)
@@ -64,6 +72,8 @@ func main() {
 
 	// Enable introspection
 	srv.Use(extension.Introspection{})
+	srv.Use(gqlext.Metrics{})
+	srv.Use(gqlext.Tracing{})
 
 	// Create router
 	r := mux.NewRouter()
@@ -71,12 +81,15 @@ func main() {
 	// Add middleware
 	r.Use(loggingMiddleware)
 	r.Use(recoveryMiddleware)
+	r.Use(metrics.Middleware)
+	r.Use(tracing.Middleware)
 
 	// Setup routes
 	if os.Getenv("ENV") != "production" {
 		r.Handle("/", playground.Handler("GraphQL", "/query"))
 	}
 	r.Handle("/query", srv)
+	r.Handle("/metrics", metrics.Handler())
 
 	// Get port from environment or use default
 	port := os.Getenv("PORT")
@@ -114,5 +127,9 @@ func main() {
 		log.Fatalf("server forced to shutdown: %v", err)
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
+	}
+
 	log.Println("server exiting")
 }
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreAuthenticate(t *testing.T) {
+	store, err := NewMemoryStore(
+		Client{ID: "lms", KeySHA256: HashKey("lms-key"), EventTypes: []string{"COURSE_COMPLETED"}},
+		Client{ID: "old", KeySHA256: HashKey("old-key"), Disabled: true},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		wantID  string
+		wantErr error
+	}{
+		{name: "valid key", key: "lms-key", wantID: "lms"},
+		{name: "missing key", key: "", wantErr: ErrMissingKey},
+		{name: "unknown key", key: "guess", wantErr: ErrInvalidKey},
+		{name: "disabled client", key: "old-key", wantErr: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := store.Authenticate(context.Background(), tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && client.ID != tt.wantID {
+				t.Errorf("expected client %s, got %s", tt.wantID, client.ID)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreReplaceValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients []Client
+	}{
+		{name: "missing id", clients: []Client{{KeySHA256: HashKey("k")}}},
+		{name: "plaintext key", clients: []Client{{ID: "lms", KeySHA256: "lms-key"}}},
+		{name: "duplicate id", clients: []Client{
+			{ID: "lms", KeySHA256: HashKey("a")},
+			{ID: "lms", KeySHA256: HashKey("b")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMemoryStore(tt.clients...); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"clients": [{"id": "lms", "key_sha256": "` + HashKey("lms-key") + `"}]}`)
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A broken file keeps the previous keys
+	write(`{"clients": [`)
+	if err := store.Reload(); err == nil {
+		t.Error("expected reload of a broken file to fail")
+	}
+	if _, err := store.Authenticate(context.Background(), "lms-key"); err != nil {
+		t.Errorf("expected previous keys to be kept, got %v", err)
+	}
+
+	write(`{"clients": [{"id": "lms", "key_sha256": "` + HashKey("rotated") + `"}]}`)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Authenticate(context.Background(), "lms-key"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected rotated key to be revoked, got %v", err)
+	}
+}
+
+func TestClientAllows(t *testing.T) {
+	scoped := &Client{EventTypes: []string{"COURSE_COMPLETED", "CHAPTER_COMPLETED"}}
+	if !scoped.Allows("CHAPTER_COMPLETED") || scoped.Allows("QUIZ_PASSED") {
+		t.Errorf("unexpected scope check for %v", scoped.EventTypes)
+	}
+	for _, c := range []*Client{{}, {EventTypes: []string{"*"}}} {
+		if !c.Allows("QUIZ_PASSED") {
+			t.Errorf("expected %v to allow every type", c.EventTypes)
+		}
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(LimiterConfig{ClientRate: 1, ClientBurst: 3, UserRate: 1, UserBurst: 2})
+	limiter.now = func() time.Time { return now }
+	client := &Client{ID: "lms"}
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow(client, "user-1"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	var limited *RateLimitError
+	if err := limiter.Allow(client, "user-1"); !errors.As(err, &limited) || limited.Scope != "user" {
+		t.Fatalf("expected user rate limit, got %v", err)
+	}
+	if limited.RetryAfterSeconds() != 1 {
+		t.Errorf("expected Retry-After of 1s, got %v", limited.RetryAfter)
+	}
+
+	// The rejected request did not consume the client's last token
+	if err := limiter.Allow(client, "user-2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.Allow(client, "user-3"); !errors.As(err, &limited) || limited.Scope != "client" {
+		t.Fatalf("expected client rate limit, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if err := limiter.Allow(client, "user-1"); err != nil {
+		t.Errorf("expected buckets to refill, got %v", err)
+	}
+}
+
+func TestLimiterClientOverride(t *testing.T) {
+	limiter := NewLimiter(LimiterConfig{ClientRate: 1, ClientBurst: 1})
+	bulk := &Client{ID: "bulk", RatePerSecond: 100, Burst: 10}
+
+	for i := 0; i < 10; i++ {
+		if err := limiter.Allow(bulk, "user-1"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	if err := limiter.Allow(nil, "user-1"); err != nil {
+		t.Errorf("expected unauthenticated requests to skip the client limit, got %v", err)
+	}
+}
+
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	now := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(LimiterConfig{UserRate: 1, UserBurst: 1})
+	limiter.now = func() time.Time { return now }
+	limiter.lastSweep = now
+
+	for _, user := range []string{"user-1", "user-2"} {
+		if err := limiter.Allow(nil, user); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	now = now.Add(idleSweepInterval)
+	if err := limiter.Allow(nil, "user-3"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(limiter.users) != 1 {
+		t.Errorf("expected idle buckets to be dropped, got %d buckets", len(limiter.users))
+	}
+}
+
+func TestLimiterAllowBatch(t *testing.T) {
+	now := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(LimiterConfig{ClientRate: 1, ClientBurst: 3})
+	limiter.now = func() time.Time { return now }
+	client := &Client{ID: "lms"}
+
+	var limited *RateLimitError
+	if err := limiter.AllowBatch(client, []string{"user-1", "user-2", "user-3", "user-4"}); !errors.As(err, &limited) || limited.Scope != "client" {
+		t.Fatalf("expected client rate limit for a batch over the burst, got %v", err)
+	}
+	if limited.RetryAfterSeconds() != 3 {
+		t.Errorf("expected Retry-After of 3s, got %v", limited.RetryAfter)
+	}
+	if err := limiter.AllowBatch(client, []string{"user-1", "user-2", "user-3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := limiter.Allow(client, "user-1"); !errors.As(err, &limited) {
+		t.Fatalf("expected the batch to take every token, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitError is returned when a client or user exhausted its token bucket
+type RateLimitError struct {
+	// Scope is "client" or "user"
+	Scope string
+	// RetryAfter is the time until the next request would be accepted
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Scope, e.RetryAfter)
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, as used by
+// the Retry-After header
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// LimiterConfig holds the token bucket parameters. A zero rate disables the
+// corresponding limit.
+type LimiterConfig struct {
+	// ClientRate and ClientBurst apply to every API key that does not
+	// override them
+	ClientRate  float64
+	ClientBurst int
+	// UserRate and UserBurst apply to every user_id, whichever client
+	// submits its events
+	UserRate  float64
+	UserBurst int
+}
+
+// idleSweepInterval is how often buckets that refilled completely are
+// dropped. A full bucket behaves exactly like a new one, so dropping it
+// only reclaims memory.
+const idleSweepInterval = time.Minute
+
+// Limiter enforces token buckets per API client and per user
+type Limiter struct {
+	cfg LimiterConfig
+	now func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*rate.Limiter
+	users     map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+// NewLimiter creates a limiter
+func NewLimiter(cfg LimiterConfig) *Limiter {
+	return &Limiter{
+		cfg:       cfg,
+		now:       time.Now,
+		clients:   make(map[string]*rate.Limiter),
+		users:     make(map[string]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of the client and from the bucket of
+// the user. Tokens are only taken when both buckets have one, so a request
+// rejected for one scope does not count against the other. A nil client
+// skips the client limit.
+func (l *Limiter) Allow(client *Client, userID string) error {
+	return l.AllowBatch(client, []string{userID})
+}
+
+// AllowBatch takes the tokens of a batch of events, one per user ID, from the
+// bucket of the client and from the buckets of the users. Either every token
+// is taken or none is, so a refused batch does not count against any bucket.
+// A batch larger than a burst is always refused.
+func (l *Limiter) AllowBatch(client *Client, userIDs []string) error {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	var taken []*rate.Reservation
+	reserve := func(scope string, lim *rate.Limiter, n int) error {
+		r := lim.ReserveN(now, n)
+		if !r.OK() {
+			// More tokens than the burst: waiting for a full bucket is the
+			// best a client can do before splitting the batch
+			return l.refuse(now, taken, scope, time.Duration(float64(lim.Burst())/float64(lim.Limit())*float64(time.Second)))
+		}
+		if delay := r.DelayFrom(now); delay > 0 {
+			r.CancelAt(now)
+			return l.refuse(now, taken, scope, delay)
+		}
+		taken = append(taken, r)
+		return nil
+	}
+
+	if client != nil {
+		if lim := l.clientLimiter(client); lim != nil {
+			if err := reserve("client", lim, len(userIDs)); err != nil {
+				return err
+			}
+		}
+	}
+
+	perUser := make(map[string]int, len(userIDs))
+	var users []string
+	for _, userID := range userIDs {
+		if perUser[userID] == 0 {
+			users = append(users, userID)
+		}
+		perUser[userID]++
+	}
+	for _, userID := range users {
+		if lim := l.userLimiter(userID); lim != nil {
+			if err := reserve("user", lim, perUser[userID]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// refuse gives back the tokens taken for a refused request
+func (l *Limiter) refuse(now time.Time, taken []*rate.Reservation, scope string, retryAfter time.Duration) error {
+	for _, r := range taken {
+		r.CancelAt(now)
+	}
+	return &RateLimitError{Scope: scope, RetryAfter: retryAfter}
+}
+
+func (l *Limiter) clientLimiter(c *Client) *rate.Limiter {
+	r, burst := l.cfg.ClientRate, l.cfg.ClientBurst
+	if c.RatePerSecond > 0 {
+		r, burst = c.RatePerSecond, c.Burst
+	}
+	if r <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(r))
+	}
+
+	lim, ok := l.clients[c.ID]
+	if !ok || lim.Limit() != rate.Limit(r) || lim.Burst() != burst {
+		// Created on first use or after the client's limits were changed
+		lim = rate.NewLimiter(rate.Limit(r), burst)
+		l.clients[c.ID] = lim
+	}
+	return lim
+}
+
+func (l *Limiter) userLimiter(userID string) *rate.Limiter {
+	if l.cfg.UserRate <= 0 || userID == "" {
+		return nil
+	}
+	lim, ok := l.users[userID]
+	if !ok {
+		burst := l.cfg.UserBurst
+		if burst <= 0 {
+			burst = int(math.Ceil(l.cfg.UserRate))
+		}
+		lim = rate.NewLimiter(rate.Limit(l.cfg.UserRate), burst)
+		l.users[userID] = lim
+	}
+	return lim
+}
+
+// sweep drops full buckets so that the per-user map does not grow with every
+// user ever seen
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for _, buckets := range []map[string]*rate.Limiter{l.clients, l.users} {
+		for key, lim := range buckets {
+			if lim.TokensAt(now) >= float64(lim.Burst()) {
+				delete(buckets, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package auth authenticates API clients of the ingestion endpoints and
+// enforces their event type scopes and rate limits.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrMissingKey is returned when a request carries no API key
+	ErrMissingKey = errors.New("missing API key")
+	// ErrInvalidKey is returned for unknown or disabled API keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrForbiddenEventType is returned when a client submits an event type
+	// outside of its scope
+	ErrForbiddenEventType = errors.New("event type not allowed for this API key")
+	// ErrReadNotAllowed is returned when a client without read access queries
+	// the event audit log
+	ErrReadNotAllowed = errors.New("API key is not allowed to read events")
+)
+
+// Client is an API client allowed to submit events
+type Client struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// KeySHA256 is the hex-encoded SHA-256 digest of the API key. Keys
+	// themselves are never stored.
+	KeySHA256 string `json:"key_sha256"`
+	// EventTypes lists the event types the client may submit. An empty list
+	// or "*" allows every type.
+	EventTypes []string `json:"event_types,omitempty"`
+	// RatePerSecond and Burst override the default per-key token bucket
+	RatePerSecond float64 `json:"rate_per_second,omitempty"`
+	Burst         int     `json:"burst,omitempty"`
+	// ReadEvents grants access to the event audit log
+	ReadEvents bool `json:"read_events,omitempty"`
+	Disabled   bool `json:"disabled,omitempty"`
+}
+
+// Allows reports whether the client may submit events of the given type
+func (c *Client) Allows(eventType string) bool {
+	if len(c.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range c.EventTypes {
+		if t == "*" || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Store looks up API clients by key
+type Store interface {
+	Authenticate(ctx context.Context, key string) (*Client, error)
+}
+
+// HashKey returns the digest under which a key is stored
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore is a Store holding clients in memory. Its clients can be
+// replaced at runtime, e.g. when the keys file is reloaded.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	byKey map[string]*Client
+}
+
+// NewMemoryStore creates a store holding the given clients
+func NewMemoryStore(clients ...Client) (*MemoryStore, error) {
+	s := &MemoryStore{}
+	if err := s.Replace(clients); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Replace atomically replaces every client of the store
+func (s *MemoryStore) Replace(clients []Client) error {
+	byKey := make(map[string]*Client, len(clients))
+	ids := make(map[string]bool, len(clients))
+	for i := range clients {
+		c := clients[i]
+		if c.ID == "" {
+			return fmt.Errorf("client %d: id is required", i)
+		}
+		if ids[c.ID] {
+			return fmt.Errorf("client %s: duplicate id", c.ID)
+		}
+		if _, err := hex.DecodeString(c.KeySHA256); err != nil || len(c.KeySHA256) != sha256.Size*2 {
+			return fmt.Errorf("client %s: key_sha256 must be a hex-encoded SHA-256 digest", c.ID)
+		}
+		ids[c.ID] = true
+		byKey[c.KeySHA256] = &c
+	}
+
+	s.mu.Lock()
+	s.byKey = byKey
+	s.mu.Unlock()
+	return nil
+}
+
+// Authenticate returns the enabled client owning key
+func (s *MemoryStore) Authenticate(ctx context.Context, key string) (*Client, error) {
+	if key == "" {
+		return nil, ErrMissingKey
+	}
+
+	s.mu.RLock()
+	c, ok := s.byKey[HashKey(key)]
+	s.mu.RUnlock()
+	if !ok || c.Disabled {
+		return nil, ErrInvalidKey
+	}
+	return c, nil
+}
+
+// Len returns the number of clients in the store
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byKey)
+}
+
+// FileStore is a MemoryStore loaded from a JSON file of the form
+// {"clients": [...]}
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore loads the clients of a keys file
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: &MemoryStore{}, path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the keys file. The previous clients are kept on error.
+func (s *FileStore) Reload() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	var file struct {
+		Clients []Client `json:"clients"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid API keys file %s: %w", s.path, err)
+	}
+	if err := s.Replace(file.Clients); err != nil {
+		return fmt.Errorf("invalid API keys file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+type clientKey struct{}
+
+// WithClient returns a context carrying the authenticated client
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFrom returns the authenticated client of a context
+func ClientFrom(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(clientKey{}).(*Client)
+	return c, ok && c != nil
+}
@@ -0,0 +1,61 @@
+package caliper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Envelope is the Caliper transport wrapper sent by sensors
+type Envelope struct {
+	Sensor      string            `json:"sensor"`
+	SendTime    time.Time         `json:"sendTime"`
+	DataVersion string            `json:"dataVersion"`
+	Data        []json.RawMessage `json:"data"`
+}
+
+// Event is the subset of a Caliper event this service understands
+type Event struct {
+	Context    interface{}            `json:"@context,omitempty"`
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Action     string                 `json:"action"`
+	Actor      *Entity                `json:"actor"`
+	Object     *Entity                `json:"object"`
+	Group      *Entity                `json:"group,omitempty"`
+	EventTime  *time.Time             `json:"eventTime,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Entity is a Caliper entity. Entities may be serialized either as a full
+// object or as a bare IRI string referencing a previously described entity.
+type Entity struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type,omitempty"`
+	IsPartOf   *Entity                `json:"isPartOf,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// UnmarshalJSON accepts both the object and the IRI string forms
+func (e *Entity) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.ID)
+	}
+
+	type entity Entity
+	return json.Unmarshal(data, (*entity)(e))
+}
+
+// DecodeEnvelope decodes an envelope and checks that it carries data
+func DecodeEnvelope(body []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	if len(envelope.Data) == 0 {
+		return nil, errors.New("envelope has no data")
+	}
+	return &envelope, nil
+}
@@ -0,0 +1,197 @@
+package caliper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"event-processor/internal/iri"
+	"event-processor/internal/models"
+)
+
+// DefaultCategoryExtension is the extension read for the event category
+const DefaultCategoryExtension = "category"
+
+var (
+	// ErrInvalidEvent is returned when an event lacks required properties
+	ErrInvalidEvent = errors.New("invalid Caliper event")
+	// ErrUnmappedEvent is returned when no event type is configured for an
+	// event's type and action
+	ErrUnmappedEvent = errors.New("no event type mapped")
+)
+
+// MappingTable maps Caliper events to event types. A key is either
+// "<type>:<action>" or "<type>"; the former takes precedence.
+type MappingTable map[string]string
+
+// DefaultMappingTable returns the mapping used when none is configured
+func DefaultMappingTable() MappingTable {
+	return MappingTable{
+		"AssignableEvent:Completed":   "COURSE_COMPLETED",
+		"AssessmentEvent:Submitted":   "ASSESSMENT_SUBMITTED",
+		"GradeEvent:Graded":           "ASSESSMENT_GRADED",
+		"NavigationEvent:NavigatedTo": "CONTENT_VIEWED",
+	}
+}
+
+// ParseMappingTable parses a comma-separated list of
+// "<type>[:<action>]=EVENT_TYPE" pairs
+func ParseMappingTable(s string) (MappingTable, error) {
+	table := MappingTable{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, eventType, ok := strings.Cut(pair, "=")
+		key, eventType = strings.TrimSpace(key), strings.TrimSpace(eventType)
+		if !ok || key == "" || eventType == "" {
+			return nil, fmt.Errorf("invalid Caliper mapping %q, expected <type>[:<action>]=<event type>", pair)
+		}
+		table[key] = eventType
+	}
+	if len(table) == 0 {
+		return nil, errors.New("caliper mapping table is empty")
+	}
+	return table, nil
+}
+
+// Config configures how Caliper events are mapped to learning events
+type Config struct {
+	// Events maps Caliper event types and actions to event types
+	Events MappingTable
+	// ActorIDPrefix is stripped from the actor IRI to obtain the user ID.
+	// When empty the full IRI is used.
+	ActorIDPrefix string
+	// CourseIDPrefix is stripped from the course IRI to obtain the course ID.
+	// When empty, or when the IRI does not start with it, the last path
+	// segment is used instead.
+	CourseIDPrefix string
+	// CategoryExtension is the event (or object) extension holding the category
+	CategoryExtension string
+}
+
+// Mapper converts Caliper events into learning events
+type Mapper struct {
+	cfg Config
+}
+
+// NewMapper creates a new Caliper event mapper
+func NewMapper(cfg Config) *Mapper {
+	if cfg.Events == nil {
+		cfg.Events = DefaultMappingTable()
+	}
+	if cfg.CategoryExtension == "" {
+		cfg.CategoryExtension = DefaultCategoryExtension
+	}
+	return &Mapper{cfg: cfg}
+}
+
+// Map converts an event into a learning event. The course is taken from the
+// object's parent when present, otherwise from the event's group, which is
+// often a course section, otherwise from the object itself.
+func (m *Mapper) Map(event Event) (models.LearningEvent, error) {
+	if event.Type == "" {
+		return models.LearningEvent{}, fmt.Errorf("%w: type is required", ErrInvalidEvent)
+	}
+	if event.Actor == nil || event.Actor.ID == "" {
+		return models.LearningEvent{}, fmt.Errorf("%w: actor.id is required", ErrInvalidEvent)
+	}
+	if event.Object == nil || event.Object.ID == "" {
+		return models.LearningEvent{}, fmt.Errorf("%w: object.id is required", ErrInvalidEvent)
+	}
+
+	eventType, ok := m.cfg.Events[event.Type+":"+event.Action]
+	if !ok {
+		eventType, ok = m.cfg.Events[event.Type]
+	}
+	if !ok {
+		return models.LearningEvent{}, fmt.Errorf("%w for %s:%s", ErrUnmappedEvent, event.Type, event.Action)
+	}
+
+	course := event.Object
+	switch {
+	case event.Object.IsPartOf != nil && event.Object.IsPartOf.ID != "":
+		course = event.Object.IsPartOf
+	case event.Group != nil && event.Group.ID != "":
+		course = event.Group
+	}
+
+	timestamp := time.Now().UTC()
+	if event.EventTime != nil {
+		timestamp = *event.EventTime
+	}
+
+	return models.LearningEvent{
+		UserID:    m.userID(event.Actor.ID),
+		EventType: eventType,
+		CourseID:  iri.ID(course.ID, m.cfg.CourseIDPrefix),
+		Category:  m.category(event),
+		Timestamp: timestamp,
+	}, nil
+}
+
+func (m *Mapper) userID(iri string) string {
+	if m.cfg.ActorIDPrefix != "" {
+		return strings.TrimPrefix(iri, m.cfg.ActorIDPrefix)
+	}
+	return iri
+}
+
+func (m *Mapper) category(event Event) string {
+	if category, ok := event.Extensions[m.cfg.CategoryExtension].(string); ok {
+		return category
+	}
+	if category, ok := event.Object.Extensions[m.cfg.CategoryExtension].(string); ok {
+		return category
+	}
+	return ""
+}
+
+// Rejection describes an envelope entry that was not published
+type Rejection struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Action string `json:"action,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// MapEnvelope maps every entry of an envelope. Entries that cannot be decoded,
+// are invalid or have no mapping are returned as rejections instead of failing
+// the whole envelope. Entity descriptions (entries without an action) are
+// skipped silently since they carry no learning activity.
+func (m *Mapper) MapEnvelope(envelope *Envelope) ([]models.LearningEvent, []Rejection) {
+	var (
+		events     []models.LearningEvent
+		rejections []Rejection
+	)
+
+	for i, raw := range envelope.Data {
+		var event Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			rejections = append(rejections, Rejection{Index: i, Reason: err.Error()})
+			continue
+		}
+		if event.Action == "" && !strings.HasSuffix(event.Type, "Event") {
+			continue
+		}
+
+		mapped, err := m.Map(event)
+		if err != nil {
+			rejections = append(rejections, Rejection{
+				Index:  i,
+				ID:     event.ID,
+				Type:   event.Type,
+				Action: event.Action,
+				Reason: err.Error(),
+			})
+			continue
+		}
+		events = append(events, mapped)
+	}
+
+	return events, rejections
+}
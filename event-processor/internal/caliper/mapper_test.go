@@ -0,0 +1,143 @@
+package caliper
+
+import (
+	"errors"
+	"testing"
+)
+
+const envelopeJSON = `{
+  "sensor": "https://lms.example.edu/sensors/1",
+  "sendTime": "2025-06-03T14:00:05Z",
+  "dataVersion": "http://purl.imsglobal.org/ctx/caliper/v1p2",
+  "data": [
+    {
+      "id": "urn:uuid:1",
+      "type": "AssessmentEvent",
+      "action": "Submitted",
+      "actor": {"id": "https://lms.example.edu/users/554433", "type": "Person"},
+      "object": {
+        "id": "https://lms.example.edu/terms/201801/courses/7/sections/1/assess/1",
+        "type": "Assessment",
+        "isPartOf": {"id": "https://lms.example.edu/terms/201801/courses/7", "type": "CourseOffering"}
+      },
+      "eventTime": "2025-06-03T14:00:00Z",
+      "extensions": {"category": "MATH"}
+    },
+    {
+      "id": "urn:uuid:2",
+      "type": "NavigationEvent",
+      "action": "NavigatedTo",
+      "actor": "https://lms.example.edu/users/554433",
+      "object": {
+        "id": "https://lms.example.edu/terms/201801/courses/7/pages/2",
+        "type": "WebPage",
+        "isPartOf": {"id": "https://lms.example.edu/terms/201801/courses/7", "type": "CourseOffering"}
+      },
+      "group": {"id": "https://lms.example.edu/terms/201801/courses/7/sections/1", "type": "CourseSection"}
+    },
+    {
+      "id": "urn:uuid:3",
+      "type": "ToolUseEvent",
+      "action": "Used",
+      "actor": "https://lms.example.edu/users/554433",
+      "object": "https://lms.example.edu/tools/1"
+    },
+    {
+      "id": "https://lms.example.edu/users/554433",
+      "type": "Person"
+    },
+    {
+      "id": "urn:uuid:4",
+      "type": "AssessmentEvent",
+      "action": "Submitted",
+      "object": "https://lms.example.edu/assess/2"
+    }
+  ]
+}`
+
+func TestMapEnvelope(t *testing.T) {
+	envelope, err := DecodeEnvelope([]byte(envelopeJSON))
+	if err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+
+	mapper := NewMapper(Config{ActorIDPrefix: "https://lms.example.edu/users/"})
+	events, rejections := mapper.MapEnvelope(envelope)
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 mapped events, got %d: %+v", len(events), events)
+	}
+
+	submitted := events[0]
+	if submitted.UserID != "554433" || submitted.EventType != "ASSESSMENT_SUBMITTED" ||
+		submitted.CourseID != "7" || submitted.Category != "MATH" {
+		t.Errorf("unexpected assessment event: %+v", submitted)
+	}
+
+	navigated := events[1]
+	if navigated.EventType != "CONTENT_VIEWED" || navigated.CourseID != "7" {
+		t.Errorf("unexpected navigation event: %+v", navigated)
+	}
+
+	if len(rejections) != 2 {
+		t.Fatalf("expected 2 rejections, got %d: %+v", len(rejections), rejections)
+	}
+	if rejections[0].Index != 2 || rejections[0].Type != "ToolUseEvent" || rejections[0].Action != "Used" {
+		t.Errorf("unexpected unmapped rejection: %+v", rejections[0])
+	}
+	if rejections[1].Index != 4 || rejections[1].ID != "urn:uuid:4" {
+		t.Errorf("unexpected invalid rejection: %+v", rejections[1])
+	}
+}
+
+func TestMapTypeOnlyMapping(t *testing.T) {
+	mapper := NewMapper(Config{Events: MappingTable{"ToolUseEvent": "TOOL_USED"}})
+	event, err := mapper.Map(Event{
+		Type:   "ToolUseEvent",
+		Action: "Used",
+		Actor:  &Entity{ID: "user-1"},
+		Object: &Entity{ID: "https://lms.example.edu/tools/1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "TOOL_USED" {
+		t.Errorf("expected TOOL_USED, got %s", event.EventType)
+	}
+
+	_, err = mapper.Map(Event{Type: "GradeEvent", Action: "Graded", Actor: &Entity{ID: "u"}, Object: &Entity{ID: "o"}})
+	if !errors.Is(err, ErrUnmappedEvent) {
+		t.Errorf("expected ErrUnmappedEvent, got %v", err)
+	}
+}
+
+func TestMapCourseFallsBackToGroup(t *testing.T) {
+	mapper := NewMapper(Config{})
+	event, err := mapper.Map(Event{
+		Type:   "NavigationEvent",
+		Action: "NavigatedTo",
+		Actor:  &Entity{ID: "user-1"},
+		Object: &Entity{ID: "https://lms.example.edu/pages/2"},
+		Group:  &Entity{ID: "https://lms.example.edu/terms/201801/courses/7/sections/1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.CourseID != "1" {
+		t.Errorf("expected the group as course, got %s", event.CourseID)
+	}
+}
+
+func TestParseMappingTable(t *testing.T) {
+	table, err := ParseMappingTable("AssessmentEvent:Submitted=QUIZ_SUBMITTED, NavigationEvent=PAGE_VIEWED")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if table["AssessmentEvent:Submitted"] != "QUIZ_SUBMITTED" || table["NavigationEvent"] != "PAGE_VIEWED" {
+		t.Errorf("unexpected table: %v", table)
+	}
+
+	if _, err := ParseMappingTable("AssessmentEvent:Submitted"); err == nil {
+		t.Error("expected error for missing event type")
+	}
+}
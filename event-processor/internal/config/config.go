@@ -1,19 +1,30 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"time"
 )
 
 type Config struct {
 	DatabaseURL string
 	Port        string
+	// EventRetention is how long events are kept in the audit log; 0 keeps
+	// them forever
+	EventRetention time.Duration
 }
 
-func Load() *Config {
-	return &Config{
-		DatabaseURL: getEnv("DATABASE_URL", "postgres://localhost:5433/events?sslmode=disable"),
-		Port:        getEnv("PORT", "8080"),
+func Load() (*Config, error) {
+	retention, err := time.ParseDuration(getEnv("EVENT_RETENTION", "2160h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_RETENTION: %w", err)
 	}
+
+	return &Config{
+		DatabaseURL:    getEnv("DATABASE_URL", "postgres://localhost:5433/events?sslmode=disable"),
+		Port:           getEnv("PORT", "8080"),
+		EventRetention: retention,
+	}, nil
 }
 
 func getEnv(key, fallback string) string {
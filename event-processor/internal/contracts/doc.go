@@ -0,0 +1,4 @@
+// Package contracts holds the Go types of the Avro schemas exchanged over
+// Kafka. contracts.go is generated from the top-level schemas directory by
+// schemas/generate.sh; do not edit it by hand.
+package contracts
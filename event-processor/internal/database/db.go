@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"event-processor/internal/logger"
+	"event-processor/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const (
+	maxRetries = 5
+	baseDelay  = time.Second
+)
+
+// ErrMissingDSN is returned when no database connection string is provided
+var ErrMissingDSN = errors.New("database connection string (DSN) is required")
+
+func connectWithRetry(dsn string) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+	delay := baseDelay
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+			Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+		})
+		if err == nil {
+			return db, nil
+		}
+
+		if attempt == maxRetries {
+			return nil, fmt.Errorf("failed to connect after %d attempts: %v", maxRetries, err)
+		}
+
+		logger.Get().Warn("database connection attempt failed",
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay),
+			zap.Error(err))
+		time.Sleep(delay)
+		delay *= 2 // exponential backoff
+	}
+
+	return nil, fmt.Errorf("unexpected error in retry logic")
+}
+
+// Connect creates a new database connection and runs auto-migrations
+func Connect(dsn string) (*gorm.DB, error) {
+	if dsn == "" {
+		return nil, ErrMissingDSN
+	}
+
+	db, err := connectWithRetry(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Get().Info("connected to database")
+
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %w", err)
+	}
+
+	// Auto-migrate the schema
+	if err := db.AutoMigrate(&models.EventRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to auto-migrate database: %w", err)
+	}
+
+	return db, nil
+}
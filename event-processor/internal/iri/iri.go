@@ -0,0 +1,18 @@
+// Package iri derives identifiers from the IRIs of learning standards such as
+// xAPI and Caliper.
+package iri
+
+import "strings"
+
+// ID returns the identifier named by iri: iri without prefix when it starts
+// with it, or its last path segment otherwise. An empty prefix is ignored.
+func ID(iri, prefix string) string {
+	if prefix != "" && strings.HasPrefix(iri, prefix) {
+		return strings.Trim(strings.TrimPrefix(iri, prefix), "/")
+	}
+	trimmed := strings.TrimRight(iri, "/")
+	if i := strings.LastIndexAny(trimmed, "/:"); i >= 0 && i < len(trimmed)-1 {
+		return trimmed[i+1:]
+	}
+	return iri
+}
@@ -0,0 +1,28 @@
+package iri
+
+import "testing"
+
+func TestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		iri      string
+		prefix   string
+		expected string
+	}{
+		{name: "last segment", iri: "https://lms.example.com/courses/course-xyz", expected: "course-xyz"},
+		{name: "trailing slash", iri: "https://lms.example.com/courses/course-xyz/", expected: "course-xyz"},
+		{name: "URN", iri: "urn:course:algebra-1", expected: "algebra-1"},
+		{name: "no separator", iri: "algebra-1", expected: "algebra-1"},
+		{name: "prefix", iri: "https://lms.example.com/courses/math/algebra-1", prefix: "https://lms.example.com/courses/", expected: "math/algebra-1"},
+		{name: "prefix without trailing slash", iri: "https://lms.example.com/courses/math/algebra-1/", prefix: "https://lms.example.com/courses", expected: "math/algebra-1"},
+		{name: "other prefix", iri: "https://other.example.com/c/algebra-1", prefix: "https://lms.example.com/courses/", expected: "algebra-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ID(tt.iri, tt.prefix); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// CloudEventsMode selects how messages are wrapped in the CloudEvents Kafka
+// protocol binding
+type CloudEventsMode string
+
+const (
+	// CloudEventsDisabled publishes the bare JSON payload without metadata
+	CloudEventsDisabled CloudEventsMode = "none"
+	// CloudEventsBinary keeps the payload as message value and carries the
+	// event attributes in ce_* headers. Consumers unaware of CloudEvents
+	// still see the bare payload, which makes it the safest migration mode.
+	CloudEventsBinary CloudEventsMode = "binary"
+	// CloudEventsStructured wraps attributes and payload in a single
+	// application/cloudevents+json document
+	CloudEventsStructured CloudEventsMode = "structured"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json; charset=UTF-8"
+	jsonContentType        = "application/json"
+)
+
+// LearningEventType is the CloudEvents type of learning events
+const LearningEventType = "dev.learning-rewards.learning-event.v1"
+
+// ParseCloudEventsMode parses a configured mode, defaulting to binary
+func ParseCloudEventsMode(s string) (CloudEventsMode, error) {
+	switch mode := CloudEventsMode(s); mode {
+	case "":
+		return CloudEventsBinary, nil
+	case CloudEventsDisabled, CloudEventsBinary, CloudEventsStructured:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown CloudEvents mode %q, expected one of none, binary, structured", s)
+	}
+}
+
+// CloudEvents holds the attributes stamped on every published message
+type CloudEvents struct {
+	Mode   CloudEventsMode
+	Source string
+	Type   string
+}
+
+// payload is an encoded message body along with its content type and the
+// URI of the schema it conforms to
+type payload struct {
+	data        []byte
+	contentType string
+	dataSchema  string
+}
+
+// structuredEvent is the JSON event format used by the structured mode
+type structuredEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// encode sets the value and headers of msg according to the configured mode.
+// An empty id is replaced by a random one. Non-JSON payloads are carried as
+// data_base64 in structured mode.
+func (c CloudEvents) encode(msg *sarama.ProducerMessage, body payload, id, subject string) error {
+	if c.Mode == "" || c.Mode == CloudEventsDisabled {
+		msg.Value = sarama.ByteEncoder(body.data)
+		return nil
+	}
+
+	if id == "" {
+		id = uuid.New().String()
+	}
+	now := time.Now().UTC()
+
+	switch c.Mode {
+	case CloudEventsBinary:
+		headers := []sarama.RecordHeader{
+			{Key: []byte("ce_specversion"), Value: []byte(cloudEventsSpecVersion)},
+			{Key: []byte("ce_id"), Value: []byte(id)},
+			{Key: []byte("ce_source"), Value: []byte(c.Source)},
+			{Key: []byte("ce_type"), Value: []byte(c.Type)},
+			{Key: []byte("ce_time"), Value: []byte(now.Format(time.RFC3339Nano))},
+			{Key: []byte("content-type"), Value: []byte(body.contentType)},
+		}
+		if subject != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte("ce_subject"), Value: []byte(subject)})
+		}
+		if body.dataSchema != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte("ce_dataschema"), Value: []byte(body.dataSchema)})
+		}
+		msg.Headers = append(msg.Headers, headers...)
+		msg.Value = sarama.ByteEncoder(body.data)
+
+	case CloudEventsStructured:
+		event := structuredEvent{
+			SpecVersion:     cloudEventsSpecVersion,
+			ID:              id,
+			Source:          c.Source,
+			Type:            c.Type,
+			Subject:         subject,
+			Time:            now,
+			DataContentType: body.contentType,
+			DataSchema:      body.dataSchema,
+		}
+		if body.contentType == jsonContentType {
+			event.Data = body.data
+		} else {
+			event.DataBase64 = body.data
+		}
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal CloudEvent: %w", err)
+		}
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{
+			Key:   []byte("content-type"),
+			Value: []byte(cloudEventsContentType),
+		})
+		msg.Value = sarama.ByteEncoder(value)
+
+	default:
+		return fmt.Errorf("unknown CloudEvents mode %q", c.Mode)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// KeyExtractor derives the message key for an event. Messages with the same
+// key are routed to the same partition, which preserves their relative order.
+// An empty key lets the partitioner pick a partition freely.
+type KeyExtractor func(event interface{}) string
+
+// NoKey publishes every message without a key
+func NoKey(event interface{}) string {
+	return ""
+}
+
+// KeyByField returns a KeyExtractor that uses the value of the field whose
+// JSON name matches field. The field must be a string or a fmt.Stringer such
+// as uuid.UUID. It works for structs (and pointers to them) as well as
+// map[string]interface{} payloads.
+func KeyByField(field string) KeyExtractor {
+	return func(event interface{}) string {
+		v := reflect.ValueOf(event)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			for i := 0; i < t.NumField(); i++ {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				if name == field && t.Field(i).IsExported() {
+					return stringValue(v.Field(i))
+				}
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return ""
+			}
+			if value := v.MapIndex(reflect.ValueOf(field)); value.IsValid() {
+				return stringValue(value)
+			}
+		}
+		return ""
+	}
+}
+
+func stringValue(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case string:
+		return value
+	case fmt.Stringer:
+		return value.String()
+	}
+	return ""
+}
+
+// KeyExtractorFor returns the KeyExtractor for a configured key name. The
+// value "none" disables keying; anything else is treated as a JSON field name.
+func KeyExtractorFor(name string) KeyExtractor {
+	if name == "" || name == "none" {
+		return NoKey
+	}
+	return KeyByField(name)
+}
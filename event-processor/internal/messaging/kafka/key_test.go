@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyByField(t *testing.T) {
+	type event struct {
+		ID       uuid.UUID `json:"id"`
+		UserID   string    `json:"user_id"`
+		CourseID string    `json:"course_id,omitempty"`
+		Count    int       `json:"count"`
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		event interface{}
+		want  string
+	}{
+		{name: "struct field", field: "user_id", event: event{UserID: "user-1"}, want: "user-1"},
+		{name: "pointer to struct", field: "course_id", event: &event{CourseID: "course-1"}, want: "course-1"},
+		{name: "stringer field", field: "id", event: event{ID: uuid.MustParse("5f0c1a5e-8a0e-4a55-9a52-2d0cde6f4a11")}, want: "5f0c1a5e-8a0e-4a55-9a52-2d0cde6f4a11"},
+		{name: "non-string field", field: "count", event: event{Count: 3}, want: ""},
+		{name: "unknown field", field: "category", event: event{UserID: "user-1"}, want: ""},
+		{name: "map payload", field: "user_id", event: map[string]interface{}{"user_id": "user-2"}, want: "user-2"},
+		{name: "nil pointer", field: "user_id", event: (*event)(nil), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyByField(tt.field)(tt.event); got != tt.want {
+				t.Errorf("KeyByField(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyExtractorForNone(t *testing.T) {
+	if got := KeyExtractorFor("none")(map[string]interface{}{"user_id": "user-1"}); got != "" {
+		t.Errorf("expected no key, got %q", got)
+	}
+}
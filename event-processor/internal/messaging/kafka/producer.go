@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"event-processor/internal/logger"
+	"event-processor/internal/metrics"
+	"event-processor/internal/schema"
 	"fmt"
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -23,6 +28,11 @@ type Producer struct {
 	logger   *zap.Logger
 	config   *sarama.Config
 	brokers  []string
+	keyFn    KeyExtractor
+	ce       CloudEvents
+	format   schema.Format
+	registry *schema.Registry
+	subject  string
 }
 
 // NewProducer creates a new Kafka producer
@@ -38,13 +48,19 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 	config.Producer.Return.Errors = true
 	config.Producer.Retry.Max = maxRetries
 	config.Producer.Retry.Backoff = retryDelay
+	// Keyed messages are hashed so that all events of a user share a partition
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Net.DialTimeout = 10 * time.Second
 	config.Net.ReadTimeout = 10 * time.Second
 	config.Net.WriteTimeout = 10 * time.Second
 
+	registry, err := schema.Default()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema registry: %w", err)
+	}
+
 	// Try to connect with retries
 	var producer sarama.SyncProducer
-	var err error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		log.Info("attempting to connect to Kafka brokers",
 			zap.Int("attempt", attempt),
@@ -79,9 +95,27 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 		logger:   log,
 		config:   config,
 		brokers:  brokers,
+		keyFn:    KeyByField("user_id"),
+		ce: CloudEvents{
+			Mode:   CloudEventsBinary,
+			Source: "/event-processor",
+			Type:   LearningEventType,
+		},
+		format:   schema.FormatJSON,
+		registry: registry,
+		subject:  topic + "-value",
 	}, nil
 }
 
+// SetKeyExtractor sets how message keys are derived from events.
+// By default messages are keyed by the event's user_id.
+func (p *Producer) SetKeyExtractor(keyFn KeyExtractor) {
+	if keyFn == nil {
+		keyFn = NoKey
+	}
+	p.keyFn = keyFn
+}
+
 // ensureConnection checks if the producer is still connected and reconnects if necessary
 func (p *Producer) ensureConnection() error {
 	// If producer is nil, we need to create a new one
@@ -120,36 +154,108 @@ func (p *Producer) reconnect() error {
 	return fmt.Errorf("failed to connect to Kafka after %d attempts: %w", maxRetries, err)
 }
 
-// PublishEvent publishes an event to Kafka
-func (p *Producer) PublishEvent(ctx context.Context, event interface{}) error {
+// SetCloudEvents sets the CloudEvents binding used for published messages.
+// By default messages use the binary mode.
+func (p *Producer) SetCloudEvents(ce CloudEvents) {
+	p.ce = ce
+}
+
+// SetFormat sets the payload format. Avro payloads are written with the
+// latest schema registered for the topic's <topic>-value subject.
+func (p *Producer) SetFormat(format schema.Format) error {
+	if format == schema.FormatAvro {
+		if _, err := p.registry.Latest(p.subject); err != nil {
+			return fmt.Errorf("cannot publish Avro to topic %s: %w", p.topic, err)
+		}
+	}
+	p.format = format
+	return nil
+}
+
+// encodePayload encodes an event in the configured format. JSON payloads
+// reference the latest schema of the subject when one is registered.
+func (p *Producer) encodePayload(event interface{}) (payload, error) {
+	if p.format == schema.FormatAvro {
+		data, version, err := p.registry.Marshal(p.subject, event)
+		if err != nil {
+			return payload{}, err
+		}
+		return payload{data: data, contentType: schema.AvroContentType, dataSchema: version.DataSchema()}, nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return payload{}, err
+	}
+	body := payload{data: data, contentType: jsonContentType}
+	if latest, err := p.registry.Latest(p.subject); err == nil {
+		body.dataSchema = latest.DataSchema()
+	}
+	return body, nil
+}
+
+// PublishEvent publishes an event to Kafka, in a producer span whose context
+// is propagated in the message headers
+func (p *Producer) PublishEvent(ctx context.Context, event interface{}) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, p.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.topic),
+			attribute.String("messaging.message.id", KeyByField("id")(event)),
+		))
+	defer func() { tracing.End(span, err) }()
+
 	// Ensure we have a connection before trying to publish
 	if err := p.ensureConnection(); err != nil {
 		return err
 	}
 
-	// Convert event to JSON
-	eventJSON, err := json.Marshal(event)
+	// Encode the event in the configured format
+	body, err := p.encodePayload(event)
 	if err != nil {
-		p.logger.Error("failed to marshal event to JSON", zap.Error(err))
-		return fmt.Errorf("failed to marshal event: %w", err)
+		p.logger.Error("failed to encode event", zap.String("format", string(p.format)), zap.Error(err))
+		return fmt.Errorf("failed to encode event: %w", err)
 	}
 
 	// Create Kafka message
 	msg := &sarama.ProducerMessage{
 		Topic: p.topic,
-		Value: sarama.StringEncoder(eventJSON),
 	}
+	key := p.keyFn(event)
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	if err := p.ce.encode(msg, body, KeyByField("id")(event), KeyByField("user_id")(event)); err != nil {
+		p.logger.Error("failed to encode CloudEvent", zap.Error(err))
+		return err
+	}
+	tracing.Inject(ctx, messageHeaders{msg})
 
 	p.logger.Debug("publishing event to Kafka",
 		zap.String("topic", p.topic),
-		zap.ByteString("event", eventJSON),
+		zap.String("key", key),
+		zap.String("cloudevents_mode", string(p.ce.Mode)),
+		zap.String("format", string(p.format)),
+		zap.String("dataschema", body.dataSchema),
+		zap.Any("event", event),
 	)
 
+	start := time.Now()
+	defer func() {
+		metrics.KafkaPublishDuration.WithLabelValues(p.topic).Observe(time.Since(start).Seconds())
+	}()
+
 	// Send message to Kafka with retries
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		partition, offset, err := p.producer.SendMessage(msg)
 		if err == nil {
+			span.SetAttributes(
+				attribute.Int("messaging.destination.partition.id", int(partition)),
+				attribute.Int64("messaging.kafka.offset", offset),
+			)
+			metrics.KafkaPublished.WithLabelValues(p.topic, "ok").Inc()
 			p.logger.Info("event published successfully",
 				zap.Int32("partition", partition),
 				zap.Int64("offset", offset),
@@ -177,6 +283,7 @@ func (p *Producer) PublishEvent(ctx context.Context, event interface{}) error {
 		}
 	}
 
+	metrics.KafkaPublished.WithLabelValues(p.topic, "error").Inc()
 	p.logger.Error("failed to publish event after all retries",
 		zap.Error(lastErr),
 		zap.String("topic", p.topic),
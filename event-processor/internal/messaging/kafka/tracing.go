@@ -0,0 +1,40 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// messageHeaders writes the trace context of a message to publish
+type messageHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+var _ propagation.TextMapCarrier = messageHeaders{}
+
+func (h messageHeaders) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (h messageHeaders) Set(key, value string) {
+	for i, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (h messageHeaders) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, header := range h.msg.Headers {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
@@ -0,0 +1,37 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMessageHeadersCarryTraceContext(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	msg := &sarama.ProducerMessage{
+		Headers: []sarama.RecordHeader{{Key: []byte("ce_id"), Value: []byte("event-1")}},
+	}
+
+	propagator := propagation.TraceContext{}
+	propagator.Inject(trace.ContextWithSpanContext(context.Background(), sc), messageHeaders{msg})
+
+	if got := len(msg.Headers); got != 2 {
+		t.Fatalf("got %d headers, want the CloudEvents one and traceparent", got)
+	}
+	want := "00-01000000000000000000000000000000-0200000000000000-01"
+	if got := (messageHeaders{msg}).Get("traceparent"); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+
+	extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), messageHeaders{msg}))
+	if extracted.TraceID() != sc.TraceID() || extracted.SpanID() != sc.SpanID() {
+		t.Errorf("extracted %v, want %v", extracted, sc)
+	}
+}
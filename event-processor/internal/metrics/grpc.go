@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor records the rate and latency of unary gRPC calls
+func UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeGRPC(info.FullMethod, start, err)
+	return resp, err
+}
+
+// StreamInterceptor records the rate and duration of streaming gRPC calls
+func StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeGRPC(info.FullMethod, start, err)
+	return err
+}
+
+func observeGRPC(method string, start time.Time, err error) {
+	GRPCRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	GRPCRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
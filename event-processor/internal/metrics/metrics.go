@@ -0,0 +1,38 @@
+// Package metrics defines the Prometheus metrics of the event processor. The
+// HTTP and database metrics common to the services are defined in the
+// observability module.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// GRPCRequests counts gRPC calls by method and status code
+	GRPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_requests_total",
+		Help: "gRPC calls by full method name and status code.",
+	}, []string{"method", "code"})
+
+	// GRPCRequestDuration observes gRPC call latencies
+	GRPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_request_duration_seconds",
+		Help:    "gRPC call latencies by full method name.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// KafkaPublished counts published messages by topic and status
+	KafkaPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_published_total",
+		Help: "Published Kafka messages by topic and status (ok or error).",
+	}, []string{"topic", "status"})
+
+	// KafkaPublishDuration observes the time to publish a message, retries
+	// included
+	KafkaPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_publish_duration_seconds",
+		Help:    "Time to publish a Kafka message, retries included, by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+)
@@ -1,17 +1,8 @@
 package models
 
-import (
-	"time"
+import "event-processor/internal/contracts"
 
-	"github.com/google/uuid"
-)
-
-type LearningEvent struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    string    `json:"user_id"`
-	EventType string    `json:"event_type"`
-	Category  string    `json:"category"`
-	CourseID  string    `json:"course_id"`
-	Timestamp time.Time `json:"timestamp"`
-	CreatedAt time.Time `json:"created_at"`
-}
+// LearningEvent is the event published to the learning-events topic. It is
+// generated from the learning-events-value schema shared with the reward
+// processor; see schemas/ at the repository root.
+type LearningEvent = contracts.LearningEvent
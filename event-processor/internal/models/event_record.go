@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// EventRecord is the audit log row of an accepted learning event
+type EventRecord struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid"`
+	UserID    string    `json:"user_id" gorm:"not null;index:idx_learning_events_user_time,priority:1"`
+	EventType string    `json:"event_type" gorm:"not null;index:idx_learning_events_type_time,priority:1"`
+	Category  string    `json:"category"`
+	CourseID  string    `json:"course_id"`
+	ClientID  string    `json:"client_id" gorm:"index"`
+	Timestamp time.Time `json:"timestamp" gorm:"not null;index:idx_learning_events_user_time,priority:2;index:idx_learning_events_type_time,priority:2;index"`
+	// ReceivedAt is when event-processor accepted the event; retention is
+	// based on it
+	ReceivedAt time.Time `json:"received_at" gorm:"not null;index"`
+}
+
+// TableName overrides the default table name
+func (EventRecord) TableName() string {
+	return "learning_events"
+}
+
+// NewEventRecord returns the audit log row of an event
+func NewEventRecord(event LearningEvent) EventRecord {
+	return EventRecord{
+		ID:         event.ID,
+		UserID:     event.UserID,
+		EventType:  event.EventType,
+		Category:   event.Category,
+		CourseID:   event.CourseID,
+		ClientID:   event.ClientID,
+		Timestamp:  event.Timestamp,
+		ReceivedAt: event.CreatedAt,
+	}
+}
@@ -0,0 +1,352 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: learningrewards/events/v1/events.proto
+
+package eventsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// LearningEvent mirrors the body of POST /events
+type LearningEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	EventType     string                 `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	CourseId      string                 `protobuf:"bytes,3,opt,name=course_id,json=courseId,proto3" json:"course_id,omitempty"`
+	Category      string                 `protobuf:"bytes,4,opt,name=category,proto3" json:"category,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LearningEvent) Reset() {
+	*x = LearningEvent{}
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LearningEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LearningEvent) ProtoMessage() {}
+
+func (x *LearningEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LearningEvent.ProtoReflect.Descriptor instead.
+func (*LearningEvent) Descriptor() ([]byte, []int) {
+	return file_learningrewards_events_v1_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LearningEvent) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *LearningEvent) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *LearningEvent) GetCourseId() string {
+	if x != nil {
+		return x.CourseId
+	}
+	return ""
+}
+
+func (x *LearningEvent) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *LearningEvent) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type PublishEventRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *LearningEvent         `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishEventRequest) Reset() {
+	*x = PublishEventRequest{}
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishEventRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishEventRequest) ProtoMessage() {}
+
+func (x *PublishEventRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishEventRequest.ProtoReflect.Descriptor instead.
+func (*PublishEventRequest) Descriptor() ([]byte, []int) {
+	return file_learningrewards_events_v1_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PublishEventRequest) GetEvent() *LearningEvent {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+type PublishEventResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishEventResponse) Reset() {
+	*x = PublishEventResponse{}
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishEventResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishEventResponse) ProtoMessage() {}
+
+func (x *PublishEventResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishEventResponse.ProtoReflect.Descriptor instead.
+func (*PublishEventResponse) Descriptor() ([]byte, []int) {
+	return file_learningrewards_events_v1_events_proto_rawDescGZIP(), []int{2}
+}
+
+type PublishEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *LearningEvent         `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishEventsRequest) Reset() {
+	*x = PublishEventsRequest{}
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishEventsRequest) ProtoMessage() {}
+
+func (x *PublishEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishEventsRequest.ProtoReflect.Descriptor instead.
+func (*PublishEventsRequest) Descriptor() ([]byte, []int) {
+	return file_learningrewards_events_v1_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PublishEventsRequest) GetEvent() *LearningEvent {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+type PublishEventsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Number of events published
+	Accepted      int32 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishEventsResponse) Reset() {
+	*x = PublishEventsResponse{}
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishEventsResponse) ProtoMessage() {}
+
+func (x *PublishEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_learningrewards_events_v1_events_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishEventsResponse.ProtoReflect.Descriptor instead.
+func (*PublishEventsResponse) Descriptor() ([]byte, []int) {
+	return file_learningrewards_events_v1_events_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PublishEventsResponse) GetAccepted() int32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+var File_learningrewards_events_v1_events_proto protoreflect.FileDescriptor
+
+const file_learningrewards_events_v1_events_proto_rawDesc = "" +
+	"\n" +
+	"&learningrewards/events/v1/events.proto\x12\x19learningrewards.events.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xba\x01\n" +
+	"\rLearningEvent\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventType\x12\x1b\n" +
+	"\tcourse_id\x18\x03 \x01(\tR\bcourseId\x12\x1a\n" +
+	"\bcategory\x18\x04 \x01(\tR\bcategory\x128\n" +
+	"\ttimestamp\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"U\n" +
+	"\x13PublishEventRequest\x12>\n" +
+	"\x05event\x18\x01 \x01(\v2(.learningrewards.events.v1.LearningEventR\x05event\"\x16\n" +
+	"\x14PublishEventResponse\"V\n" +
+	"\x14PublishEventsRequest\x12>\n" +
+	"\x05event\x18\x01 \x01(\v2(.learningrewards.events.v1.LearningEventR\x05event\"3\n" +
+	"\x15PublishEventsResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x05R\baccepted2\xf5\x01\n" +
+	"\fEventService\x12o\n" +
+	"\fPublishEvent\x12..learningrewards.events.v1.PublishEventRequest\x1a/.learningrewards.events.v1.PublishEventResponse\x12t\n" +
+	"\rPublishEvents\x12/.learningrewards.events.v1.PublishEventsRequest\x1a0.learningrewards.events.v1.PublishEventsResponse(\x01B@Z>event-processor/internal/pb/learningrewards/events/v1;eventsv1b\x06proto3"
+
+var (
+	file_learningrewards_events_v1_events_proto_rawDescOnce sync.Once
+	file_learningrewards_events_v1_events_proto_rawDescData []byte
+)
+
+func file_learningrewards_events_v1_events_proto_rawDescGZIP() []byte {
+	file_learningrewards_events_v1_events_proto_rawDescOnce.Do(func() {
+		file_learningrewards_events_v1_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_learningrewards_events_v1_events_proto_rawDesc), len(file_learningrewards_events_v1_events_proto_rawDesc)))
+	})
+	return file_learningrewards_events_v1_events_proto_rawDescData
+}
+
+var file_learningrewards_events_v1_events_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_learningrewards_events_v1_events_proto_goTypes = []any{
+	(*LearningEvent)(nil),         // 0: learningrewards.events.v1.LearningEvent
+	(*PublishEventRequest)(nil),   // 1: learningrewards.events.v1.PublishEventRequest
+	(*PublishEventResponse)(nil),  // 2: learningrewards.events.v1.PublishEventResponse
+	(*PublishEventsRequest)(nil),  // 3: learningrewards.events.v1.PublishEventsRequest
+	(*PublishEventsResponse)(nil), // 4: learningrewards.events.v1.PublishEventsResponse
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_learningrewards_events_v1_events_proto_depIdxs = []int32{
+	5, // 0: learningrewards.events.v1.LearningEvent.timestamp:type_name -> google.protobuf.Timestamp
+	0, // 1: learningrewards.events.v1.PublishEventRequest.event:type_name -> learningrewards.events.v1.LearningEvent
+	0, // 2: learningrewards.events.v1.PublishEventsRequest.event:type_name -> learningrewards.events.v1.LearningEvent
+	1, // 3: learningrewards.events.v1.EventService.PublishEvent:input_type -> learningrewards.events.v1.PublishEventRequest
+	3, // 4: learningrewards.events.v1.EventService.PublishEvents:input_type -> learningrewards.events.v1.PublishEventsRequest
+	2, // 5: learningrewards.events.v1.EventService.PublishEvent:output_type -> learningrewards.events.v1.PublishEventResponse
+	4, // 6: learningrewards.events.v1.EventService.PublishEvents:output_type -> learningrewards.events.v1.PublishEventsResponse
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_learningrewards_events_v1_events_proto_init() }
+func file_learningrewards_events_v1_events_proto_init() {
+	if File_learningrewards_events_v1_events_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_learningrewards_events_v1_events_proto_rawDesc), len(file_learningrewards_events_v1_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_learningrewards_events_v1_events_proto_goTypes,
+		DependencyIndexes: file_learningrewards_events_v1_events_proto_depIdxs,
+		MessageInfos:      file_learningrewards_events_v1_events_proto_msgTypes,
+	}.Build()
+	File_learningrewards_events_v1_events_proto = out.File
+	file_learningrewards_events_v1_events_proto_goTypes = nil
+	file_learningrewards_events_v1_events_proto_depIdxs = nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"event-processor/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventFilter selects audit log events. Zero fields are not filtered on.
+type EventFilter struct {
+	UserID    string
+	EventType string
+	ClientID  string
+	// From and To bound the event timestamp; From is inclusive, To exclusive
+	From time.Time
+	To   time.Time
+	// After continues a listing after the given position
+	After *Cursor
+	Limit int
+}
+
+// Cursor is a position in the audit log, which is ordered by event timestamp
+// and then ID
+type Cursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// EventRepository defines the interface of the event audit log
+type EventRepository interface {
+	// Save stores an accepted event
+	Save(ctx context.Context, record models.EventRecord) error
+	// Delete removes an event, e.g. when it could not be published
+	Delete(ctx context.Context, id string) error
+	// Find returns the events matching a filter in timestamp order
+	Find(ctx context.Context, filter EventFilter) ([]models.EventRecord, error)
+	// DeleteReceivedBefore deletes the events received before cutoff in
+	// batches of batchSize and returns how many were deleted
+	DeleteReceivedBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error)
+}
+
+// Ensure GormEventRepository implements EventRepository
+var _ EventRepository = (*GormEventRepository)(nil)
+
+// GormEventRepository implements EventRepository using GORM
+type GormEventRepository struct {
+	db *gorm.DB
+}
+
+// NewGormEventRepository creates a new GORM-based event repository
+func NewGormEventRepository(db *gorm.DB) *GormEventRepository {
+	return &GormEventRepository{db: db}
+}
+
+// Save implements EventRepository
+func (r *GormEventRepository) Save(ctx context.Context, record models.EventRecord) error {
+	return r.db.WithContext(ctx).Create(&record).Error
+}
+
+// Delete implements EventRepository
+func (r *GormEventRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.EventRecord{}, "id = ?", id).Error
+}
+
+// Find implements EventRepository
+func (r *GormEventRepository) Find(ctx context.Context, filter EventFilter) ([]models.EventRecord, error) {
+	query := r.db.WithContext(ctx).Model(&models.EventRecord{})
+
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.ClientID != "" {
+		query = query.Where("client_id = ?", filter.ClientID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+	if filter.After != nil {
+		query = query.Where("(timestamp, id) > (?, ?)", filter.After.Timestamp, filter.After.ID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var records []models.EventRecord
+	err := query.Order("timestamp, id").Find(&records).Error
+	return records, err
+}
+
+// DeleteReceivedBefore implements EventRepository
+func (r *GormEventRepository) DeleteReceivedBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	var total int64
+	for {
+		// Delete in batches to keep transactions and locks short
+		result := r.db.WithContext(ctx).Exec(
+			"DELETE FROM learning_events WHERE id IN (SELECT id FROM learning_events WHERE received_at < ? LIMIT ?)",
+			cutoff, batchSize)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Format is the wire format of message payloads
+type Format string
+
+const (
+	// FormatJSON encodes payloads as plain JSON
+	FormatJSON Format = "json"
+	// FormatAvro encodes payloads with the Avro single object encoding, which
+	// prefixes the binary data with the fingerprint of the writer schema
+	FormatAvro Format = "avro"
+)
+
+// AvroContentType is the content type of Avro single-object-encoded payloads
+const AvroContentType = "application/avro"
+
+// singleObjectMagic is the marker of the Avro single object encoding
+var singleObjectMagic = [2]byte{0xC3, 0x01}
+
+const singleObjectHeaderLen = 10
+
+// ParseFormat parses a configured payload format, defaulting to JSON
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatAvro:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown payload format %q, expected json or avro", s)
+	}
+}
+
+// IsSingleObject reports whether data starts with the single object marker
+func IsSingleObject(data []byte) bool {
+	return len(data) >= singleObjectHeaderLen && data[0] == singleObjectMagic[0] && data[1] == singleObjectMagic[1]
+}
+
+// Marshal encodes v with the latest schema of subject. It returns the payload
+// and the version it was written with.
+func (r *Registry) Marshal(subject string, v any) ([]byte, *Version, error) {
+	latest, err := r.Latest(subject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := avro.Marshal(latest.Schema, v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s v%d: %w", subject, latest.Version, err)
+	}
+
+	data := make([]byte, singleObjectHeaderLen, singleObjectHeaderLen+len(body))
+	data[0], data[1] = singleObjectMagic[0], singleObjectMagic[1]
+	binary.LittleEndian.PutUint64(data[2:], latest.Fingerprint)
+	return append(data, body...), latest, nil
+}
+
+// Unmarshal decodes a single-object-encoded payload into v. Data written with
+// any registered version of subject is resolved against its latest version,
+// which is the one the generated types match.
+func (r *Registry) Unmarshal(subject string, data []byte, v any) error {
+	if !IsSingleObject(data) {
+		return fmt.Errorf("payload is not Avro single-object encoded")
+	}
+
+	fingerprint := binary.LittleEndian.Uint64(data[2:singleObjectHeaderLen])
+	writer, ok := r.Lookup(fingerprint)
+	if !ok {
+		return fmt.Errorf("%w %016x", ErrUnknownSchema, fingerprint)
+	}
+	if writer.Subject != subject {
+		return fmt.Errorf("payload was written for subject %s, expected %s", writer.Subject, subject)
+	}
+
+	reader, err := r.Latest(subject)
+	if err != nil {
+		return err
+	}
+	resolved, err := r.resolve(reader, writer)
+	if err != nil {
+		return fmt.Errorf("cannot read %s v%d as v%d: %w", subject, writer.Version, reader.Version, err)
+	}
+
+	return avro.Unmarshal(resolved, data[singleObjectHeaderLen:], v)
+}
+
+// DataSchema returns the CloudEvents dataschema URI of a version
+func (v *Version) DataSchema() string {
+	return fmt.Sprintf("urn:learning-rewards:schema:%s:%d", v.Subject, v.Version)
+}
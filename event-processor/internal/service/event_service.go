@@ -2,34 +2,146 @@ package service
 
 import (
 	"context"
+	"event-processor/internal/auth"
+	"event-processor/internal/logger"
 	"event-processor/internal/messaging/kafka"
 	"event-processor/internal/models"
+	"event-processor/internal/repository"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
 )
 
 type EventService interface {
 	ProcessEvent(ctx context.Context, userID, eventType, courseID, category string, timestamp time.Time) error
+	ProcessEvents(ctx context.Context, events []models.LearningEvent) error
+	QueryEvents(ctx context.Context, filter repository.EventFilter) (EventPage, error)
+}
+
+// EventPage is a page of the event audit log
+type EventPage struct {
+	Events []models.EventRecord
+	// Next is the position of the next page; nil on the last page
+	Next *repository.Cursor
 }
 
 type eventService struct {
 	producer *kafka.Producer
+	limiter  *auth.Limiter
+	events   repository.EventRepository
 }
 
-func NewEventService(producer *kafka.Producer) EventService {
-	return &eventService{producer: producer}
+// NewEventService creates the event service. A nil limiter disables rate
+// limiting and a nil repository disables the audit log.
+func NewEventService(producer *kafka.Producer, limiter *auth.Limiter, events repository.EventRepository) EventService {
+	return &eventService{producer: producer, limiter: limiter, events: events}
 }
 
+// ProcessEvent records a learning event in the audit log and publishes it.
+// When the context carries an authenticated client, the event type must be in
+// its scope and the client is recorded on the event. It returns
+// auth.ErrForbiddenEventType or an *auth.RateLimitError when the event is
+// refused.
 func (s *eventService) ProcessEvent(ctx context.Context, userID, eventType, courseID, category string, timestamp time.Time) error {
-	event := models.LearningEvent{
-		ID:        uuid.New(),
+	return s.ProcessEvents(ctx, []models.LearningEvent{{
 		UserID:    userID,
 		EventType: eventType,
 		CourseID:  courseID,
 		Category:  category,
 		Timestamp: timestamp,
+	}})
+}
+
+// ProcessEvents records and publishes a batch of learning events, in order,
+// like ProcessEvent. The scope and the rate limits are checked for the whole
+// batch before any event is published, so a refused batch publishes nothing.
+func (s *eventService) ProcessEvents(ctx context.Context, events []models.LearningEvent) error {
+	client, authenticated := auth.ClientFrom(ctx)
+	userIDs := make([]string, len(events))
+	for i, event := range events {
+		if authenticated && !client.Allows(event.EventType) {
+			return auth.ErrForbiddenEventType
+		}
+		userIDs[i] = event.UserID
+	}
+	if s.limiter != nil {
+		if err := s.limiter.AllowBatch(client, userIDs); err != nil {
+			return err
+		}
 	}
 
-	return s.producer.PublishEvent(ctx, event)
+	for _, event := range events {
+		event.ID = uuid.New().String()
+		event.CreatedAt = time.Now().UTC()
+		if authenticated {
+			event.ClientID = client.ID
+		}
+		if err := s.publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish stores event in the audit log and publishes it
+func (s *eventService) publish(ctx context.Context, event models.LearningEvent) error {
+	if s.events == nil {
+		return s.producer.PublishEvent(ctx, event)
+	}
+
+	// Store first so that every published event is in the audit log
+	if err := s.events.Save(ctx, models.NewEventRecord(event)); err != nil {
+		return fmt.Errorf("failed to store event: %w", err)
+	}
+	if err := s.producer.PublishEvent(ctx, event); err != nil {
+		// The event was not accepted, keep it out of the audit log
+		if delErr := s.events.Delete(context.WithoutCancel(ctx), event.ID); delErr != nil {
+			logger.Get().Error("failed to remove unpublished event from the audit log",
+				zap.String("event_id", event.ID),
+				zap.Error(delErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// QueryEvents returns a page of the audit log. Authenticated clients need
+// read access. The limit defaults to 100 and is capped at 1000.
+func (s *eventService) QueryEvents(ctx context.Context, filter repository.EventFilter) (EventPage, error) {
+	if client, ok := auth.ClientFrom(ctx); ok && !client.ReadEvents {
+		return EventPage{}, auth.ErrReadNotAllowed
+	}
+	if s.events == nil {
+		return EventPage{}, fmt.Errorf("event audit log is disabled")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	// Fetch one more event to know whether there is a next page
+	filter.Limit = limit + 1
+	records, err := s.events.Find(ctx, filter)
+	if err != nil {
+		return EventPage{}, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	page := EventPage{Events: records}
+	if len(records) > limit {
+		page.Events = records[:limit]
+		last := page.Events[limit-1]
+		page.Next = &repository.Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	return page, nil
 }
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"event-processor/internal/auth"
+	"event-processor/internal/models"
+	"event-processor/internal/repository"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeEventRepository holds events in timestamp order
+type fakeEventRepository struct {
+	repository.EventRepository
+	records []models.EventRecord
+	limits  []int
+	saved   []models.EventRecord
+}
+
+func (f *fakeEventRepository) Save(ctx context.Context, record models.EventRecord) error {
+	f.saved = append(f.saved, record)
+	return nil
+}
+
+func (f *fakeEventRepository) Find(ctx context.Context, filter repository.EventFilter) ([]models.EventRecord, error) {
+	f.limits = append(f.limits, filter.Limit)
+	var found []models.EventRecord
+	for _, r := range f.records {
+		if filter.After != nil && !r.Timestamp.After(filter.After.Timestamp) {
+			continue
+		}
+		if len(found) == filter.Limit {
+			break
+		}
+		found = append(found, r)
+	}
+	return found, nil
+}
+
+func TestQueryEventsPaginates(t *testing.T) {
+	start := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	repo := &fakeEventRepository{}
+	for i := 0; i < 5; i++ {
+		repo.records = append(repo.records, models.EventRecord{ID: fmt.Sprint(i), Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+	svc := NewEventService(nil, nil, repo)
+
+	var ids []string
+	filter := repository.EventFilter{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+		page, err := svc.QueryEvents(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, e := range page.Events {
+			ids = append(ids, e.ID)
+		}
+		if page.Next == nil {
+			break
+		}
+		filter.After = page.Next
+	}
+
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Errorf("expected every event once, got %v", ids)
+	}
+}
+
+func TestQueryEventsLimit(t *testing.T) {
+	repo := &fakeEventRepository{}
+	svc := NewEventService(nil, nil, repo)
+
+	for _, limit := range []int{0, 5000} {
+		if _, err := svc.QueryEvents(context.Background(), repository.EventFilter{Limit: limit}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.limits[0] != defaultQueryLimit+1 || repo.limits[1] != maxQueryLimit+1 {
+		t.Errorf("unexpected limits %v", repo.limits)
+	}
+}
+
+func TestQueryEventsRequiresReadAccess(t *testing.T) {
+	svc := NewEventService(nil, nil, &fakeEventRepository{})
+
+	ctx := auth.WithClient(context.Background(), &auth.Client{ID: "lms"})
+	if _, err := svc.QueryEvents(ctx, repository.EventFilter{}); !errors.Is(err, auth.ErrReadNotAllowed) {
+		t.Errorf("expected ErrReadNotAllowed, got %v", err)
+	}
+
+	ctx = auth.WithClient(context.Background(), &auth.Client{ID: "auditor", ReadEvents: true})
+	if _, err := svc.QueryEvents(ctx, repository.EventFilter{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestProcessEventsRefusesWholeBatch(t *testing.T) {
+	batch := []models.LearningEvent{
+		{UserID: "user-1", EventType: "COURSE_COMPLETED"},
+		{UserID: "user-1", EventType: "CHAPTER_COMPLETED"},
+		{UserID: "user-1", EventType: "QUIZ_PASSED"},
+	}
+	ctx := auth.WithClient(context.Background(), &auth.Client{ID: "lms"})
+
+	tests := []struct {
+		name    string
+		limiter *auth.Limiter
+		ctx     context.Context
+		check   func(error) bool
+	}{
+		{
+			name:    "over the user limit on the last event",
+			limiter: auth.NewLimiter(auth.LimiterConfig{UserRate: 1, UserBurst: 2}),
+			ctx:     ctx,
+			check: func(err error) bool {
+				var limited *auth.RateLimitError
+				return errors.As(err, &limited) && limited.Scope == "user"
+			},
+		},
+		{
+			name:  "last event out of scope",
+			ctx:   auth.WithClient(context.Background(), &auth.Client{ID: "lms", EventTypes: []string{"COURSE_COMPLETED", "CHAPTER_COMPLETED"}}),
+			check: func(err error) bool { return errors.Is(err, auth.ErrForbiddenEventType) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The producer is nil: publishing any event would panic
+			repo := &fakeEventRepository{}
+			svc := NewEventService(nil, tt.limiter, repo)
+
+			if err := svc.ProcessEvents(tt.ctx, batch); !tt.check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(repo.saved) != 0 {
+				t.Errorf("expected nothing published, got %d events", len(repo.saved))
+			}
+		})
+	}
+}
+
+func TestProcessEventsGivesBackTokens(t *testing.T) {
+	limiter := auth.NewLimiter(auth.LimiterConfig{ClientRate: 1, ClientBurst: 3, UserRate: 1, UserBurst: 1})
+	svc := NewEventService(nil, limiter, &fakeEventRepository{})
+	ctx := auth.WithClient(context.Background(), &auth.Client{ID: "lms"})
+
+	batch := []models.LearningEvent{{UserID: "user-1"}, {UserID: "user-2"}, {UserID: "user-2"}}
+	var limited *auth.RateLimitError
+	if err := svc.ProcessEvents(ctx, batch); !errors.As(err, &limited) || limited.Scope != "user" {
+		t.Fatalf("expected user rate limit, got %v", err)
+	}
+
+	// The refused batch took no token from the client or from user-1
+	for _, user := range []string{"user-1", "user-3", "user-4"} {
+		if err := limiter.Allow(&auth.Client{ID: "lms"}, user); err != nil {
+			t.Fatalf("%s: unexpected error: %v", user, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"event-processor/internal/logger"
+	"event-processor/internal/repository"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRetentionInterval  = time.Hour
+	defaultRetentionBatchSize = 10000
+)
+
+// Retention deletes audit log events received longer ago than a maximum age
+type Retention struct {
+	events    repository.EventRepository
+	maxAge    time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRetention creates a retention policy checked every hour
+func NewRetention(events repository.EventRepository, maxAge time.Duration) *Retention {
+	return &Retention{
+		events:    events,
+		maxAge:    maxAge,
+		interval:  defaultRetentionInterval,
+		batchSize: defaultRetentionBatchSize,
+	}
+}
+
+// Purge deletes the expired events once
+func (r *Retention) Purge(ctx context.Context) (int64, error) {
+	return r.events.DeleteReceivedBefore(ctx, time.Now().Add(-r.maxAge), r.batchSize)
+}
+
+// Run purges expired events at startup and then periodically until ctx is
+// cancelled
+func (r *Retention) Run(ctx context.Context) {
+	log := logger.Get()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := r.Purge(ctx)
+		if err != nil {
+			log.Error("failed to purge expired events", zap.Error(err))
+		} else if deleted > 0 {
+			log.Info("purged expired events",
+				zap.Int64("deleted", deleted),
+				zap.Duration("max_age", r.maxAge))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package transport
+
+import (
+	"errors"
+	"event-processor/internal/auth"
+	"event-processor/internal/logger"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// apiKeyHeader is the alternative to the Authorization: Bearer header
+const apiKeyHeader = "X-API-Key"
+
+// authenticate rejects requests without a valid API key and stores the
+// authenticated client in the request context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := s.keys.Authenticate(r.Context(), apiKey(r))
+		if err != nil {
+			logger.Get().Debug("request rejected",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Error(err))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="event-processor"`)
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithClient(r.Context(), client)))
+	})
+}
+
+// apiKey returns the key of a request, taken from the Authorization bearer
+// token or the X-API-Key header
+func apiKey(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.Header.Get(apiKeyHeader)
+}
+
+// writeServiceError maps an error of the event service to a response
+func writeServiceError(w http.ResponseWriter, err error) {
+	var rateLimited *auth.RateLimitError
+	switch {
+	case errors.As(err, &rateLimited):
+		w.Header().Set("Retry-After", strconv.Itoa(rateLimited.RetryAfterSeconds()))
+		http.Error(w, "Too Many Requests: "+err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, auth.ErrForbiddenEventType), errors.Is(err, auth.ErrReadNotAllowed):
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
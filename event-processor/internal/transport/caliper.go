@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"encoding/json"
+	"event-processor/internal/caliper"
+	"event-processor/internal/logger"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// CaliperResponse reports the outcome of a Caliper envelope
+type CaliperResponse struct {
+	Accepted int                 `json:"accepted"`
+	Rejected []caliper.Rejection `json:"rejected"`
+}
+
+// handleCaliperEnvelope accepts a Caliper envelope, publishes every event that
+// has a mapping and reports the ones that were rejected. It responds with
+// 422 Unprocessable Entity when no event of the envelope could be mapped. The
+// mapped events are published as a batch: if one is out of the client's scope
+// or over a rate limit, none is.
+func (s *Server) handleCaliperEnvelope(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatementsBody))
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	envelope, err := caliper.DecodeEnvelope(body)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, rejections := s.caliper.MapEnvelope(envelope)
+	for _, rejection := range rejections {
+		logger.Get().Warn("Caliper event rejected",
+			zap.String("sensor", envelope.Sensor),
+			zap.Int("index", rejection.Index),
+			zap.String("event_id", rejection.ID),
+			zap.String("type", rejection.Type),
+			zap.String("action", rejection.Action),
+			zap.String("reason", rejection.Reason))
+	}
+
+	if err := s.svc.ProcessEvents(r.Context(), events); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	status := http.StatusAccepted
+	if len(events) == 0 && len(rejections) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	if rejections == nil {
+		rejections = []caliper.Rejection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(CaliperResponse{
+		Accepted: len(events),
+		Rejected: rejections,
+	})
+}
@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"event-processor/internal/models"
+	"event-processor/internal/repository"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventsResponse is a page of the event audit log
+type EventsResponse struct {
+	Events []models.EventRecord `json:"events"`
+	// NextCursor is passed as cursor to fetch the next page; empty on the
+	// last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// handleListEvents queries the event audit log. Events are filtered by the
+// user_id, event_type, client_id, from and to (RFC 3339, on the event
+// timestamp) query parameters and paginated with limit and cursor.
+func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.svc.QueryEvents(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	resp := EventsResponse{Events: page.Events}
+	if resp.Events == nil {
+		resp.Events = []models.EventRecord{}
+	}
+	if page.Next != nil {
+		resp.NextCursor = encodeCursor(*page.Next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func parseEventFilter(query url.Values) (repository.EventFilter, error) {
+	filter := repository.EventFilter{
+		UserID:    query.Get("user_id"),
+		EventType: query.Get("event_type"),
+		ClientID:  query.Get("client_id"),
+	}
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
+
+// encodeCursor returns an opaque token for a position in the audit log
+func encodeCursor(c repository.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.ID))
+}
+
+func decodeCursor(token string) (repository.Cursor, error) {
+	errInvalid := errors.New("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return repository.Cursor{}, errInvalid
+	}
+	ts, id, ok := strings.Cut(string(data), "|")
+	if !ok || id == "" {
+		return repository.Cursor{}, errInvalid
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return repository.Cursor{}, errInvalid
+	}
+	return repository.Cursor{Timestamp: timestamp, ID: id}, nil
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"encoding/json"
+	"event-processor/internal/auth"
+	"event-processor/internal/models"
+	"event-processor/internal/repository"
+	"event-processor/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListEvents(t *testing.T) {
+	ts := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	next := repository.Cursor{Timestamp: ts, ID: "3f1c2a9e-6a4b-4c6e-9d1a-0b7f4f1e2d3c"}
+	svc := &fakeEventService{page: service.EventPage{
+		Events: []models.EventRecord{{ID: next.ID, UserID: "user-1", Timestamp: ts}},
+		Next:   &next,
+	}}
+	server := NewServer(svc, Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/events?user_id=user-1&event_type=COURSE_COMPLETED&from=2025-06-01T00:00:00Z&limit=1", nil)
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp EventsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Events) != 1 || resp.NextCursor == "" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	filter := svc.queried[0]
+	if filter.UserID != "user-1" || filter.EventType != "COURSE_COMPLETED" || filter.Limit != 1 ||
+		!filter.From.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) || !filter.To.IsZero() {
+		t.Errorf("unexpected filter: %+v", filter)
+	}
+
+	// The next cursor continues after the last event
+	req = httptest.NewRequest(http.MethodGet, "/events?cursor="+resp.NextCursor, nil)
+	server.Router().ServeHTTP(httptest.NewRecorder(), req)
+	if after := svc.queried[1].After; after == nil || *after != next {
+		t.Errorf("expected cursor %+v, got %+v", next, after)
+	}
+}
+
+func TestListEventsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		svcErr   error
+		wantCode int
+	}{
+		{name: "invalid time", query: "from=yesterday", wantCode: http.StatusBadRequest},
+		{name: "invalid limit", query: "limit=0", wantCode: http.StatusBadRequest},
+		{name: "invalid cursor", query: "cursor=abc", wantCode: http.StatusBadRequest},
+		{name: "no read access", svcErr: auth.ErrReadNotAllowed, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&fakeEventService{err: tt.svcErr}, Config{})
+			rec := httptest.NewRecorder()
+			server.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?"+tt.query, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body)
+			}
+		})
+	}
+}
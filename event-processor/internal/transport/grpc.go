@@ -0,0 +1,204 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"event-processor/internal/auth"
+	"event-processor/internal/logger"
+	"event-processor/internal/metrics"
+	eventsv1 "event-processor/internal/pb/learningrewards/events/v1"
+	"event-processor/internal/service"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// GRPCServer exposes the event service over gRPC
+type GRPCServer struct {
+	eventsv1.UnimplementedEventServiceServer
+
+	svc    service.EventService
+	keys   auth.Store
+	server *grpc.Server
+	health *health.Server
+}
+
+// NewGRPCServer creates the gRPC server. Like the HTTP server, calls are
+// authenticated when keys is not nil.
+func NewGRPCServer(svc service.EventService, keys auth.Store) *GRPCServer {
+	s := &GRPCServer{
+		svc:    svc,
+		keys:   keys,
+		health: health.NewServer(),
+	}
+
+	unary := []grpc.UnaryServerInterceptor{metrics.UnaryInterceptor, traceUnary}
+	stream := []grpc.StreamServerInterceptor{metrics.StreamInterceptor, traceStream}
+	if keys != nil {
+		unary = append(unary, s.authenticateUnary)
+		stream = append(stream, s.authenticateStream)
+	}
+	s.server = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	eventsv1.RegisterEventServiceServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(eventsv1.EventService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+// Server returns the underlying gRPC server
+func (s *GRPCServer) Server() *grpc.Server {
+	return s.server
+}
+
+// PublishEvent publishes a single learning event
+func (s *GRPCServer) PublishEvent(ctx context.Context, req *eventsv1.PublishEventRequest) (*eventsv1.PublishEventResponse, error) {
+	if err := s.publish(ctx, req.GetEvent()); err != nil {
+		return nil, err
+	}
+	return &eventsv1.PublishEventResponse{}, nil
+}
+
+// PublishEvents publishes a stream of learning events, stopping at the first
+// one that cannot be published
+func (s *GRPCServer) PublishEvents(stream eventsv1.EventService_PublishEventsServer) error {
+	var accepted int32
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&eventsv1.PublishEventsResponse{Accepted: accepted})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.publish(stream.Context(), req.GetEvent()); err != nil {
+			// Keep the code and details, e.g. RetryInfo, of the original status
+			st := status.Convert(err).Proto()
+			st.Message = fmt.Sprintf("event %d: %s", accepted, st.Message)
+			return status.FromProto(st).Err()
+		}
+		accepted++
+	}
+}
+
+func (s *GRPCServer) publish(ctx context.Context, event *eventsv1.LearningEvent) error {
+	if event == nil {
+		return status.Error(codes.InvalidArgument, "event is required")
+	}
+
+	var timestamp time.Time
+	if event.GetTimestamp() != nil {
+		timestamp = event.GetTimestamp().AsTime()
+	}
+
+	err := s.svc.ProcessEvent(ctx, event.GetUserId(), event.GetEventType(), event.GetCourseId(), event.GetCategory(), timestamp)
+	if err != nil {
+		return serviceStatus(err)
+	}
+	return nil
+}
+
+// serviceStatus maps an error of the event service to a gRPC status. Rate
+// limited calls carry a RetryInfo detail, the gRPC counterpart of the
+// Retry-After header.
+func serviceStatus(err error) error {
+	var rateLimited *auth.RateLimitError
+	switch {
+	case errors.As(err, &rateLimited):
+		st := status.New(codes.ResourceExhausted, err.Error())
+		if detailed, detailErr := st.WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(rateLimited.RetryAfter),
+		}); detailErr == nil {
+			st = detailed
+		}
+		return st.Err()
+	case errors.Is(err, auth.ErrForbiddenEventType), errors.Is(err, auth.ErrReadNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		logger.Get().Error("failed to publish event over gRPC", zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (s *GRPCServer) authenticateUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isPublicMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *GRPCServer) authenticateStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublicMethod(info.FullMethod) {
+		return handler(srv, stream)
+	}
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate returns a context carrying the client owning the API key of
+// the call metadata
+func (s *GRPCServer) authenticate(ctx context.Context) (context.Context, error) {
+	client, err := s.keys.Authenticate(ctx, metadataAPIKey(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.WithClient(ctx, client), nil
+}
+
+// metadataAPIKey returns the key of a call, taken from the authorization
+// bearer token or the x-api-key metadata
+func metadataAPIKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if values := md.Get(strings.ToLower(apiKeyHeader)); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// isPublicMethod reports whether a method may be called without an API key:
+// health checks and server reflection
+func isPublicMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") ||
+		strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+// authenticatedStream overrides the context of a server stream
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
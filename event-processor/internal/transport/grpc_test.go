@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"event-processor/internal/auth"
+	"event-processor/internal/models"
+	eventsv1 "event-processor/internal/pb/learningrewards/events/v1"
+	"event-processor/internal/repository"
+	"event-processor/internal/service"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type publishedEvent struct {
+	clientID  string
+	userID    string
+	eventType string
+	timestamp time.Time
+}
+
+// fakeEventService records events and queries and fails with err once set
+type fakeEventService struct {
+	events []publishedEvent
+	err    error
+	// spans holds the span context each event was published in
+	spans []trace.SpanContext
+
+	page    service.EventPage
+	queried []repository.EventFilter
+}
+
+func (f *fakeEventService) ProcessEvent(ctx context.Context, userID, eventType, courseID, category string, timestamp time.Time) error {
+	if f.err != nil {
+		return f.err
+	}
+	event := publishedEvent{userID: userID, eventType: eventType, timestamp: timestamp}
+	if client, ok := auth.ClientFrom(ctx); ok {
+		event.clientID = client.ID
+	}
+	f.events = append(f.events, event)
+	f.spans = append(f.spans, trace.SpanContextFromContext(ctx))
+	return nil
+}
+
+func (f *fakeEventService) ProcessEvents(ctx context.Context, events []models.LearningEvent) error {
+	for _, event := range events {
+		if err := f.ProcessEvent(ctx, event.UserID, event.EventType, event.CourseID, event.Category, event.Timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeEventService) QueryEvents(ctx context.Context, filter repository.EventFilter) (service.EventPage, error) {
+	f.queried = append(f.queried, filter)
+	return f.page, f.err
+}
+
+func newTestGRPCClient(t *testing.T, svc *fakeEventService) *grpc.ClientConn {
+	t.Helper()
+
+	keys, err := auth.NewMemoryStore(auth.Client{ID: "lms", KeySHA256: auth.HashKey("lms-key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(svc, keys)
+	go server.Server().Serve(lis)
+	t.Cleanup(server.Server().Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+}
+
+func TestGRPCPublishEvent(t *testing.T) {
+	svc := &fakeEventService{}
+	client := eventsv1.NewEventServiceClient(newTestGRPCClient(t, svc))
+	ts := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+
+	_, err := client.PublishEvent(withKey("lms-key"), &eventsv1.PublishEventRequest{
+		Event: &eventsv1.LearningEvent{UserId: "user-1", EventType: "COURSE_COMPLETED", Timestamp: timestamppb.New(ts)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := publishedEvent{clientID: "lms", userID: "user-1", eventType: "COURSE_COMPLETED", timestamp: ts}
+	if len(svc.events) != 1 || svc.events[0] != want {
+		t.Errorf("expected %+v, got %+v", want, svc.events)
+	}
+}
+
+func TestGRPCPublishEventErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		svcErr   error
+		event    *eventsv1.LearningEvent
+		wantCode codes.Code
+	}{
+		{name: "missing key", key: "", event: &eventsv1.LearningEvent{}, wantCode: codes.Unauthenticated},
+		{name: "invalid key", key: "guess", event: &eventsv1.LearningEvent{}, wantCode: codes.Unauthenticated},
+		{name: "missing event", key: "lms-key", wantCode: codes.InvalidArgument},
+		{name: "forbidden event type", key: "lms-key", svcErr: auth.ErrForbiddenEventType, event: &eventsv1.LearningEvent{}, wantCode: codes.PermissionDenied},
+		{name: "publish failure", key: "lms-key", svcErr: errors.New("kafka down"), event: &eventsv1.LearningEvent{}, wantCode: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := eventsv1.NewEventServiceClient(newTestGRPCClient(t, &fakeEventService{err: tt.svcErr}))
+			_, err := client.PublishEvent(withKey(tt.key), &eventsv1.PublishEventRequest{Event: tt.event})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("expected %s, got %s (%v)", tt.wantCode, code, err)
+			}
+		})
+	}
+}
+
+func TestGRPCPublishEventsStream(t *testing.T) {
+	svc := &fakeEventService{}
+	client := eventsv1.NewEventServiceClient(newTestGRPCClient(t, svc))
+
+	stream, err := client.PublishEvents(withKey("lms-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"user-1", "user-2", "user-3"} {
+		if err := stream.Send(&eventsv1.PublishEventsRequest{Event: &eventsv1.LearningEvent{UserId: user}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetAccepted() != 3 || len(svc.events) != 3 || svc.events[2].clientID != "lms" {
+		t.Errorf("expected 3 events from lms, got %d: %+v", resp.GetAccepted(), svc.events)
+	}
+}
+
+func TestGRPCPublishEventsStreamContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	svc := &fakeEventService{}
+	client := eventsv1.NewEventServiceClient(newTestGRPCClient(t, svc))
+
+	ctx := metadata.AppendToOutgoingContext(withKey("lms-key"),
+		"traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	stream, err := client.PublishEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&eventsv1.PublishEventsRequest{Event: &eventsv1.LearningEvent{UserId: "user-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 server span, got %d", len(spans))
+	}
+	server := spans[0]
+	if server.Name() != eventsv1.EventService_PublishEvents_FullMethodName || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected span %s of kind %s", server.Name(), server.SpanKind())
+	}
+	if got := server.Parent().SpanID().String(); got != "b7ad6b7169203331" {
+		t.Errorf("expected the caller's span as parent, got %s", got)
+	}
+	if len(svc.spans) != 1 || svc.spans[0].SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("expected the event published in span %s, got %v", server.SpanContext().SpanID(), svc.spans)
+	}
+}
+
+func TestGRPCRateLimitedCarriesRetryInfo(t *testing.T) {
+	svc := &fakeEventService{err: &auth.RateLimitError{Scope: "user", RetryAfter: 1500 * time.Millisecond}}
+	client := eventsv1.NewEventServiceClient(newTestGRPCClient(t, svc))
+
+	stream, err := client.PublishEvents(withKey("lms-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&eventsv1.PublishEventsRequest{Event: &eventsv1.LearningEvent{UserId: "user-1"}})
+	_, err = stream.CloseAndRecv()
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay().AsDuration() == 1500*time.Millisecond {
+			return
+		}
+	}
+	t.Errorf("expected a RetryInfo detail, got %v", st.Details())
+}
+
+func TestGRPCHealthIsPublic(t *testing.T) {
+	health := healthpb.NewHealthClient(newTestGRPCClient(t, &fakeEventService{}))
+
+	resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: eventsv1.EventService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %s", resp.GetStatus())
+	}
+}
@@ -2,30 +2,72 @@ package transport
 
 import (
 	"encoding/json"
+	"event-processor/internal/auth"
+	"event-processor/internal/caliper"
 	"event-processor/internal/service"
+	"event-processor/internal/xapi"
 	"net/http"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
 	"github.com/gorilla/mux"
 )
 
+// Config holds the optional ingestion formats of the server
+type Config struct {
+	// XAPIMapper maps xAPI statements to learning events. Defaults to the
+	// default verb mapping.
+	XAPIMapper *xapi.Mapper
+	// CaliperMapper maps Caliper events to learning events. Defaults to the
+	// default mapping table.
+	CaliperMapper *caliper.Mapper
+	// Keys authenticates the clients of the ingestion and audit log
+	// endpoints. When nil, the endpoints are open.
+	Keys auth.Store
+}
+
 type Server struct {
-	svc    service.EventService
-	router *mux.Router
+	svc     service.EventService
+	router  *mux.Router
+	xapi    *xapi.Mapper
+	caliper *caliper.Mapper
+	keys    auth.Store
 }
 
-func NewServer(svc service.EventService) *Server {
+func NewServer(svc service.EventService, cfg Config) *Server {
+	if cfg.XAPIMapper == nil {
+		cfg.XAPIMapper = xapi.NewMapper(xapi.Config{})
+	}
+	if cfg.CaliperMapper == nil {
+		cfg.CaliperMapper = caliper.NewMapper(caliper.Config{})
+	}
+
 	s := &Server{
-		svc:    svc,
-		router: mux.NewRouter(),
+		svc:     svc,
+		router:  mux.NewRouter(),
+		xapi:    cfg.XAPIMapper,
+		caliper: cfg.CaliperMapper,
+		keys:    cfg.Keys,
 	}
 	s.setupRoutes()
 	return s
 }
 
 func (s *Server) setupRoutes() {
-	s.router.HandleFunc("/events", s.handleEvent).Methods(http.MethodPost)
+	s.router.Use(metrics.Middleware)
+	s.router.Use(tracing.Middleware)
 	s.router.HandleFunc("/health", s.handleHealth).Methods(http.MethodGet)
+	s.router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
+	api := s.router.NewRoute().Subrouter()
+	if s.keys != nil {
+		api.Use(s.authenticate)
+	}
+	api.HandleFunc("/events", s.handleEvent).Methods(http.MethodPost)
+	api.HandleFunc("/events", s.handleListEvents).Methods(http.MethodGet)
+	api.HandleFunc("/xapi/statements", s.handleXAPIStatements).Methods(http.MethodPost)
+	api.HandleFunc("/caliper", s.handleCaliperEnvelope).Methods(http.MethodPost)
 }
 
 func (s *Server) Router() *mux.Router {
@@ -50,7 +92,7 @@ func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	err := s.svc.ProcessEvent(ctx, req.UserID, req.EventType, req.CourseID, req.Category, req.Timestamp)
 	if err != nil {
-		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, err)
 		return
 	}
 
@@ -0,0 +1,25 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := NewServer(&fakeEventService{}, Config{})
+
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	rec = httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	want := `http_requests_total{code="200",method="GET",route="/health"}`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %s in metrics:\n%s", want, rec.Body)
+	}
+}
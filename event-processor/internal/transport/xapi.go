@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"event-processor/internal/logger"
+	"event-processor/internal/models"
+	"event-processor/internal/xapi"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// maxStatementsBody caps the size of a statements request body
+const maxStatementsBody = 5 << 20
+
+// handleXAPIStatements accepts a single xAPI statement or a batch of them,
+// maps every statement to a learning event and publishes the mapped ones.
+// Like an LRS, the batch is rejected as a whole if any statement is invalid,
+// out of the client's scope or over a rate limit.
+// Statements with unmapped verbs or unsuccessful results are accepted but not
+// published. The response holds the statement IDs in request order.
+func (s *Server) handleXAPIStatements(w http.ResponseWriter, r *http.Request) {
+	log := logger.Get()
+	w.Header().Set("X-Experience-API-Version", xapi.Version)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatementsBody))
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statements, err := xapi.DecodeStatements(body)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]string, len(statements))
+	var events []models.LearningEvent
+	for i, stmt := range statements {
+		if stmt.ID == "" {
+			stmt.ID = uuid.New().String()
+		} else if _, err := uuid.Parse(stmt.ID); err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: statement %d: invalid id %q", i, stmt.ID), http.StatusBadRequest)
+			return
+		}
+		ids[i] = stmt.ID
+
+		event, err := s.xapi.Map(stmt)
+		switch {
+		case err == nil:
+			events = append(events, event)
+		case errors.Is(err, xapi.ErrUnmappedVerb), errors.Is(err, xapi.ErrUnsuccessful):
+			log.Debug("xAPI statement not published",
+				zap.String("statement_id", stmt.ID),
+				zap.String("verb", stmt.Verb.ID),
+				zap.String("reason", err.Error()))
+		default:
+			http.Error(w, fmt.Sprintf("Bad Request: statement %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.svc.ProcessEvents(r.Context(), events); err != nil {
+		log.Error("failed to publish xAPI statements",
+			zap.Int("statements", len(statements)),
+			zap.Error(err))
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ids)
+}
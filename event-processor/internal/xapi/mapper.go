@@ -0,0 +1,193 @@
+package xapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"event-processor/internal/iri"
+	"event-processor/internal/models"
+)
+
+// Well-known ADL verbs and activity types used by the default mapping
+const (
+	VerbCompleted = "http://adlnet.gov/expapi/verbs/completed"
+	VerbPassed    = "http://adlnet.gov/expapi/verbs/passed"
+
+	ActivityCourse = "http://adlnet.gov/expapi/activities/course"
+	ActivityModule = "http://adlnet.gov/expapi/activities/module"
+	ActivityLesson = "http://adlnet.gov/expapi/activities/lesson"
+)
+
+// DefaultCategoryExtension is the activity extension read for the event category
+const DefaultCategoryExtension = "https://w3id.org/learning-rewards/extensions/category"
+
+var (
+	// ErrInvalidStatement is returned when a statement lacks required properties
+	ErrInvalidStatement = errors.New("invalid xAPI statement")
+	// ErrUnmappedVerb is returned when no event type is configured for a verb
+	ErrUnmappedVerb = errors.New("no event type mapped for verb")
+	// ErrUnsuccessful is returned when the statement result reports a failure
+	// and the mapper only accepts successful outcomes
+	ErrUnsuccessful = errors.New("statement result is not successful")
+)
+
+// VerbMapping maps verb IRIs to event types. A key is either a verb IRI or
+// "<verb IRI>|<activity type IRI>"; the latter takes precedence so the same
+// verb can yield different event types for courses and chapters.
+type VerbMapping map[string]string
+
+// DefaultVerbMapping returns the mapping used when none is configured
+func DefaultVerbMapping() VerbMapping {
+	return VerbMapping{
+		VerbCompleted:                        "COURSE_COMPLETED",
+		VerbCompleted + "|" + ActivityCourse: "COURSE_COMPLETED",
+		VerbCompleted + "|" + ActivityModule: "CHAPTER_COMPLETED",
+		VerbCompleted + "|" + ActivityLesson: "CHAPTER_COMPLETED",
+		VerbPassed + "|" + ActivityCourse:    "COURSE_COMPLETED",
+	}
+}
+
+// ParseVerbMapping parses a comma-separated list of "key=EVENT_TYPE" pairs,
+// where key is a verb IRI optionally followed by "|<activity type IRI>"
+func ParseVerbMapping(s string) (VerbMapping, error) {
+	mapping := VerbMapping{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		// IRIs may not contain '=' in their scheme or authority, but they can in
+		// a query string, so split on the last one
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("invalid verb mapping %q, expected <verb>[|<activity type>]=<event type>", pair)
+		}
+		mapping[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("verb mapping is empty")
+	}
+	return mapping, nil
+}
+
+// Config configures how statements are mapped to learning events
+type Config struct {
+	// Verbs maps verbs to event types
+	Verbs VerbMapping
+	// ObjectIRIPrefix is stripped from the object IRI to obtain the course ID.
+	// When empty, or when the IRI does not start with it, the last path segment
+	// of the IRI is used instead.
+	ObjectIRIPrefix string
+	// CategoryExtension is the activity (or context) extension holding the category
+	CategoryExtension string
+	// RequireSuccess drops statements whose result reports success or
+	// completion as false
+	RequireSuccess bool
+}
+
+// Mapper converts xAPI statements into learning events
+type Mapper struct {
+	cfg Config
+}
+
+// NewMapper creates a new statement mapper
+func NewMapper(cfg Config) *Mapper {
+	if cfg.Verbs == nil {
+		cfg.Verbs = DefaultVerbMapping()
+	}
+	if cfg.CategoryExtension == "" {
+		cfg.CategoryExtension = DefaultCategoryExtension
+	}
+	return &Mapper{cfg: cfg}
+}
+
+// Validate checks that a statement has the properties required for mapping
+func (m *Mapper) Validate(stmt Statement) error {
+	if ActorID(stmt.Actor) == "" {
+		return fmt.Errorf("%w: actor has no identifier", ErrInvalidStatement)
+	}
+	if stmt.Verb.ID == "" {
+		return fmt.Errorf("%w: verb.id is required", ErrInvalidStatement)
+	}
+	if stmt.Object.ID == "" {
+		return fmt.Errorf("%w: object.id is required", ErrInvalidStatement)
+	}
+	return nil
+}
+
+// Map converts a statement into a learning event. It returns ErrUnmappedVerb
+// for verbs without a configured event type and ErrUnsuccessful for failed
+// outcomes when success is required; both mean the statement is valid but
+// should not be published.
+func (m *Mapper) Map(stmt Statement) (models.LearningEvent, error) {
+	if err := m.Validate(stmt); err != nil {
+		return models.LearningEvent{}, err
+	}
+
+	eventType, ok := m.eventType(stmt)
+	if !ok {
+		return models.LearningEvent{}, fmt.Errorf("%w: %s", ErrUnmappedVerb, stmt.Verb.ID)
+	}
+
+	if m.cfg.RequireSuccess && stmt.Result != nil {
+		if (stmt.Result.Success != nil && !*stmt.Result.Success) ||
+			(stmt.Result.Completion != nil && !*stmt.Result.Completion) {
+			return models.LearningEvent{}, ErrUnsuccessful
+		}
+	}
+
+	timestamp := time.Now().UTC()
+	if stmt.Timestamp != nil {
+		timestamp = *stmt.Timestamp
+	}
+
+	return models.LearningEvent{
+		UserID:    ActorID(stmt.Actor),
+		EventType: eventType,
+		CourseID:  iri.ID(stmt.Object.ID, m.cfg.ObjectIRIPrefix),
+		Category:  m.category(stmt),
+		Timestamp: timestamp,
+	}, nil
+}
+
+func (m *Mapper) eventType(stmt Statement) (string, bool) {
+	if stmt.Object.Definition != nil && stmt.Object.Definition.Type != "" {
+		if eventType, ok := m.cfg.Verbs[stmt.Verb.ID+"|"+stmt.Object.Definition.Type]; ok {
+			return eventType, true
+		}
+	}
+	eventType, ok := m.cfg.Verbs[stmt.Verb.ID]
+	return eventType, ok
+}
+
+func (m *Mapper) category(stmt Statement) string {
+	if stmt.Object.Definition != nil {
+		if category, ok := stmt.Object.Definition.Extensions[m.cfg.CategoryExtension].(string); ok {
+			return category
+		}
+	}
+	if stmt.Context != nil {
+		if category, ok := stmt.Context.Extensions[m.cfg.CategoryExtension].(string); ok {
+			return category
+		}
+	}
+	return ""
+}
+
+// ActorID returns the identifier used as user ID for an actor. Accounts are
+// preferred, followed by the inverse functional identifiers in spec order.
+func ActorID(actor Actor) string {
+	switch {
+	case actor.Account != nil && actor.Account.Name != "":
+		return actor.Account.Name
+	case actor.Mbox != "":
+		return strings.TrimPrefix(actor.Mbox, "mailto:")
+	case actor.MboxSHA1Sum != "":
+		return actor.MboxSHA1Sum
+	case actor.OpenID != "":
+		return actor.OpenID
+	}
+	return ""
+}
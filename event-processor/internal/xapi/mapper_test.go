@@ -0,0 +1,137 @@
+package xapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func completedCourse() Statement {
+	ts := time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC)
+	return Statement{
+		Actor: Actor{Account: &Account{HomePage: "https://lms.example.com", Name: "abc-123"}},
+		Verb:  Verb{ID: VerbCompleted},
+		Object: Object{
+			ID: "https://lms.example.com/courses/course-xyz",
+			Definition: &Definition{
+				Type:       ActivityCourse,
+				Extensions: map[string]interface{}{DefaultCategoryExtension: "MATH"},
+			},
+		},
+		Timestamp: &ts,
+	}
+}
+
+func TestMapperMap(t *testing.T) {
+	mapper := NewMapper(Config{RequireSuccess: true})
+
+	event, err := mapper.Map(completedCourse())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.UserID != "abc-123" || event.EventType != "COURSE_COMPLETED" ||
+		event.CourseID != "course-xyz" || event.Category != "MATH" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if !event.Timestamp.Equal(*completedCourse().Timestamp) {
+		t.Errorf("expected statement timestamp, got %v", event.Timestamp)
+	}
+}
+
+func TestMapperActivityTypeMapping(t *testing.T) {
+	stmt := completedCourse()
+	stmt.Object.Definition.Type = ActivityModule
+
+	event, err := NewMapper(Config{}).Map(stmt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "CHAPTER_COMPLETED" {
+		t.Errorf("expected CHAPTER_COMPLETED, got %s", event.EventType)
+	}
+}
+
+func TestMapperObjectIRIPrefix(t *testing.T) {
+	stmt := completedCourse()
+	stmt.Object.ID = "https://lms.example.com/courses/math/algebra-1"
+
+	event, err := NewMapper(Config{ObjectIRIPrefix: "https://lms.example.com/courses/"}).Map(stmt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.CourseID != "math/algebra-1" {
+		t.Errorf("expected prefix to be stripped, got %s", event.CourseID)
+	}
+}
+
+func TestMapperErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Statement)
+		want   error
+	}{
+		{
+			name:   "missing actor identifier",
+			mutate: func(s *Statement) { s.Actor = Actor{Name: "Jane"} },
+			want:   ErrInvalidStatement,
+		},
+		{
+			name:   "missing object id",
+			mutate: func(s *Statement) { s.Object.ID = "" },
+			want:   ErrInvalidStatement,
+		},
+		{
+			name:   "unmapped verb",
+			mutate: func(s *Statement) { s.Verb.ID = "http://adlnet.gov/expapi/verbs/launched" },
+			want:   ErrUnmappedVerb,
+		},
+		{
+			name:   "unsuccessful result",
+			mutate: func(s *Statement) { s.Result = &Result{Success: boolPtr(false)} },
+			want:   ErrUnsuccessful,
+		},
+	}
+
+	mapper := NewMapper(Config{RequireSuccess: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := completedCourse()
+			tt.mutate(&stmt)
+			if _, err := mapper.Map(stmt); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseVerbMapping(t *testing.T) {
+	mapping, err := ParseVerbMapping(VerbCompleted + "=COURSE_COMPLETED, " + VerbCompleted + "|" + ActivityModule + "=CHAPTER_COMPLETED")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mapping[VerbCompleted+"|"+ActivityModule] != "CHAPTER_COMPLETED" || mapping[VerbCompleted] != "COURSE_COMPLETED" {
+		t.Errorf("unexpected mapping: %v", mapping)
+	}
+
+	if _, err := ParseVerbMapping("missing-event-type="); err == nil {
+		t.Error("expected error for incomplete pair")
+	}
+}
+
+func TestDecodeStatements(t *testing.T) {
+	single, err := DecodeStatements([]byte(`{"actor":{"mbox":"mailto:a@example.com"},"verb":{"id":"v"},"object":{"id":"o"}}`))
+	if err != nil || len(single) != 1 || ActorID(single[0].Actor) != "a@example.com" {
+		t.Fatalf("unexpected single decode result: %v, %v", single, err)
+	}
+
+	batch, err := DecodeStatements([]byte(` [{"verb":{"id":"a"}},{"verb":{"id":"b"}}]`))
+	if err != nil || len(batch) != 2 {
+		t.Fatalf("unexpected batch decode result: %v, %v", batch, err)
+	}
+
+	if _, err := DecodeStatements([]byte(`[]`)); err == nil {
+		t.Error("expected error for empty batch")
+	}
+}
@@ -0,0 +1,98 @@
+package xapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Version is the xAPI specification version this service speaks
+const Version = "1.0.3"
+
+// Statement is the subset of an xAPI statement this service understands
+type Statement struct {
+	ID        string     `json:"id,omitempty"`
+	Actor     Actor      `json:"actor"`
+	Verb      Verb       `json:"verb"`
+	Object    Object     `json:"object"`
+	Result    *Result    `json:"result,omitempty"`
+	Context   *Context   `json:"context,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// Actor identifies who performed the statement
+type Actor struct {
+	ObjectType  string   `json:"objectType,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Mbox        string   `json:"mbox,omitempty"`
+	MboxSHA1Sum string   `json:"mbox_sha1sum,omitempty"`
+	OpenID      string   `json:"openid,omitempty"`
+	Account     *Account `json:"account,omitempty"`
+}
+
+// Account is an actor identifier scoped to a system
+type Account struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+// Verb describes the action of the statement
+type Verb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display,omitempty"`
+}
+
+// Object is the activity the statement is about
+type Object struct {
+	ObjectType string      `json:"objectType,omitempty"`
+	ID         string      `json:"id"`
+	Definition *Definition `json:"definition,omitempty"`
+}
+
+// Definition describes an activity
+type Definition struct {
+	Name       map[string]string      `json:"name,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Result holds the outcome of the statement
+type Result struct {
+	Success    *bool                  `json:"success,omitempty"`
+	Completion *bool                  `json:"completion,omitempty"`
+	Duration   string                 `json:"duration,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Context holds contextual information about the statement
+type Context struct {
+	Registration string                 `json:"registration,omitempty"`
+	Extensions   map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// DecodeStatements decodes a request body holding either a single statement
+// or an array of statements, as allowed by the statements resource
+func DecodeStatements(body []byte) ([]Statement, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("empty request body")
+	}
+
+	if body[0] == '[' {
+		var statements []Statement
+		if err := json.Unmarshal(body, &statements); err != nil {
+			return nil, err
+		}
+		if len(statements) == 0 {
+			return nil, errors.New("no statements in request body")
+		}
+		return statements, nil
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(body, &statement); err != nil {
+		return nil, err
+	}
+	return []Statement{statement}, nil
+}
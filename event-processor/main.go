@@ -1,22 +1,45 @@
 package main
 
 import (
+	"context"
+	"event-processor/internal/auth"
+	"event-processor/internal/caliper"
+	"event-processor/internal/config"
+	"event-processor/internal/database"
 	"event-processor/internal/logger"
 	"event-processor/internal/messaging/kafka"
+	"event-processor/internal/repository"
+	"event-processor/internal/schema"
 	"event-processor/internal/service"
 	"event-processor/internal/transport"
+	"event-processor/internal/xapi"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
 	"go.uber.org/zap"
 )
 
 const (
 	defaultPort         = "8081"
+	defaultGRPCPort     = "9081"
 	defaultKafkaBrokers = "localhost:29092"
 	defaultKafkaTopic   = "learning-events"
+	defaultPartitionKey = "user_id"
+
+	defaultCloudEventsSource = "/event-processor"
+
+	defaultClientRateLimit = "100"
+	defaultClientBurst     = "200"
+	defaultUserRateLimit   = "5"
+	defaultUserBurst       = "20"
 )
 
 func main() {
@@ -29,6 +52,18 @@ func main() {
 	log := logger.Get()
 	log.Info("starting event processor service")
 
+	shutdownTracing, err := tracing.Init(context.Background(), "event-processor")
+	if err != nil {
+		log.Fatal("failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	// Get Kafka configuration from environment variables
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
 	if kafkaBrokers == "" {
@@ -47,20 +82,220 @@ func main() {
 	}
 	defer producer.Close()
 
-	svc := service.NewEventService(producer)
-	server := transport.NewServer(svc)
+	// Key messages so that events of the same user stay ordered on one partition
+	partitionKey := os.Getenv("KAFKA_PARTITION_KEY")
+	if partitionKey == "" {
+		partitionKey = defaultPartitionKey
+	}
+	producer.SetKeyExtractor(kafka.KeyExtractorFor(partitionKey))
+
+	// Wrap messages in CloudEvents so consumers can route and version them
+	ceMode, err := kafka.ParseCloudEventsMode(os.Getenv("KAFKA_CLOUDEVENTS_MODE"))
+	if err != nil {
+		log.Fatal("invalid CloudEvents configuration", zap.Error(err))
+	}
+	ceSource := os.Getenv("KAFKA_CLOUDEVENTS_SOURCE")
+	if ceSource == "" {
+		ceSource = defaultCloudEventsSource
+	}
+	producer.SetCloudEvents(kafka.CloudEvents{
+		Mode:   ceMode,
+		Source: ceSource,
+		Type:   kafka.LearningEventType,
+	})
+
+	payloadFormat, err := schema.ParseFormat(os.Getenv("KAFKA_PAYLOAD_FORMAT"))
+	if err != nil {
+		log.Fatal("invalid payload format", zap.Error(err))
+	}
+	if err := producer.SetFormat(payloadFormat); err != nil {
+		log.Fatal("invalid payload format", zap.Error(err))
+	}
+
+	xapiMapper, err := newXAPIMapper()
+	if err != nil {
+		log.Fatal("invalid xAPI configuration", zap.Error(err))
+	}
+
+	caliperMapper, err := newCaliperMapper()
+	if err != nil {
+		log.Fatal("invalid Caliper configuration", zap.Error(err))
+	}
+
+	keys, err := newKeyStore()
+	if err != nil {
+		log.Fatal("invalid API keys configuration", zap.Error(err))
+	}
+
+	limiter, err := newLimiter()
+	if err != nil {
+		log.Fatal("invalid rate limit configuration", zap.Error(err))
+	}
+
+	// Every accepted event is stored in the audit log
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("invalid configuration", zap.Error(err))
+	}
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("failed to connect to database", zap.Error(err))
+	}
+	eventRepo := repository.NewGormEventRepository(db)
+
+	if cfg.EventRetention > 0 {
+		go service.NewRetention(eventRepo, cfg.EventRetention).Run(context.Background())
+	}
+
+	svc := service.NewEventService(producer, limiter, eventRepo)
+	serverCfg := transport.Config{
+		XAPIMapper:    xapiMapper,
+		CaliperMapper: caliperMapper,
+	}
+	if keys != nil {
+		serverCfg.Keys = keys
+	}
+	server := transport.NewServer(svc, serverCfg)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
 	}
 
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = defaultGRPCPort
+	}
+
+	// The gRPC server shares the event service, and thus the scopes and rate
+	// limits, of the HTTP server
+	var grpcKeys auth.Store
+	if keys != nil {
+		grpcKeys = keys
+	}
+	grpcServer := transport.NewGRPCServer(svc, grpcKeys)
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatal("failed to listen for gRPC", zap.String("port", grpcPort), zap.Error(err))
+	}
+	go func() {
+		if err := grpcServer.Server().Serve(lis); err != nil {
+			log.Fatal("gRPC server error", zap.Error(err))
+		}
+	}()
+
 	log.Info("event processor service started",
 		zap.String("port", port),
+		zap.String("grpc_port", grpcPort),
 		zap.String("kafka_topic", kafkaTopic),
+		zap.String("kafka_partition_key", partitionKey),
+		zap.String("kafka_cloudevents_mode", string(ceMode)),
+		zap.String("kafka_payload_format", string(payloadFormat)),
+		zap.Bool("auth_enabled", keys != nil),
+		zap.Duration("event_retention", cfg.EventRetention),
 	)
 
 	if err := http.ListenAndServe(":"+port, server.Router()); err != nil {
 		log.Fatal("server error", zap.Error(err))
 	}
 }
+
+// newXAPIMapper builds the xAPI statement mapper from environment variables
+func newXAPIMapper() (*xapi.Mapper, error) {
+	cfg := xapi.Config{
+		ObjectIRIPrefix:   os.Getenv("XAPI_OBJECT_IRI_PREFIX"),
+		CategoryExtension: os.Getenv("XAPI_CATEGORY_EXTENSION"),
+		RequireSuccess:    os.Getenv("XAPI_REQUIRE_SUCCESS") != "false",
+	}
+
+	if verbMap := os.Getenv("XAPI_VERB_MAP"); verbMap != "" {
+		verbs, err := xapi.ParseVerbMapping(verbMap)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Verbs = verbs
+	}
+
+	return xapi.NewMapper(cfg), nil
+}
+
+// newCaliperMapper builds the Caliper event mapper from environment variables
+func newCaliperMapper() (*caliper.Mapper, error) {
+	cfg := caliper.Config{
+		ActorIDPrefix:     os.Getenv("CALIPER_ACTOR_ID_PREFIX"),
+		CourseIDPrefix:    os.Getenv("CALIPER_COURSE_ID_PREFIX"),
+		CategoryExtension: os.Getenv("CALIPER_CATEGORY_EXTENSION"),
+	}
+
+	if eventMap := os.Getenv("CALIPER_EVENT_MAP"); eventMap != "" {
+		events, err := caliper.ParseMappingTable(eventMap)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Events = events
+	}
+
+	return caliper.NewMapper(cfg), nil
+}
+
+// newKeyStore loads the API keys file and reloads it on SIGHUP. It returns nil
+// when authentication is explicitly disabled.
+func newKeyStore() (*auth.FileStore, error) {
+	log := logger.Get()
+
+	path := os.Getenv("API_KEYS_FILE")
+	if path == "" {
+		if os.Getenv("AUTH_DISABLED") == "true" {
+			log.Warn("API key authentication is disabled, ingestion endpoints are open")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("API_KEYS_FILE is required unless AUTH_DISABLED=true")
+	}
+
+	store, err := auth.NewFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("loaded API keys", zap.String("path", path), zap.Int("clients", store.Len()))
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := store.Reload(); err != nil {
+				log.Error("failed to reload API keys, keeping the previous ones", zap.Error(err))
+				continue
+			}
+			log.Info("reloaded API keys", zap.Int("clients", store.Len()))
+		}
+	}()
+
+	return store, nil
+}
+
+// newLimiter builds the rate limiter from environment variables. A rate of 0
+// disables the corresponding limit.
+func newLimiter() (*auth.Limiter, error) {
+	var cfg auth.LimiterConfig
+	var err error
+	if cfg.ClientRate, err = strconv.ParseFloat(getEnv("RATE_LIMIT_CLIENT_RPS", defaultClientRateLimit), 64); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_CLIENT_RPS: %w", err)
+	}
+	if cfg.ClientBurst, err = strconv.Atoi(getEnv("RATE_LIMIT_CLIENT_BURST", defaultClientBurst)); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_CLIENT_BURST: %w", err)
+	}
+	if cfg.UserRate, err = strconv.ParseFloat(getEnv("RATE_LIMIT_USER_RPS", defaultUserRateLimit), 64); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_USER_RPS: %w", err)
+	}
+	if cfg.UserBurst, err = strconv.Atoi(getEnv("RATE_LIMIT_USER_BURST", defaultUserBurst)); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_USER_BURST: %w", err)
+	}
+	return auth.NewLimiter(cfg), nil
+}
+
+func getEnv(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
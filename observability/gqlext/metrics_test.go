@@ -0,0 +1,24 @@
+package gqlext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+func TestRootFieldIgnoresClientNames(t *testing.T) {
+	for query, want := range map[string]string{
+		`query AnyName { rules { id } }`:                           "rules",
+		`mutation Other { renamed: createRule(input: {}) { id } }`: "createRule",
+		`query { __typename rules { id } }`:                        "rules",
+		`query { rules { id } rule(id: "1") { id } }`:              "multiple",
+		`query { ... on Query { rules { id } } }`:                  "other",
+	} {
+		doc, err := parser.ParseQuery(&ast.Source{Input: query})
+		require.NoError(t, err)
+		assert.Equal(t, want, rootField(doc.Operations[0]), query)
+	}
+}
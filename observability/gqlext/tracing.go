@@ -0,0 +1,56 @@
+package gqlext
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is a gqlgen extension starting a span for each GraphQL operation
+type Tracing struct{}
+
+var (
+	_ graphql.HandlerExtension    = Tracing{}
+	_ graphql.ResponseInterceptor = Tracing{}
+)
+
+// ExtensionName implements graphql.HandlerExtension
+func (Tracing) ExtensionName() string {
+	return "Tracing"
+}
+
+// Validate implements graphql.HandlerExtension
+func (Tracing) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse implements graphql.ResponseInterceptor
+func (Tracing) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	operation, opType := "anonymous", "unknown"
+	if graphql.HasOperationContext(ctx) {
+		oc := graphql.GetOperationContext(ctx)
+		if oc.OperationName != "" {
+			operation = oc.OperationName
+		}
+		if oc.Operation != nil {
+			opType = string(oc.Operation.Operation)
+		}
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "graphql "+opType+" "+operation,
+		trace.WithAttributes(
+			attribute.String("graphql.operation.name", operation),
+			attribute.String("graphql.operation.type", opType),
+		))
+	defer span.End()
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
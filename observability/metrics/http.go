@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/observability/internal/httpx"
+	"github.com/gorilla/mux"
+)
+
+// Middleware records the rate and latency of the requests of a mux router,
+// labeled by route template rather than path so that IDs in paths do not
+// multiply the series
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		start := time.Now()
+		rec := httpx.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).Inc()
+		HTTPRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
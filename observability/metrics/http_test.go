@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues("/rules/{id}", http.MethodGet, "404"))
+	for _, id := range []string{"a", "b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/rules/"+id, nil))
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(HTTPRequests.WithLabelValues("/rules/{id}", http.MethodGet, "404")))
+}
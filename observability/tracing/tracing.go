@@ -0,0 +1,122 @@
+// Package tracing sets up OpenTelemetry tracing and propagates trace
+// contexts over HTTP, Kafka headers and stored values.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the service, set by Init
+var instrumentationName = "github.com/alexandredsa/learning-rewards/observability/tracing"
+
+// Tracer returns the tracer of the service. It is a no-op until Init installs
+// an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs the W3C trace context propagator and, when
+// OTEL_TRACES_EXPORTER is "otlp", a tracer provider exporting spans over
+// OTLP/HTTP. The exporter is configured by the standard OTEL_EXPORTER_OTLP_*
+// variables, the sampler by OTEL_TRACES_SAMPLER and the service name by
+// OTEL_SERVICE_NAME, which defaults to service. The returned function flushes
+// and stops the provider.
+func Init(ctx context.Context, service string) (func(context.Context) error, error) {
+	instrumentationName = service
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, must be otlp or none", exporter)
+	}
+
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Attributes from the environment take precedence
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Inject writes the trace context of ctx to carrier
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the trace context read from carrier
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Serialize returns the trace context of ctx in a form that can be stored,
+// or "" if ctx has none
+func Serialize(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(carrier)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Deserialize returns ctx with the trace context stored by Serialize. An
+// empty or invalid value leaves ctx unchanged.
+func Deserialize(ctx context.Context, value string) context.Context {
+	if value == "" {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if err := json.Unmarshal([]byte(value), &carrier); err != nil {
+		return ctx
+	}
+	return Extract(ctx, carrier)
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
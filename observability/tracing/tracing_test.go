@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInitIsNoopByDefault(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+
+	shutdown, err := Init(context.Background(), "observability-test")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, span := Tracer().Start(context.Background(), "noop")
+	assert.False(t, span.SpanContext().IsValid())
+}
+
+func TestInitOTLP(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://127.0.0.1:1")
+
+	shutdown, err := Init(context.Background(), "observability-test")
+	require.NoError(t, err)
+
+	_, span := Tracer().Start(context.Background(), "exported")
+	assert.True(t, span.SpanContext().IsValid())
+	span.End()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = shutdown(ctx)
+}
+
+func TestInitRejectsUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+
+	_, err := Init(context.Background(), "observability-test")
+	assert.Error(t, err)
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	_, err := Init(context.Background(), "observability-test")
+	require.NoError(t, err)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	value := Serialize(trace.ContextWithSpanContext(context.Background(), sc))
+	require.NotEmpty(t, value)
+
+	restored := trace.SpanContextFromContext(Deserialize(context.Background(), value))
+	assert.Equal(t, sc.TraceID(), restored.TraceID())
+	assert.Equal(t, sc.SpanID(), restored.SpanID())
+	assert.True(t, restored.IsRemote())
+
+	assert.Empty(t, Serialize(context.Background()))
+	assert.Equal(t, context.Background(), Deserialize(context.Background(), "not json"))
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/graph/resolver"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/catalog"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/database"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/server"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
 	"go.uber.org/zap"
@@ -18,6 +25,13 @@ import (
 const (
 	defaultPort     = "8100"
 	shutdownTimeout = 10 * time.Second
+	// healthCheckTimeout bounds each dependency check of the readiness probe
+	healthCheckTimeout = 2 * time.Second
+	// catalogTimeout bounds the catalog requests of the rule analysis
+	catalogTimeout = 5 * time.Second
+	// catalogTTL is how long the catalog, or the failure to fetch it, is
+	// reused by the rule analysis
+	catalogTTL = time.Minute
 )
 
 func getPort() string {
@@ -34,6 +48,25 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// newAuthenticator configures the validation of the JWTs of the API from
+// AUTH_JWT_SECRET, AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE. Without a secret,
+// authentication is disabled if AUTH_DISABLED is true and the API refuses to
+// start otherwise.
+func newAuthenticator() (*auth.Authenticator, error) {
+	secret := os.Getenv("AUTH_JWT_SECRET")
+	if secret == "" {
+		if getEnv("AUTH_DISABLED", "false") == "true" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("AUTH_JWT_SECRET is required unless AUTH_DISABLED is true")
+	}
+	return auth.NewAuthenticator(auth.Config{
+		Secret:   []byte(secret),
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	})
+}
+
 func main() {
 	// Initialize logger
 	if err := logger.Initialize(logger.Config{
@@ -51,6 +84,18 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	shutdownTracing, err := tracing.Init(context.Background(), "reward-processor-api")
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	// Connect to database using DSN from environment variable (or default from database package)
 	db, err := database.Connect(os.Getenv("DATABASE_DSN"))
 	if err != nil {
@@ -63,14 +108,39 @@ func main() {
 	// Get port from environment variable or use default
 	port := getPort()
 
+	// The engine explains rules against the stored counts; it evaluates no
+	// event
+	res := resolver.NewResolver(ruleRepo, log)
+	res.Engine = rules.NewEngine(nil, repository.NewGormUserEventRepository(db), log)
+	res.Ledger = repository.NewGormRewardLedgerRepository(db)
+	// Rules are analyzed against the catalog of catalog-api; an empty
+	// CATALOG_API_URL leaves categories unchecked
+	if catalogURL := getEnv("CATALOG_API_URL", "http://catalog-api:8080"); catalogURL != "" {
+		res.Catalog = catalog.NewCache(&catalog.Client{
+			BaseURL: catalogURL,
+			Client:  &http.Client{Timeout: catalogTimeout},
+		}, catalogTTL)
+	}
+
+	checker := health.New(healthCheckTimeout)
+	checker.Add("postgres", database.Check(db))
+	checker.Add("rules", rules.StoreCheck(ruleRepo))
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		log.Fatal("Failed to configure authentication", zap.Error(err))
+	}
+
 	// Create and start server
 	srv := server.New(server.Config{
-		Port: port,
+		Port:   port,
+		Health: checker,
+		Auth:   authenticator,
 	})
 
 	// Start server in a goroutine
 	go func() {
-		if err := srv.Start(ruleRepo); err != nil && err != http.ErrServerClosed {
+		if err := srv.Start(res); err != nil && err != http.ErrServerClosed {
 			log.Error("Failed to start server", zap.Error(err))
 			os.Exit(1)
 		}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/database"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/replay"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func getEnv(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
+// parseTime parses an optional RFC 3339 flag value
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Get().Fatal("Invalid time flag", zap.String("flag", name), zap.Error(err))
+	}
+	return t
+}
+
+// workerOffsets returns the offsets the worker resumes from. Offsets stored in
+// the database take precedence over those committed to Kafka, as the worker
+// does; once some are stored, partitions without one were never processed.
+func workerOffsets(ctx context.Context, offsets repository.OffsetRepository, group, topic string, committed map[int32]int64) (map[int32]int64, error) {
+	stored, err := offsets.Offsets(ctx, group, topic)
+	if err != nil || len(stored) == 0 {
+		return committed, err
+	}
+	end := make(map[int32]int64, len(committed))
+	for partition := range committed {
+		end[partition] = stored[partition]
+	}
+	return end, nil
+}
+
+// partialReplay reports whether the replay starts after the beginning of the
+// history
+func partialReplay(source string, fromOffset int64, fromTime string) bool {
+	return fromTime != "" || source == "kafka" && fromOffset != sarama.OffsetOldest
+}
+
+// flagSet reports whether the flag was passed on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func main() {
+	var (
+		source     = flag.String("source", "kafka", "event history to replay: kafka or archive")
+		modeFlag   = flag.String("mode", string(replay.ModeCountsOnly), "counts-only, no-emit or emit-missing")
+		fromOffset = flag.Int64("from-offset", sarama.OffsetOldest, "kafka: first offset of every partition (-2 for the oldest retained)")
+		fromTime   = flag.String("from-time", "", "first event time, RFC 3339; kafka: overrides -from-offset")
+		until      = flag.String("until", "group", "kafka: replay up to the committed offsets of the consumer group (group) or the end of the topic (latest)")
+		toTime     = flag.String("to-time", "", "archive: end of the replay (exclusive), RFC 3339")
+		archiveURL = flag.String("archive-url", getEnv("EVENT_ARCHIVE_URL", "http://event-processor:8081"), "archive: event-processor address")
+		swap       = flag.Bool("swap", true, "swap the rebuilt counts in; otherwise they are left in "+repository.ShadowUserEventCountsTable+". Defaults to false, and cannot be set, with -from-offset or -from-time")
+		force      = flag.Bool("force", false, "swap even if the consumer group has active members")
+	)
+	flag.Parse()
+
+	if err := logger.Initialize(logger.Config{
+		Level:      getEnv("LOG_LEVEL", "info"),
+		Production: getEnv("ENV", "development") == "production",
+	}); err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	defer logger.Sync()
+
+	log := logger.Get()
+
+	mode, err := replay.ParseMode(*modeFlag)
+	if err != nil {
+		log.Fatal("Invalid replay mode", zap.Error(err))
+	}
+	if *until != "group" && *until != "latest" {
+		log.Fatal("Invalid -until, must be group or latest", zap.String("until", *until))
+	}
+
+	// The shadow table starts empty: counts rebuilt from part of the history
+	// would replace the live counts of every earlier event
+	if partialReplay(*source, *fromOffset, *fromTime) {
+		if flagSet("swap") && *swap {
+			log.Fatal("Cannot swap in the counts of a replay starting at -from-offset or -from-time, which miss the earlier events")
+		}
+		*swap = false
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	db, err := database.Connect(getEnv("DATABASE_DSN", ""))
+	if err != nil {
+		log.Fatal("Failed to initialize database", zap.Error(err))
+	}
+
+	rules, err := repository.NewGormRuleRepository(db).GetActiveRules(ctx)
+	if err != nil {
+		log.Fatal("Failed to get rules", zap.Error(err))
+	}
+
+	brokers := strings.Split(getEnv("KAFKA_BROKERS", "localhost:29092"), ",")
+	group := getEnv("KAFKA_CONSUMER_GROUP", "reward-processor")
+	topic := strings.Split(getEnv("KAFKA_CONSUMER_TOPICS", "learning-events"), ",")[0]
+
+	reader, err := kafka.NewReader(brokers, topic)
+	if err != nil {
+		log.Fatal("Failed to create Kafka reader", zap.Error(err))
+	}
+	defer reader.Close()
+
+	// The worker must be stopped: events it processes while the replay runs
+	// would be lost by the swap
+	committed, active, err := reader.GroupOffsets(group)
+	if err != nil {
+		log.Fatal("Failed to fetch consumer group offsets", zap.Error(err))
+	}
+	if active && *swap && !*force {
+		log.Fatal("Consumer group has active members, stop the worker or pass -force", zap.String("group", group))
+	}
+
+	var events replay.Source
+	switch *source {
+	case "kafka":
+		opts := kafka.ReadOptions{StartOffset: *fromOffset, StartTime: parseTime("from-time", *fromTime)}
+		if *until == "group" {
+			// Stopping where the worker stopped lets it resume from its
+			// offsets without counting any event twice
+			end, err := workerOffsets(ctx, repository.NewGormOffsetRepository(db), group, topic, committed)
+			if err != nil {
+				log.Fatal("Failed to load stored consumer offsets", zap.Error(err))
+			}
+			opts.EndOffsets = end
+		}
+		events = &replay.KafkaSource{Reader: reader, Options: opts}
+	case "archive":
+		events = &replay.ArchiveSource{
+			BaseURL: *archiveURL,
+			APIKey:  getEnv("EVENT_ARCHIVE_API_KEY", ""),
+			From:    parseTime("from-time", *fromTime),
+			To:      parseTime("to-time", *toTime),
+			Client:  &http.Client{Timeout: 30 * time.Second},
+		}
+	default:
+		log.Fatal("Invalid -source, must be kafka or archive", zap.String("source", *source))
+	}
+
+	shadow, err := repository.CreateShadowCounts(ctx, db)
+	if err != nil {
+		log.Fatal("Failed to create shadow table", zap.Error(err))
+	}
+
+	replayer, err := replay.New(mode, rules, shadow, repository.NewGormRewardLedgerRepository(db), repository.NewGormOutboxRepository(db), log)
+	if err != nil {
+		log.Fatal("Failed to create replayer", zap.Error(err))
+	}
+
+	log.Info("Starting replay",
+		zap.String("source", *source),
+		zap.String("mode", string(mode)),
+		zap.Int("rules", len(rules)))
+	result, err := replayer.Run(ctx, events)
+	if err != nil {
+		if dropErr := repository.DropShadowCounts(context.Background(), db); dropErr != nil {
+			log.Error("Failed to drop shadow table", zap.Error(dropErr))
+		}
+		log.Fatal("Replay failed", zap.Error(err))
+	}
+	log.Info("Replay finished",
+		zap.Int("events", result.Events),
+		zap.Int("rewards_triggered", result.Triggered),
+		zap.Int("rewards_missing", result.Missing),
+		zap.Int("rewards_emitted", result.Emitted))
+
+	if !*swap {
+		log.Info("Rebuilt counts left in shadow table", zap.String("table", repository.ShadowUserEventCountsTable))
+		return
+	}
+
+	if _, active, err := reader.GroupOffsets(group); err != nil {
+		log.Fatal("Failed to check consumer group", zap.Error(err))
+	} else if active && !*force {
+		log.Fatal("Consumer group became active during the replay, counts left in shadow table",
+			zap.String("group", group),
+			zap.String("table", repository.ShadowUserEventCountsTable))
+	}
+
+	offsets := repository.NewGormOffsetRepository(db)
+	err = repository.NewGormTransactor(db).RunInTx(ctx, func(ctx context.Context) error {
+		if err := repository.SwapShadowCounts(ctx, db); err != nil {
+			return err
+		}
+		kafkaSource, ok := events.(*replay.KafkaSource)
+		if !ok {
+			return nil
+		}
+		// The counts now cover every message before the replay's end
+		// offsets: the worker resumes from there, and messages it processed
+		// past them must be processed again
+		for partition, end := range kafkaSource.EndOffsets() {
+			if err := offsets.Save(ctx, group, topic, partition, end); err != nil {
+				return err
+			}
+		}
+		return offsets.ClearProcessed(ctx, group, topic)
+	})
+	if err != nil {
+		log.Fatal("Failed to swap counts", zap.Error(err))
+	}
+	log.Info("Swapped rebuilt counts in", zap.String("table", repository.UserEventCountsTable))
+}
@@ -0,0 +1,209 @@
+// Command rulesctl keeps the rules in a rules file, reviewed in git, and
+// applies the file to the database:
+//
+//	rulesctl export -o rules.yaml
+//	rulesctl validate rules.yaml
+//	rulesctl diff rules.yaml
+//	rulesctl apply -comment "$(git rev-parse HEAD)" rules.yaml
+//
+// Rules are matched by ID. Applying creates the rules missing from the
+// database, updates the ones that differ and archives the ones missing from
+// the file, so that applying a file twice changes nothing the second time.
+// Every command but validate connects to DATABASE_DSN.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/audit"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/database"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rulesfile"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+const usage = `usage: rulesctl <command> [flags]
+
+commands:
+  export [-format yaml|json] [-o file]    write the rules that are not archived
+  validate <file>                         check a rules file
+  diff <file>                             show the changes applying a file would make
+  apply [-comment text] [-author name] <file>
+                                          make the rules match a file
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "export":
+		err = export(ctx, args)
+	case "validate":
+		err = validate(args)
+	case "diff":
+		err = syncRules(ctx, "diff", args)
+	case "apply":
+		err = syncRules(ctx, "apply", args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rulesctl:", err)
+		os.Exit(1)
+	}
+}
+
+func export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatArg := flags.String("format", "", "yaml or json; by default, from the extension of -o, or yaml")
+	output := flags.String("o", "", "file to write; by default, standard output")
+	flags.Parse(args)
+
+	format := rulesfile.FormatOf(*output)
+	if *formatArg != "" {
+		var err error
+		if format, err = rulesfile.ParseFormat(*formatArg); err != nil {
+			return err
+		}
+	}
+
+	repo, err := connect()
+	if err != nil {
+		return err
+	}
+	rules, err := repo.GetUnarchivedRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch rules: %w", err)
+	}
+	data, err := rulesfile.Marshal(rulesfile.FromModels(rules), format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
+
+func validate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.Parse(args)
+
+	rules, err := readFile(flags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d rules OK\n", flags.Arg(0), len(rules))
+	return nil
+}
+
+// syncRules diffs or applies a rules file
+func syncRules(ctx context.Context, command string, args []string) error {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	comment := flags.String("comment", "", "apply: comment recorded on the versions of the changed rules, e.g. a commit")
+	author := flags.String("author", defaultAuthor(), "apply: author recorded on the versions of the changed rules")
+	flags.Parse(args)
+
+	rules, err := readFile(flags)
+	if err != nil {
+		return err
+	}
+	repo, err := connect()
+	if err != nil {
+		return err
+	}
+
+	dryRun := command == "diff"
+	changes, err := repo.SyncRules(audit.WithAuthor(ctx, *author), rules, dryRun, *comment)
+	if err != nil {
+		return err
+	}
+	printChanges(os.Stdout, changes)
+	if !dryRun && len(changes) > 0 {
+		fmt.Printf("Applied %d changes\n", len(changes))
+	}
+	return nil
+}
+
+// readFile reads and validates the rules file named by the first argument
+func readFile(flags *flag.FlagSet) ([]models.Rule, error) {
+	if flags.NArg() != 1 {
+		return nil, fmt.Errorf("%s takes a rules file", flags.Name())
+	}
+	path := flags.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := rulesfile.Parse(data, rulesfile.FormatOf(path))
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s:\n%w", path, err)
+	}
+	return file.Models(), nil
+}
+
+func connect() (*repository.GormRuleRepository, error) {
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		return nil, errors.New("DATABASE_DSN is required")
+	}
+	db, err := database.Connect(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewGormRuleRepository(db), nil
+}
+
+func defaultAuthor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "rulesctl"
+}
+
+// printChanges prints changes as a diff: + for created rules, ~ for updated
+// ones and - for archived ones, each followed by its changed fields
+func printChanges(w io.Writer, changes []repository.RuleSyncChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+	marks := map[repository.RuleSyncAction]string{
+		repository.RuleSyncCreate:  "+",
+		repository.RuleSyncUpdate:  "~",
+		repository.RuleSyncDisable: "-",
+	}
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s %s (%s)\n", marks[change.Action], change.RuleID, change.Action)
+		for _, field := range change.Diff {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, orNull(field.From), orNull(field.To))
+		}
+	}
+}
+
+func orNull(s *string) string {
+	if s == nil {
+		return "null"
+	}
+	return *s
+}
@@ -0,0 +1,52 @@
+// Command token issues a JWT for the rules API, signed with AUTH_JWT_SECRET.
+// It is meant for development and for service accounts:
+//
+//	go run ./cmd/token -sub alice -role EDITOR
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+)
+
+func main() {
+	var (
+		subject = flag.String("sub", "", "subject of the token, recorded as the author of rule changes")
+		roleArg = flag.String("role", string(auth.Viewer), "VIEWER, EDITOR or ADMIN")
+		ttl     = flag.Duration("ttl", 24*time.Hour, "validity of the token")
+	)
+	flag.Parse()
+
+	if err := run(*subject, *roleArg, *ttl); err != nil {
+		fmt.Fprintln(os.Stderr, "token:", err)
+		os.Exit(1)
+	}
+}
+
+func run(subject, roleArg string, ttl time.Duration) error {
+	if subject == "" {
+		return fmt.Errorf("-sub is required")
+	}
+	role, err := auth.ParseRole(roleArg)
+	if err != nil {
+		return err
+	}
+	authenticator, err := auth.NewAuthenticator(auth.Config{
+		Secret:   []byte(os.Getenv("AUTH_JWT_SECRET")),
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	})
+	if err != nil {
+		return err
+	}
+	token, err := authenticator.Issue(auth.Principal{Subject: subject, Role: role}, ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
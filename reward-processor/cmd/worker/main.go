@@ -2,19 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/countcache"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/database"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/database/seed"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/outbox"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/processor"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/schema"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"go.uber.org/zap"
 )
 
+// healthCheckTimeout bounds each dependency check of the readiness probe
+const healthCheckTimeout = 2 * time.Second
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -22,6 +39,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
+
 func main() {
 	// Initialize logger
 	if err := logger.Initialize(logger.Config{
@@ -34,6 +59,18 @@ func main() {
 
 	log := logger.Get()
 
+	shutdownTracing, err := tracing.Init(context.Background(), "reward-processor-worker")
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	// Initialize database
 	db, err := database.Connect(getEnv("DATABASE_DSN", ""))
 	if err != nil {
@@ -41,7 +78,14 @@ func main() {
 	}
 
 	// Create repositories
-	eventRepo := repository.NewGormUserEventRepository(db)
+	repos := processor.Repositories{
+		Events:      repository.NewGormUserEventRepository(db),
+		Ledger:      repository.NewGormRewardLedgerRepository(db),
+		Offsets:     repository.NewGormOffsetRepository(db),
+		Outbox:      repository.NewGormOutboxRepository(db),
+		DeadLetters: repository.NewGormDeadLetterRepository(db),
+		Tx:          repository.NewGormTransactor(db),
+	}
 	ruleRepo := repository.NewGormRuleRepository(db)
 
 	// Seed rules if needed
@@ -50,23 +94,83 @@ func main() {
 		log.Fatal("Failed to seed rules", zap.Error(err))
 	}
 
-	// Get enabled rules
-	rules, err := ruleRepo.GetEnabledRules(ctx)
+	// Get active rules
+	rules, err := ruleRepo.GetActiveRules(ctx)
 	if err != nil {
 		log.Fatal("Failed to get rules", zap.Error(err))
 	}
 
+	ceMode, err := kafka.ParseCloudEventsMode(getEnv("KAFKA_CLOUDEVENTS_MODE", ""))
+	if err != nil {
+		log.Fatal("Invalid CloudEvents configuration", zap.Error(err))
+	}
+
+	payloadFormat, err := schema.ParseFormat(getEnv("KAFKA_PAYLOAD_FORMAT", ""))
+	if err != nil {
+		log.Fatal("Invalid payload format", zap.Error(err))
+	}
+
+	outboxCfg := outbox.DefaultConfig()
+	if outboxCfg.PollInterval, err = getDuration("OUTBOX_POLL_INTERVAL", outboxCfg.PollInterval); err != nil {
+		log.Fatal("Invalid outbox poll interval", zap.Error(err))
+	}
+	if outboxCfg.Retention, err = getDuration("OUTBOX_RETENTION", outboxCfg.Retention); err != nil {
+		log.Fatal("Invalid outbox retention", zap.Error(err))
+	}
+
+	workers, err := strconv.Atoi(getEnv("KAFKA_WORKERS", "16"))
+	if err != nil || workers <= 0 {
+		log.Fatal("Invalid KAFKA_WORKERS, must be a positive integer", zap.String("value", getEnv("KAFKA_WORKERS", "")))
+	}
+	queueSize, err := strconv.Atoi(getEnv("KAFKA_WORKER_QUEUE_SIZE", "64"))
+	if err != nil || queueSize < 0 {
+		log.Fatal("Invalid KAFKA_WORKER_QUEUE_SIZE, must be a non-negative integer", zap.String("value", getEnv("KAFKA_WORKER_QUEUE_SIZE", "")))
+	}
+
+	ruleReload, err := getDuration("RULES_RELOAD_INTERVAL", 30*time.Second)
+	if err != nil || ruleReload < 0 {
+		log.Fatal("Invalid RULES_RELOAD_INTERVAL, must be a non-negative duration", zap.String("value", getEnv("RULES_RELOAD_INTERVAL", "")))
+	}
+
+	retryCfg := kafka.DefaultRetryConfig()
+	if retryCfg.MaxAttempts, err = strconv.Atoi(getEnv("KAFKA_MAX_ATTEMPTS", strconv.Itoa(retryCfg.MaxAttempts))); err != nil || retryCfg.MaxAttempts <= 0 {
+		log.Fatal("Invalid KAFKA_MAX_ATTEMPTS, must be a positive integer", zap.String("value", getEnv("KAFKA_MAX_ATTEMPTS", "")))
+	}
+	if retryCfg.StuckAfter, err = getDuration("KAFKA_STUCK_AFTER", retryCfg.StuckAfter); err != nil || retryCfg.StuckAfter <= 0 {
+		log.Fatal("Invalid KAFKA_STUCK_AFTER, must be a positive duration", zap.String("value", getEnv("KAFKA_STUCK_AFTER", "")))
+	}
+
+	countCacheCfg := countcache.DefaultConfig()
+	if countCacheCfg.Size, err = strconv.Atoi(getEnv("COUNT_CACHE_SIZE", "0")); err != nil || countCacheCfg.Size < 0 {
+		log.Fatal("Invalid COUNT_CACHE_SIZE, must be a non-negative integer", zap.String("value", getEnv("COUNT_CACHE_SIZE", "")))
+	}
+	if countCacheCfg.FlushInterval, err = getDuration("COUNT_CACHE_FLUSH_INTERVAL", countCacheCfg.FlushInterval); err != nil || countCacheCfg.FlushInterval <= 0 {
+		log.Fatal("Invalid COUNT_CACHE_FLUSH_INTERVAL, must be a positive duration", zap.String("value", getEnv("COUNT_CACHE_FLUSH_INTERVAL", "")))
+	}
+	if countCacheCfg.FlushSize, err = strconv.Atoi(getEnv("COUNT_CACHE_FLUSH_SIZE", strconv.Itoa(countCacheCfg.FlushSize))); err != nil || countCacheCfg.FlushSize <= 0 {
+		log.Fatal("Invalid COUNT_CACHE_FLUSH_SIZE, must be a positive integer", zap.String("value", getEnv("COUNT_CACHE_FLUSH_SIZE", "")))
+	}
+
 	// Get configuration from environment
 	cfg := processor.Config{
-		KafkaBrokers:   strings.Split(getEnv("KAFKA_BROKERS", "localhost:29092"), ","),
-		ConsumerGroup:  getEnv("KAFKA_CONSUMER_GROUP", "reward-processor"),
-		ConsumerTopics: strings.Split(getEnv("KAFKA_CONSUMER_TOPICS", "learning-events"), ","),
-		ProducerTopic:  getEnv("KAFKA_PRODUCER_TOPIC", "user-rewards"),
-		Rules:          rules,
+		KafkaBrokers:      strings.Split(getEnv("KAFKA_BROKERS", "localhost:29092"), ","),
+		ConsumerGroup:     getEnv("KAFKA_CONSUMER_GROUP", "reward-processor"),
+		ConsumerTopics:    strings.Split(getEnv("KAFKA_CONSUMER_TOPICS", "learning-events"), ","),
+		ProducerTopic:     getEnv("KAFKA_PRODUCER_TOPIC", "user-rewards"),
+		Rules:             rules,
+		RuleSource:        ruleRepo.GetActiveRules,
+		RuleReload:        ruleReload,
+		CloudEventsMode:   ceMode,
+		CloudEventsSource: getEnv("KAFKA_CLOUDEVENTS_SOURCE", "/reward-processor"),
+		PayloadFormat:     payloadFormat,
+		Outbox:            outboxCfg,
+		Pool:              kafka.PoolConfig{Workers: workers, QueueSize: queueSize},
+		CountCache:        countCacheCfg,
+		Retry:             retryCfg,
 	}
 
 	// Create processor
-	proc, err := processor.New(cfg, eventRepo, log)
+	proc, err := processor.New(cfg, repos, log)
 	if err != nil {
 		log.Fatal("Failed to create processor", zap.Error(err))
 	}
@@ -86,8 +190,45 @@ func main() {
 		cancel()
 	}()
 
+	registerPoolMetrics(proc)
+
+	checker := health.New(healthCheckTimeout)
+	checker.Add("postgres", database.Check(db))
+	proc.RegisterChecks(checker)
+
+	// Serve metrics and health checks
+	router := mux.NewRouter()
+	router.Handle("/metrics", metrics.Handler())
+	checker.Register(router)
+	httpServer := &http.Server{
+		Addr:    ":" + getEnv("PORT", "8083"),
+		Handler: router,
+	}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("HTTP server error", zap.Error(err))
+		}
+	}()
+	defer httpServer.Close()
+
 	// Start processing
 	if err := proc.Start(ctx); err != nil {
 		log.Fatal("Processor error", zap.Error(err))
 	}
 }
+
+// registerPoolMetrics exposes the worker pool stats
+func registerPoolMetrics(proc *processor.Processor) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "kafka_worker_pool_busy",
+		Help: "Workers handling an event.",
+	}, func() float64 { return float64(proc.PoolStats().Busy) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "kafka_worker_pool_queued",
+		Help: "Events waiting for a worker.",
+	}, func() float64 { return float64(proc.PoolStats().Queued) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "kafka_worker_pool_saturation",
+		Help: "Share of the workers and queue slots in use, from 0 to 1.",
+	}, func() float64 { return proc.PoolStats().Saturation() })
+}
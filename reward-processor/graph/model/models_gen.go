@@ -7,19 +7,41 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
+type ConditionCheck struct {
+	// enabled (the rule is ACTIVE), eventType, category or count
+	Condition string `json:"condition"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual"`
+	Satisfied bool   `json:"satisfied"`
+}
+
 type CreateRuleInput struct {
 	EventType  string               `json:"eventType"`
 	Count      *int                 `json:"count,omitempty"`
 	Conditions *RuleConditionsInput `json:"conditions,omitempty"`
 	Reward     *RewardInput         `json:"reward"`
-	Enabled    bool                 `json:"enabled"`
+}
+
+type FieldChange struct {
+	// Field name, e.g. reward.amount
+	Field string `json:"field"`
+	// Null when the field was not set
+	From *string `json:"from,omitempty"`
+	To   *string `json:"to,omitempty"`
 }
 
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
 type Query struct {
 }
 
@@ -41,7 +63,28 @@ type Rule struct {
 	Count      *int            `json:"count,omitempty"`
 	Conditions *RuleConditions `json:"conditions,omitempty"`
 	Reward     *Reward         `json:"reward"`
-	Enabled    bool            `json:"enabled"`
+	Status     RuleStatus      `json:"status"`
+	// True while the rule is ACTIVE
+	Enabled  bool `json:"enabled"`
+	Archived bool `json:"archived"`
+	// RFC 3339
+	ArchivedAt *string `json:"archivedAt,omitempty"`
+	// RFC 3339
+	CreatedAt string `json:"createdAt"`
+	// Incremented on every change; see ruleHistory
+	Version int `json:"version"`
+	// Set by createRule and updateRule only: the findings of analyzeRules about the rule
+	Warnings []*RuleFinding `json:"warnings,omitempty"`
+	// Set by createRule and updateRule only: false when catalog-api could not be
+	// reached and the category of the rule was not checked
+	CatalogChecked *bool `json:"catalogChecked,omitempty"`
+}
+
+type RuleAnalysis struct {
+	Findings []*RuleFinding `json:"findings"`
+	// False when catalog-api could not be reached: categories and the counts of
+	// COURSE_COMPLETED rules were not checked against the catalog
+	CatalogChecked bool `json:"catalogChecked"`
 }
 
 type RuleConditions struct {
@@ -52,12 +95,87 @@ type RuleConditionsInput struct {
 	Category *string `json:"category,omitempty"`
 }
 
+type RuleConnection struct {
+	Edges    []*RuleEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+	// The number of rules matching the filter in every page
+	TotalCount int `json:"totalCount"`
+}
+
+type RuleEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Rule  `json:"node"`
+}
+
+type RuleExplanation struct {
+	Rule   *Rule  `json:"rule"`
+	UserID string `json:"userId"`
+	// The user's count for the rule: events of its type, in its category if it has one
+	CurrentCount int `json:"currentCount"`
+	// The user's count of events of the rule's type in every category
+	EventTypeCount int               `json:"eventTypeCount"`
+	RequiredCount  int               `json:"requiredCount"`
+	Conditions     []*ConditionCheck `json:"conditions"`
+	Triggered      bool              `json:"triggered"`
+	// When the rule granted its reward to the user, RFC 3339
+	TriggeredAt *string `json:"triggeredAt,omitempty"`
+	// What keeps the rule from triggering; empty once it triggered
+	Missing []string `json:"missing"`
+}
+
+type RuleFilter struct {
+	EventType  *string     `json:"eventType,omitempty"`
+	Enabled    *bool       `json:"enabled,omitempty"`
+	Status     *RuleStatus `json:"status,omitempty"`
+	RewardType *RewardType `json:"rewardType,omitempty"`
+	Category   *string     `json:"category,omitempty"`
+	// false lists the rules not archived, true the archived ones, null both
+	Archived *bool `json:"archived,omitempty"`
+}
+
+type RuleFinding struct {
+	Kind   RuleFindingKind `json:"kind"`
+	RuleID string          `json:"ruleId"`
+	// The other rules involved, for duplicates and shadowed rules
+	RelatedRuleIds []string `json:"relatedRuleIds"`
+	Message        string   `json:"message"`
+}
+
+type RuleSort struct {
+	Field     RuleSortField `json:"field"`
+	Direction SortDirection `json:"direction"`
+}
+
+type RuleSyncChange struct {
+	RuleID string         `json:"ruleId"`
+	Action RuleSyncAction `json:"action"`
+	Diff   []*FieldChange `json:"diff"`
+}
+
+type RuleVersion struct {
+	Version int        `json:"version"`
+	Change  RuleChange `json:"change"`
+	// The rule as it was after the change
+	Rule *Rule `json:"rule"`
+	// Who made the change: the subject of the caller's token
+	Author string `json:"author"`
+	// RFC 3339
+	ChangedAt string `json:"changedAt"`
+	// Fields changed from the previous version
+	Diff []*FieldChange `json:"diff"`
+	// The comment of a review change
+	Comment *string `json:"comment,omitempty"`
+}
+
+// Fields left out are not changed. A field set to null is cleared where that
+// makes sense: conditions, and count, which goes back to 1. eventType and reward
+// cannot be null. reward replaces the whole reward, so an amount left out
+// or null is 0.
 type UpdateRuleInput struct {
-	EventType  *string              `json:"eventType,omitempty"`
-	Count      *int                 `json:"count,omitempty"`
-	Conditions *RuleConditionsInput `json:"conditions,omitempty"`
-	Reward     *RewardInput         `json:"reward,omitempty"`
-	Enabled    *bool                `json:"enabled,omitempty"`
+	EventType  graphql.Omittable[*string]              `json:"eventType,omitempty"`
+	Count      graphql.Omittable[*int]                 `json:"count,omitempty"`
+	Conditions graphql.Omittable[*RuleConditionsInput] `json:"conditions,omitempty"`
+	Reward     graphql.Omittable[*RewardInput]         `json:"reward,omitempty"`
 }
 
 type RewardType string
@@ -114,3 +232,490 @@ func (e RewardType) MarshalJSON() ([]byte, error) {
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
+
+// Roles of the users of the API, from the role claim of their token. Each role
+// has the permissions of the roles below it.
+type Role string
+
+const (
+	// Reads rules
+	RoleViewer Role = "VIEWER"
+	// Drafts rules: creates them, updates the ones not active and submits them for review
+	RoleEditor Role = "EDITOR"
+	// Reviews and publishes rules, archives, deletes and reverts them
+	RoleAdmin Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleViewer,
+	RoleEditor,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *Role) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e Role) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RuleChange string
+
+const (
+	RuleChangeCreate  RuleChange = "CREATE"
+	RuleChangeUpdate  RuleChange = "UPDATE"
+	RuleChangeArchive RuleChange = "ARCHIVE"
+	RuleChangeRevert  RuleChange = "REVERT"
+	RuleChangeDelete  RuleChange = "DELETE"
+	RuleChangeSubmit  RuleChange = "SUBMIT"
+	RuleChangeApprove RuleChange = "APPROVE"
+	RuleChangeReject  RuleChange = "REJECT"
+	RuleChangePublish RuleChange = "PUBLISH"
+)
+
+var AllRuleChange = []RuleChange{
+	RuleChangeCreate,
+	RuleChangeUpdate,
+	RuleChangeArchive,
+	RuleChangeRevert,
+	RuleChangeDelete,
+	RuleChangeSubmit,
+	RuleChangeApprove,
+	RuleChangeReject,
+	RuleChangePublish,
+}
+
+func (e RuleChange) IsValid() bool {
+	switch e {
+	case RuleChangeCreate, RuleChangeUpdate, RuleChangeArchive, RuleChangeRevert, RuleChangeDelete, RuleChangeSubmit, RuleChangeApprove, RuleChangeReject, RuleChangePublish:
+		return true
+	}
+	return false
+}
+
+func (e RuleChange) String() string {
+	return string(e)
+}
+
+func (e *RuleChange) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleChange(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleChange", str)
+	}
+	return nil
+}
+
+func (e RuleChange) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RuleChange) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RuleChange) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RuleFindingKind string
+
+const (
+	// Same event type, category, count and reward as another rule: users get the reward twice
+	RuleFindingKindDuplicate RuleFindingKind = "DUPLICATE"
+	// Same event type, category and count as another rule, with another reward: the rules always fire together
+	RuleFindingKindShadowed RuleFindingKind = "SHADOWED"
+	// A count users cannot reach, below 1
+	RuleFindingKindUnreachableCount RuleFindingKind = "UNREACHABLE_COUNT"
+	// A category that is not in the catalog, compared case-insensitively
+	RuleFindingKindUnknownCategory RuleFindingKind = "UNKNOWN_CATEGORY"
+)
+
+var AllRuleFindingKind = []RuleFindingKind{
+	RuleFindingKindDuplicate,
+	RuleFindingKindShadowed,
+	RuleFindingKindUnreachableCount,
+	RuleFindingKindUnknownCategory,
+}
+
+func (e RuleFindingKind) IsValid() bool {
+	switch e {
+	case RuleFindingKindDuplicate, RuleFindingKindShadowed, RuleFindingKindUnreachableCount, RuleFindingKindUnknownCategory:
+		return true
+	}
+	return false
+}
+
+func (e RuleFindingKind) String() string {
+	return string(e)
+}
+
+func (e *RuleFindingKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleFindingKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleFindingKind", str)
+	}
+	return nil
+}
+
+func (e RuleFindingKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RuleFindingKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RuleFindingKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RuleSortField string
+
+const (
+	RuleSortFieldCreatedAt RuleSortField = "CREATED_AT"
+	RuleSortFieldEventType RuleSortField = "EVENT_TYPE"
+	RuleSortFieldCount     RuleSortField = "COUNT"
+)
+
+var AllRuleSortField = []RuleSortField{
+	RuleSortFieldCreatedAt,
+	RuleSortFieldEventType,
+	RuleSortFieldCount,
+}
+
+func (e RuleSortField) IsValid() bool {
+	switch e {
+	case RuleSortFieldCreatedAt, RuleSortFieldEventType, RuleSortFieldCount:
+		return true
+	}
+	return false
+}
+
+func (e RuleSortField) String() string {
+	return string(e)
+}
+
+func (e *RuleSortField) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleSortField", str)
+	}
+	return nil
+}
+
+func (e RuleSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RuleSortField) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RuleSortField) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+// The review lifecycle of a rule: DRAFT, submitted to PENDING_REVIEW, approved
+// or rejected back to DRAFT, published from APPROVED to ACTIVE. Archiving
+// retires a rule from any status.
+type RuleStatus string
+
+const (
+	RuleStatusDraft         RuleStatus = "DRAFT"
+	RuleStatusPendingReview RuleStatus = "PENDING_REVIEW"
+	RuleStatusApproved      RuleStatus = "APPROVED"
+	// Evaluated by the worker
+	RuleStatusActive RuleStatus = "ACTIVE"
+	// Archived
+	RuleStatusRetired RuleStatus = "RETIRED"
+)
+
+var AllRuleStatus = []RuleStatus{
+	RuleStatusDraft,
+	RuleStatusPendingReview,
+	RuleStatusApproved,
+	RuleStatusActive,
+	RuleStatusRetired,
+}
+
+func (e RuleStatus) IsValid() bool {
+	switch e {
+	case RuleStatusDraft, RuleStatusPendingReview, RuleStatusApproved, RuleStatusActive, RuleStatusRetired:
+		return true
+	}
+	return false
+}
+
+func (e RuleStatus) String() string {
+	return string(e)
+}
+
+func (e *RuleStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleStatus", str)
+	}
+	return nil
+}
+
+func (e RuleStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RuleStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RuleStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RuleSyncAction string
+
+const (
+	// A rule of the file is created
+	RuleSyncActionCreate RuleSyncAction = "CREATE"
+	// A rule is changed to match the file
+	RuleSyncActionUpdate RuleSyncAction = "UPDATE"
+	// A rule missing from the file is archived
+	RuleSyncActionDisable RuleSyncAction = "DISABLE"
+)
+
+var AllRuleSyncAction = []RuleSyncAction{
+	RuleSyncActionCreate,
+	RuleSyncActionUpdate,
+	RuleSyncActionDisable,
+}
+
+func (e RuleSyncAction) IsValid() bool {
+	switch e {
+	case RuleSyncActionCreate, RuleSyncActionUpdate, RuleSyncActionDisable:
+		return true
+	}
+	return false
+}
+
+func (e RuleSyncAction) String() string {
+	return string(e)
+}
+
+func (e *RuleSyncAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RuleSyncAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RuleSyncAction", str)
+	}
+	return nil
+}
+
+func (e RuleSyncAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RuleSyncAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RuleSyncAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RulesFileFormat string
+
+const (
+	RulesFileFormatYaml RulesFileFormat = "YAML"
+	RulesFileFormatJSON RulesFileFormat = "JSON"
+)
+
+var AllRulesFileFormat = []RulesFileFormat{
+	RulesFileFormatYaml,
+	RulesFileFormatJSON,
+}
+
+func (e RulesFileFormat) IsValid() bool {
+	switch e {
+	case RulesFileFormatYaml, RulesFileFormatJSON:
+		return true
+	}
+	return false
+}
+
+func (e RulesFileFormat) String() string {
+	return string(e)
+}
+
+func (e *RulesFileFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RulesFileFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RulesFileFormat", str)
+	}
+	return nil
+}
+
+func (e RulesFileFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RulesFileFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RulesFileFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortDirection string
+
+const (
+	SortDirectionAsc  SortDirection = "ASC"
+	SortDirectionDesc SortDirection = "DESC"
+)
+
+var AllSortDirection = []SortDirection{
+	SortDirectionAsc,
+	SortDirectionDesc,
+}
+
+func (e SortDirection) IsValid() bool {
+	switch e {
+	case SortDirectionAsc, SortDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortDirection) String() string {
+	return string(e)
+}
+
+func (e *SortDirection) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortDirection", str)
+	}
+	return nil
+}
+
+func (e SortDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortDirection) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortDirection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,26 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/alexandredsa/learning-rewards/reward-processor/graph/generated"
+	"github.com/alexandredsa/learning-rewards/reward-processor/graph/model"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+)
+
+// Directives returns the implementations of the schema directives
+func Directives() generated.DirectiveRoot {
+	return generated.DirectiveRoot{
+		HasRole: HasRole,
+	}
+}
+
+// HasRole implements @hasRole, resolving the field only for principals with
+// the role
+func HasRole(ctx context.Context, obj any, next graphql.Resolver, role model.Role) (any, error) {
+	if err := auth.Require(ctx, auth.Role(role)); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
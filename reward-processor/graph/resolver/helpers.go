@@ -1,12 +1,22 @@
 package resolver
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/model"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rulesfile"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 )
 
 const (
 	minCountValue = 1
+
+	// defaultPageSize and maxPageSize bound the rules of a page
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
 func ConvertToGraphQLRule(rule *models.Rule) *model.Rule {
@@ -41,8 +51,64 @@ func ConvertToGraphQLRule(rule *models.Rule) *model.Rule {
 			Amount:      amountPtr,
 			Description: rule.Reward.Description,
 		},
-		Enabled: rule.Enabled,
+		Status:     model.RuleStatus(rule.Status),
+		Enabled:    rule.Enabled,
+		Archived:   rule.ArchivedAt != nil,
+		ArchivedAt: formatTime(rule.ArchivedAt),
+		CreatedAt:  rule.CreatedAt.UTC().Format(time.RFC3339),
+		Version:    rule.Version,
+	}
+}
+
+// formatTime formats t in RFC 3339, or returns nil if t is nil
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
+
+// ConvertGraphQLRuleFilter converts a rules filter. Without a filter, rules
+// that are not archived are listed.
+func ConvertGraphQLRuleFilter(filter *model.RuleFilter) repository.RuleFilter {
+	if filter == nil {
+		notArchived := false
+		return repository.RuleFilter{Archived: &notArchived}
+	}
+	result := repository.RuleFilter{
+		EventType: filter.EventType,
+		Enabled:   filter.Enabled,
+		Category:  filter.Category,
+		Archived:  filter.Archived,
+	}
+	if filter.Status != nil {
+		status := models.RuleStatus(*filter.Status)
+		result.Status = &status
+	}
+	if filter.RewardType != nil {
+		rewardType := models.RewardType(*filter.RewardType)
+		result.RewardType = &rewardType
+	}
+	return result
+}
+
+// ConvertGraphQLRuleSort converts a rules sort order, by creation time by
+// default
+func ConvertGraphQLRuleSort(sort *model.RuleSort) repository.RuleSort {
+	if sort == nil {
+		return repository.RuleSort{Field: repository.RuleSortCreatedAt}
+	}
+	result := repository.RuleSort{Desc: sort.Direction == model.SortDirectionDesc}
+	switch sort.Field {
+	case model.RuleSortFieldEventType:
+		result.Field = repository.RuleSortEventType
+	case model.RuleSortFieldCount:
+		result.Field = repository.RuleSortCount
+	default:
+		result.Field = repository.RuleSortCreatedAt
 	}
+	return result
 }
 
 func ConvertGraphQLRuleToModel(rule interface{}) *models.Rule {
@@ -77,39 +143,180 @@ func ConvertGraphQLRuleToModel(rule interface{}) *models.Rule {
 				Amount:      rewardAmount,
 				Description: r.Reward.Description,
 			},
-			Enabled: r.Enabled,
 		}
 
-	case *model.UpdateRuleInput:
-		rule := &models.Rule{}
+	default:
+		return nil
+	}
+}
+
+// ConvertGraphQLRuleUpdate converts an update input to the fields to write,
+// telling fields left out from fields set to null or 0
+func ConvertGraphQLRuleUpdate(input *model.UpdateRuleInput) (repository.RuleUpdate, error) {
+	var update repository.RuleUpdate
 
-		if r.EventType != nil {
-			rule.EventType = *r.EventType
+	if eventType, ok := input.EventType.ValueOK(); ok {
+		if eventType == nil {
+			return update, fmt.Errorf("eventType cannot be null")
 		}
-		if r.Count != nil {
-			rule.Count = *r.Count
+		update.EventType = repository.Some(*eventType)
+	}
+	if count, ok := input.Count.ValueOK(); ok {
+		switch {
+		case count == nil:
+			update.Count = repository.Some(minCountValue)
+		case *count < minCountValue:
+			return update, fmt.Errorf("count must be at least %d", minCountValue)
+		default:
+			update.Count = repository.Some(*count)
 		}
-		if r.Conditions != nil && r.Conditions.Category != nil {
-			rule.ConditionsCategory = r.Conditions.Category
+	}
+	if conditions, ok := input.Conditions.ValueOK(); ok {
+		var category *string
+		if conditions != nil {
+			category = conditions.Category
 		}
-		if r.Reward != nil {
-			if r.Reward.Type != "" {
-				rule.Reward.Type = models.RewardType(r.Reward.Type)
-			}
-			if r.Reward.Amount != nil {
-				rule.Reward.Amount = *r.Reward.Amount
-			}
-			if r.Reward.Description != "" {
-				rule.Reward.Description = r.Reward.Description
-			}
+		update.ConditionsCategory = repository.Some(category)
+	}
+	if reward, ok := input.Reward.ValueOK(); ok {
+		if reward == nil {
+			return update, fmt.Errorf("reward cannot be null")
 		}
-		if r.Enabled != nil {
-			rule.Enabled = *r.Enabled
+		amount := 0
+		if reward.Amount != nil {
+			amount = *reward.Amount
 		}
+		update.Reward = repository.Some(models.Reward{
+			Type:        models.RewardType(reward.Type),
+			Amount:      amount,
+			Description: reward.Description,
+		})
+	}
+	return update, nil
+}
 
-		return rule
+// ConvertToGraphQLRuleHistory converts the versions of a rule, newest first.
+// The rule was created with its oldest version.
+func ConvertToGraphQLRuleHistory(versions []models.RuleVersion) []*model.RuleVersion {
+	result := make([]*model.RuleVersion, len(versions))
+	if len(versions) == 0 {
+		return result
+	}
+	createdAt := versions[len(versions)-1].CreatedAt
+	for i, v := range versions {
+		rule := v.Rule()
+		rule.CreatedAt = createdAt
 
-	default:
+		result[i] = &model.RuleVersion{
+			Version:   v.Version,
+			Change:    model.RuleChange(v.Change),
+			Rule:      ConvertToGraphQLRule(&rule),
+			Author:    v.Author,
+			ChangedAt: v.CreatedAt.UTC().Format(time.RFC3339),
+			Diff:      convertDiff(v.Diff),
+			Comment:   optionalString(v.Comment),
+		}
+	}
+	return result
+}
+
+// convertDiff converts the fields changed by a rule change
+func convertDiff(diff []models.FieldChange) []*model.FieldChange {
+	result := make([]*model.FieldChange, len(diff))
+	for i, c := range diff {
+		result[i] = &model.FieldChange{Field: c.Field, From: c.From, To: c.To}
+	}
+	return result
+}
+
+// ConvertGraphQLRulesFileFormat converts a rules file format, YAML by default
+func ConvertGraphQLRulesFileFormat(format *model.RulesFileFormat) rulesfile.Format {
+	if format != nil && *format == model.RulesFileFormatJSON {
+		return rulesfile.JSON
+	}
+	return rulesfile.YAML
+}
+
+// ParseRulesFile parses and validates a rules file, returning its rules
+func ParseRulesFile(file string, format *model.RulesFileFormat) ([]models.Rule, error) {
+	parsed, err := rulesfile.Parse([]byte(file), ConvertGraphQLRulesFileFormat(format))
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %w", err)
+	}
+	return parsed.Models(), nil
+}
+
+// ConvertToGraphQLRuleSyncChanges converts the changes of a rules sync
+func ConvertToGraphQLRuleSyncChanges(changes []repository.RuleSyncChange) []*model.RuleSyncChange {
+	result := make([]*model.RuleSyncChange, len(changes))
+	for i, c := range changes {
+		result[i] = &model.RuleSyncChange{
+			RuleID: c.RuleID,
+			Action: model.RuleSyncAction(c.Action),
+			Diff:   convertDiff(c.Diff),
+		}
+	}
+	return result
+}
+
+// ConvertToGraphQLFinding converts a finding of the rule analysis
+func ConvertToGraphQLFinding(f rules.Finding) *model.RuleFinding {
+	related := f.RelatedRuleIDs
+	if related == nil {
+		related = []string{}
+	}
+	return &model.RuleFinding{
+		Kind:           model.RuleFindingKind(f.Kind),
+		RuleID:         f.RuleID,
+		RelatedRuleIds: related,
+		Message:        f.Message,
+	}
+}
+
+// stringValue returns the value of s, or "" if s is nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// optionalString returns nil for an empty s
+func optionalString(s string) *string {
+	if s == "" {
 		return nil
 	}
+	return &s
+}
+
+func ConvertToGraphQLExplanation(x rules.Explanation) *model.RuleExplanation {
+	conditions := make([]*model.ConditionCheck, len(x.Conditions))
+	for i, c := range x.Conditions {
+		conditions[i] = &model.ConditionCheck{
+			Condition: c.Condition,
+			Expected:  c.Expected,
+			Actual:    c.Actual,
+			Satisfied: c.Satisfied,
+		}
+	}
+
+	missing := x.Missing
+	if missing == nil {
+		missing = []string{}
+	}
+
+	return &model.RuleExplanation{
+		Rule:           ConvertToGraphQLRule(&x.Rule),
+		UserID:         x.UserID,
+		CurrentCount:   x.Count,
+		EventTypeCount: x.EventTypeCount,
+		RequiredCount:  x.Rule.Count,
+		Conditions:     conditions,
+		Triggered:      x.Triggered(),
+		TriggeredAt:    formatTime(x.TriggeredAt),
+		Missing:        missing,
+	}
 }
@@ -1,8 +1,19 @@
 package resolver
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/graph/model"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/catalog"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // This file will not be regenerated automatically.
@@ -11,7 +22,13 @@ import (
 
 type Resolver struct {
 	RuleRepository repository.RuleRepository
-	Logger         *zap.Logger
+	// Engine and Ledger answer explainRule, which fails when they are not set
+	Engine *rules.Engine
+	Ledger repository.RewardLedgerRepository
+	// Catalog is what rules are analyzed against; categories are not
+	// checked when it is not set
+	Catalog catalog.Source
+	Logger  *zap.Logger
 }
 
 // NewResolver creates a new resolver with the required dependencies
@@ -21,3 +38,78 @@ func NewResolver(ruleRepo repository.RuleRepository, logger *zap.Logger) *Resolv
 		Logger:         logger,
 	}
 }
+
+// warningsCatalogTimeout bounds the catalog lookup of createRule and
+// updateRule, which have saved the rule already
+const warningsCatalogTimeout = time.Second
+
+// catalog returns the catalog rules are analyzed against, or nil when it is
+// not set or cannot be fetched
+func (r *Resolver) catalog(ctx context.Context) *catalog.Catalog {
+	if r.Catalog == nil {
+		return nil
+	}
+	cat, err := r.Catalog.Catalog(ctx)
+	if err != nil {
+		r.Logger.Warn("Analyzing rules without the catalog", zap.Error(err))
+		return nil
+	}
+	return cat
+}
+
+// analyze analyzes the rules that are not archived. catalogChecked is false
+// when the catalog is not set or cannot be fetched, in which case the rules
+// are analyzed without it.
+func (r *Resolver) analyze(ctx context.Context) (findings []rules.Finding, catalogChecked bool, err error) {
+	current, err := r.RuleRepository.GetUnarchivedRules(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch rules: %w", err)
+	}
+	cat := r.catalog(ctx)
+	return rules.Analyze(current, cat), cat != nil, nil
+}
+
+// withWarnings converts saved for createRule and updateRule, with the findings
+// of the analysis of saved against the other rules as its warnings. The rule
+// is already saved, so an analysis that fails is logged and leaves it without
+// warnings.
+func (r *Resolver) withWarnings(ctx context.Context, saved *models.Rule) *model.Rule {
+	rule := ConvertToGraphQLRule(saved)
+	current, err := r.RuleRepository.GetUnarchivedRules(ctx)
+	if err != nil {
+		r.Logger.Warn("Failed to analyze rules", zap.String("ruleID", rule.ID), zap.Error(err))
+		return rule
+	}
+
+	catalogCtx, cancel := context.WithTimeout(ctx, warningsCatalogTimeout)
+	defer cancel()
+	cat := r.catalog(catalogCtx)
+
+	rule.Warnings = []*model.RuleFinding{}
+	for _, f := range rules.AnalyzeRule(*saved, current, cat) {
+		rule.Warnings = append(rule.Warnings, ConvertToGraphQLFinding(f))
+	}
+	catalogChecked := cat != nil
+	rule.CatalogChecked = &catalogChecked
+	return rule
+}
+
+// transition applies a review change to a rule
+func (r *Resolver) transition(ctx context.Context, id string, change models.RuleChange, comment string) (*model.Rule, error) {
+	rule, err := r.RuleRepository.TransitionRule(ctx, id, change, comment)
+	if err != nil {
+		var transitionErr *repository.TransitionError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, fmt.Errorf("rule not found: %s", id)
+		case errors.As(err, &transitionErr), errors.Is(err, repository.ErrSelfApproval):
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to %s rule: %w", strings.ToLower(string(change)), err)
+	}
+	r.Logger.Debug("Changed rule status",
+		zap.String("ruleID", id),
+		zap.String("change", string(change)),
+		zap.String("status", string(rule.Status)))
+	return ConvertToGraphQLRule(rule), nil
+}
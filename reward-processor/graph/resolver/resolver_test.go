@@ -2,14 +2,22 @@ package resolver_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/model"
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/resolver"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/catalog"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // MockRuleRepository is a mock implementation of repository.RuleRepository
@@ -22,8 +30,8 @@ func (m *MockRuleRepository) CreateRule(ctx context.Context, rule *models.Rule)
 	return args.Error(0)
 }
 
-func (m *MockRuleRepository) UpdateRule(ctx context.Context, id string, rule *models.Rule) error {
-	args := m.Called(ctx, id, rule)
+func (m *MockRuleRepository) UpdateRule(ctx context.Context, id string, allowActive bool, update repository.RuleUpdate) error {
+	args := m.Called(ctx, id, allowActive, update)
 	return args.Error(0)
 }
 
@@ -35,11 +43,63 @@ func (m *MockRuleRepository) GetRuleByID(ctx context.Context, id string) (*model
 	return args.Get(0).(*models.Rule), args.Error(1)
 }
 
-func (m *MockRuleRepository) GetEnabledRules(ctx context.Context) ([]models.Rule, error) {
+func (m *MockRuleRepository) GetActiveRules(ctx context.Context) ([]models.Rule, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]models.Rule), args.Error(1)
 }
 
+func (m *MockRuleRepository) ListRules(ctx context.Context, filter repository.RuleFilter, sort repository.RuleSort, first int, after string) (repository.RulePage, error) {
+	args := m.Called(ctx, filter, sort, first, after)
+	return args.Get(0).(repository.RulePage), args.Error(1)
+}
+
+func (m *MockRuleRepository) DeleteRule(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRuleRepository) GetRuleHistory(ctx context.Context, id string) ([]models.RuleVersion, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]models.RuleVersion), args.Error(1)
+}
+
+func (m *MockRuleRepository) RevertRule(ctx context.Context, id string, version int) (*models.Rule, error) {
+	args := m.Called(ctx, id, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) TransitionRule(ctx context.Context, id string, change models.RuleChange, comment string) (*models.Rule, error) {
+	args := m.Called(ctx, id, change, comment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) GetUnarchivedRules(ctx context.Context) ([]models.Rule, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Rule), args.Error(1)
+}
+
+func (m *MockRuleRepository) SyncRules(ctx context.Context, rules []models.Rule, dryRun bool, comment string) ([]repository.RuleSyncChange, error) {
+	args := m.Called(ctx, rules, dryRun, comment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]repository.RuleSyncChange), args.Error(1)
+}
+
+func (m *MockRuleRepository) ArchiveRule(ctx context.Context, id string) (*models.Rule, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Rule), args.Error(1)
+}
+
 // TestCase represents a test case with setup and assertions
 type TestCase struct {
 	name         string
@@ -61,6 +121,9 @@ func runTestCase(t *testing.T, tc TestCase) {
 
 	// Setup mocks
 	tc.setupMocks(mockRepo)
+	// createRule and updateRule analyze the rules for warnings; expectations
+	// set by the test come first
+	mockRepo.On("GetUnarchivedRules", mock.Anything).Return([]models.Rule{}, nil).Maybe()
 
 	// Run test
 	result, err := tc.runTest(resolver)
@@ -86,11 +149,10 @@ func TestCreateRule(t *testing.T) {
 						Amount:      100,
 						Description: "Completed 5 math courses",
 					},
-					Enabled: true,
 				}).Return(nil)
 			},
 			runTest: func(r *resolver.Resolver) (interface{}, error) {
-				return r.Mutation().CreateRule(context.Background(), model.CreateRuleInput{
+				return r.Mutation().CreateRule(asRole(auth.Admin), model.CreateRuleInput{
 					EventType: "COURSE_COMPLETED",
 					Count:     ptrInt(5),
 					Conditions: &model.RuleConditionsInput{
@@ -101,7 +163,6 @@ func TestCreateRule(t *testing.T) {
 						Amount:      ptrInt(100),
 						Description: "Completed 5 math courses",
 					},
-					Enabled: true,
 				})
 			},
 			assertResult: func(t *testing.T, result interface{}, err error) {
@@ -113,7 +174,6 @@ func TestCreateRule(t *testing.T) {
 				assert.Equal(t, model.RewardType("POINTS"), rule.Reward.Type)
 				assert.Equal(t, ptrInt(100), rule.Reward.Amount)
 				assert.Equal(t, "Completed 5 math courses", rule.Reward.Description)
-				assert.True(t, rule.Enabled)
 			},
 			assertMocks: func(t *testing.T, m *MockRuleRepository) {
 				m.AssertExpectations(t)
@@ -129,17 +189,15 @@ func TestCreateRule(t *testing.T) {
 						Type:        models.RewardType("BADGE"),
 						Description: "Completed a course",
 					},
-					Enabled: true,
 				}).Return(nil)
 			},
 			runTest: func(r *resolver.Resolver) (interface{}, error) {
-				return r.Mutation().CreateRule(context.Background(), model.CreateRuleInput{
+				return r.Mutation().CreateRule(asRole(auth.Admin), model.CreateRuleInput{
 					EventType: "COURSE_COMPLETED",
 					Reward: &model.RewardInput{
 						Type:        model.RewardType("BADGE"),
 						Description: "Completed a course",
 					},
-					Enabled: true,
 				})
 			},
 			assertResult: func(t *testing.T, result interface{}, err error) {
@@ -151,7 +209,6 @@ func TestCreateRule(t *testing.T) {
 				assert.Equal(t, model.RewardType("BADGE"), rule.Reward.Type)
 				assert.Nil(t, rule.Reward.Amount)
 				assert.Equal(t, "Completed a course", rule.Reward.Description)
-				assert.True(t, rule.Enabled)
 			},
 			assertMocks: func(t *testing.T, m *MockRuleRepository) {
 				m.AssertExpectations(t)
@@ -171,17 +228,6 @@ func TestUpdateRule(t *testing.T) {
 		{
 			name: "update rule conditions",
 			setupMocks: func(m *MockRuleRepository) {
-				existingRule := &models.Rule{
-					ID:                 "rule-001",
-					EventType:          "COURSE_COMPLETED",
-					ConditionsCategory: ptrString("MATH"),
-					Reward: models.Reward{
-						Type:        models.RewardType("POINTS"),
-						Amount:      100,
-						Description: "Math course reward",
-					},
-					Enabled: true,
-				}
 				updatedRule := &models.Rule{
 					ID:                 "rule-001",
 					EventType:          "COURSE_COMPLETED",
@@ -191,20 +237,20 @@ func TestUpdateRule(t *testing.T) {
 						Amount:      100,
 						Description: "Math course reward",
 					},
+					Status:  models.RuleActive,
 					Enabled: true,
 				}
 
-				m.On("GetRuleByID", mock.Anything, "rule-001").Return(existingRule, nil)
-				m.On("UpdateRule", mock.Anything, "rule-001", mock.MatchedBy(func(rule *models.Rule) bool {
-					return rule.ConditionsCategory != nil && *rule.ConditionsCategory == "SCIENCE"
-				})).Return(nil)
 				m.On("GetRuleByID", mock.Anything, "rule-001").Return(updatedRule, nil)
+				m.On("UpdateRule", mock.Anything, "rule-001", true, repository.RuleUpdate{
+					ConditionsCategory: repository.Some(ptrString("SCIENCE")),
+				}).Return(nil)
 			},
 			runTest: func(r *resolver.Resolver) (interface{}, error) {
-				return r.Mutation().UpdateRule(context.Background(), "rule-001", model.UpdateRuleInput{
-					Conditions: &model.RuleConditionsInput{
+				return r.Mutation().UpdateRule(asRole(auth.Admin), "rule-001", model.UpdateRuleInput{
+					Conditions: graphql.OmittableOf(&model.RuleConditionsInput{
 						Category: ptrString("SCIENCE"),
-					},
+					}),
 				})
 			},
 			assertResult: func(t *testing.T, result interface{}, err error) {
@@ -217,6 +263,60 @@ func TestUpdateRule(t *testing.T) {
 				m.AssertExpectations(t)
 			},
 		},
+		{
+			name: "update sends an approved rule back to draft",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("UpdateRule", mock.Anything, "rule-001", false, repository.RuleUpdate{
+					Count: repository.Some(10),
+				}).Return(nil)
+				m.On("GetRuleByID", mock.Anything, "rule-001").Return(&models.Rule{ID: "rule-001", Status: models.RuleDraft}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Editor), "rule-001", model.UpdateRuleInput{
+					Count: graphql.OmittableOf(ptrInt(10)),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.RuleStatusDraft, result.(*model.Rule).Status)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "rule not found",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("UpdateRule", mock.Anything, "missing", true, mock.Anything).Return(gorm.ErrRecordNotFound)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Admin), "missing", model.UpdateRuleInput{
+					Count: graphql.OmittableOf(ptrInt(2)),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule not found: missing")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+				m.AssertNotCalled(t, "GetRuleByID", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name:       "invalid update is not written",
+			setupMocks: func(m *MockRuleRepository) {},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Admin), "rule-001", model.UpdateRuleInput{
+					EventType: graphql.OmittableOf[*string](nil),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "eventType cannot be null")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertNotCalled(t, "UpdateRule", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -226,7 +326,105 @@ func TestUpdateRule(t *testing.T) {
 	}
 }
 
+func TestConvertGraphQLRuleUpdate(t *testing.T) {
+	reward := &model.RewardInput{Type: model.RewardTypePoints, Description: "No points"}
+
+	tests := []struct {
+		name     string
+		input    model.UpdateRuleInput
+		expected repository.RuleUpdate
+		err      string
+	}{
+		{
+			name:     "fields left out are not updated",
+			input:    model.UpdateRuleInput{},
+			expected: repository.RuleUpdate{},
+		},
+		{
+			name:     "event type",
+			input:    model.UpdateRuleInput{EventType: graphql.OmittableOf(ptrString("MODULE_COMPLETED"))},
+			expected: repository.RuleUpdate{EventType: repository.Some("MODULE_COMPLETED")},
+		},
+		{
+			name:  "null event type",
+			input: model.UpdateRuleInput{EventType: graphql.OmittableOf[*string](nil)},
+			err:   "eventType cannot be null",
+		},
+		{
+			name:     "count",
+			input:    model.UpdateRuleInput{Count: graphql.OmittableOf(ptrInt(3))},
+			expected: repository.RuleUpdate{Count: repository.Some(3)},
+		},
+		{
+			name:     "null count goes back to 1",
+			input:    model.UpdateRuleInput{Count: graphql.OmittableOf[*int](nil)},
+			expected: repository.RuleUpdate{Count: repository.Some(1)},
+		},
+		{
+			name:  "zero count",
+			input: model.UpdateRuleInput{Count: graphql.OmittableOf(ptrInt(0))},
+			err:   "count must be at least 1",
+		},
+		{
+			name:     "category",
+			input:    model.UpdateRuleInput{Conditions: graphql.OmittableOf(&model.RuleConditionsInput{Category: ptrString("MATH")})},
+			expected: repository.RuleUpdate{ConditionsCategory: repository.Some(ptrString("MATH"))},
+		},
+		{
+			name:     "null category clears it",
+			input:    model.UpdateRuleInput{Conditions: graphql.OmittableOf(&model.RuleConditionsInput{})},
+			expected: repository.RuleUpdate{ConditionsCategory: repository.Some[*string](nil)},
+		},
+		{
+			name:     "null conditions clear the category",
+			input:    model.UpdateRuleInput{Conditions: graphql.OmittableOf[*model.RuleConditionsInput](nil)},
+			expected: repository.RuleUpdate{ConditionsCategory: repository.Some[*string](nil)},
+		},
+		{
+			name:  "reward without amount has none",
+			input: model.UpdateRuleInput{Reward: graphql.OmittableOf(reward)},
+			expected: repository.RuleUpdate{Reward: repository.Some(models.Reward{
+				Type:        models.PointsReward,
+				Description: "No points",
+			})},
+		},
+		{
+			name: "reward with amount",
+			input: model.UpdateRuleInput{Reward: graphql.OmittableOf(&model.RewardInput{
+				Type:        model.RewardTypePoints,
+				Amount:      ptrInt(50),
+				Description: "50 points",
+			})},
+			expected: repository.RuleUpdate{Reward: repository.Some(models.Reward{
+				Type:        models.PointsReward,
+				Amount:      50,
+				Description: "50 points",
+			})},
+		},
+		{
+			name:  "null reward",
+			input: model.UpdateRuleInput{Reward: graphql.OmittableOf[*model.RewardInput](nil)},
+			err:   "reward cannot be null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := resolver.ConvertGraphQLRuleUpdate(&tt.input)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, update)
+		})
+	}
+}
+
 func TestConvertToGraphQLRule(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	archivedAt := time.Date(2025, 7, 1, 8, 30, 0, 0, time.UTC)
+
 	tests := []struct {
 		name     string
 		input    *models.Rule
@@ -244,7 +442,9 @@ func TestConvertToGraphQLRule(t *testing.T) {
 					Amount:      100,
 					Description: "Math course reward",
 				},
-				Enabled: true,
+				Status:    models.RuleActive,
+				Enabled:   true,
+				CreatedAt: createdAt,
 			},
 			expected: &model.Rule{
 				ID:        "rule-001",
@@ -258,11 +458,13 @@ func TestConvertToGraphQLRule(t *testing.T) {
 					Amount:      ptrInt(100),
 					Description: "Math course reward",
 				},
-				Enabled: true,
+				Status:    model.RuleStatusActive,
+				Enabled:   true,
+				CreatedAt: "2025-06-01T12:00:00Z",
 			},
 		},
 		{
-			name: "convert rule without conditions",
+			name: "convert archived rule without conditions",
 			input: &models.Rule{
 				ID:        "rule-002",
 				EventType: "COURSE_COMPLETED",
@@ -270,7 +472,9 @@ func TestConvertToGraphQLRule(t *testing.T) {
 					Type:        models.RewardType("BADGE"),
 					Description: "Course completion badge",
 				},
-				Enabled: true,
+				Status:     models.RuleRetired,
+				CreatedAt:  createdAt,
+				ArchivedAt: &archivedAt,
 			},
 			expected: &model.Rule{
 				ID:         "rule-002",
@@ -280,7 +484,10 @@ func TestConvertToGraphQLRule(t *testing.T) {
 					Type:        model.RewardType("BADGE"),
 					Description: "Course completion badge",
 				},
-				Enabled: true,
+				Status:     model.RuleStatusRetired,
+				Archived:   true,
+				ArchivedAt: ptrString("2025-07-01T08:30:00Z"),
+				CreatedAt:  "2025-06-01T12:00:00Z",
 			},
 		},
 	}
@@ -293,6 +500,852 @@ func TestConvertToGraphQLRule(t *testing.T) {
 	}
 }
 
+func TestRules(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	page := []models.Rule{
+		{ID: "rule-001", EventType: "COURSE_COMPLETED", Enabled: true, CreatedAt: createdAt},
+		{ID: "rule-002", EventType: "MODULE_COMPLETED", Enabled: true, CreatedAt: createdAt.Add(time.Hour)},
+	}
+	notArchived := false
+	byCreatedAt := repository.RuleSort{Field: repository.RuleSortCreatedAt}
+
+	tests := []TestCase{
+		{
+			name: "first page lists rules that are not archived by default",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("ListRules", mock.Anything, repository.RuleFilter{Archived: &notArchived}, byCreatedAt, 20, "").
+					Return(repository.RulePage{Rules: page, HasNextPage: true, TotalCount: 5}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().Rules(context.Background(), nil, nil, nil, nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				conn := result.(*model.RuleConnection)
+				assert.Equal(t, 5, conn.TotalCount)
+				assert.Len(t, conn.Edges, 2)
+				assert.Equal(t, "rule-001", conn.Edges[0].Node.ID)
+				assert.Equal(t, repository.RuleCursor(page[0], byCreatedAt), conn.Edges[0].Cursor)
+				assert.True(t, conn.PageInfo.HasNextPage)
+				assert.Equal(t, &conn.Edges[1].Cursor, conn.PageInfo.EndCursor)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "filter and sort are passed to the repository",
+			setupMocks: func(m *MockRuleRepository) {
+				rewardType := models.RewardType("BADGE")
+				m.On("ListRules", mock.Anything,
+					repository.RuleFilter{EventType: ptrString("COURSE_COMPLETED"), RewardType: &rewardType},
+					repository.RuleSort{Field: repository.RuleSortCount, Desc: true}, 10, "cursor").
+					Return(repository.RulePage{}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				rewardType := model.RewardTypeBadge
+				return r.Query().Rules(context.Background(),
+					&model.RuleFilter{EventType: ptrString("COURSE_COMPLETED"), RewardType: &rewardType},
+					&model.RuleSort{Field: model.RuleSortFieldCount, Direction: model.SortDirectionDesc},
+					ptrInt(10), ptrString("cursor"))
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				conn := result.(*model.RuleConnection)
+				assert.Empty(t, conn.Edges)
+				assert.False(t, conn.PageInfo.HasNextPage)
+				assert.Nil(t, conn.PageInfo.EndCursor)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name:       "page size out of range",
+			setupMocks: func(m *MockRuleRepository) {},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().Rules(context.Background(), nil, nil, ptrInt(101), nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "first must be between 1 and 100")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertNotCalled(t, "ListRules")
+			},
+		},
+		{
+			name: "invalid cursor",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("ListRules", mock.Anything, mock.Anything, mock.Anything, 20, "bogus").
+					Return(repository.RulePage{}, repository.ErrInvalidCursor)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().Rules(context.Background(), nil, nil, nil, ptrString("bogus"))
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "invalid after cursor: bogus")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestDeleteAndArchiveRule(t *testing.T) {
+	archivedAt := time.Date(2025, 7, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []TestCase{
+		{
+			name: "delete rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("DeleteRule", mock.Anything, "rule-001").Return(nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().DeleteRule(context.Background(), "rule-001")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, true, result)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "delete missing rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("DeleteRule", mock.Anything, "missing").Return(gorm.ErrRecordNotFound)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().DeleteRule(context.Background(), "missing")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule not found: missing")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "archive rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("ArchiveRule", mock.Anything, "rule-001").Return(&models.Rule{
+					ID:         "rule-001",
+					EventType:  "COURSE_COMPLETED",
+					Status:     models.RuleRetired,
+					ArchivedAt: &archivedAt,
+				}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().ArchiveRule(context.Background(), "rule-001")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				rule := result.(*model.Rule)
+				assert.True(t, rule.Archived)
+				assert.Equal(t, model.RuleStatusRetired, rule.Status)
+				assert.False(t, rule.Enabled)
+				assert.Equal(t, ptrString("2025-07-01T08:30:00Z"), rule.ArchivedAt)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "archive missing rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("ArchiveRule", mock.Anything, "missing").Return(nil, gorm.ErrRecordNotFound)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().ArchiveRule(context.Background(), "missing")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule not found: missing")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestRuleHistory(t *testing.T) {
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	updated := created.Add(24 * time.Hour)
+	versions := []models.RuleVersion{
+		{
+			RuleID:    "rule-001",
+			Version:   2,
+			Change:    models.RuleUpdated,
+			EventType: "COURSE_COMPLETED",
+			Count:     5,
+			Reward:    models.Reward{Type: models.PointsReward, Description: "No points"},
+			Author:    "alice",
+			Diff:      []models.FieldChange{{Field: "reward.amount", From: ptrString("100"), To: ptrString("0")}},
+			CreatedAt: updated,
+		},
+		{
+			RuleID:    "rule-001",
+			Version:   1,
+			Change:    models.RuleCreated,
+			EventType: "COURSE_COMPLETED",
+			Count:     5,
+			Reward:    models.Reward{Type: models.PointsReward, Amount: 100, Description: "No points"},
+			Enabled:   true,
+			Author:    "bob",
+			CreatedAt: created,
+		},
+	}
+
+	tests := []TestCase{
+		{
+			name: "history newest first",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("GetRuleHistory", mock.Anything, "rule-001").Return(versions, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().RuleHistory(context.Background(), "rule-001")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				history := result.([]*model.RuleVersion)
+				assert.Len(t, history, 2)
+				assert.Equal(t, &model.RuleVersion{
+					Version: 2,
+					Change:  model.RuleChangeUpdate,
+					Rule: &model.Rule{
+						ID:        "rule-001",
+						EventType: "COURSE_COMPLETED",
+						Count:     ptrInt(5),
+						Reward: &model.Reward{
+							Type:        model.RewardTypePoints,
+							Description: "No points",
+						},
+						CreatedAt: "2025-06-01T12:00:00Z",
+						Version:   2,
+					},
+					Author:    "alice",
+					ChangedAt: "2025-06-02T12:00:00Z",
+					Diff:      []*model.FieldChange{{Field: "reward.amount", From: ptrString("100"), To: ptrString("0")}},
+				}, history[0])
+				assert.Equal(t, model.RuleChangeCreate, history[1].Change)
+				assert.Empty(t, history[1].Diff)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "version",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("GetRuleHistory", mock.Anything, "rule-001").Return(versions, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().RuleVersion(context.Background(), "rule-001", 1)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				version := result.(*model.RuleVersion)
+				assert.Equal(t, "bob", version.Author)
+				assert.Equal(t, ptrInt(100), version.Rule.Reward.Amount)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "missing version",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("GetRuleHistory", mock.Anything, "rule-001").Return(versions, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().RuleVersion(context.Background(), "rule-001", 3)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Nil(t, result)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestRevertRule(t *testing.T) {
+	tests := []TestCase{
+		{
+			name: "revert",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("RevertRule", mock.Anything, "rule-001", 1).Return(&models.Rule{ID: "rule-001", Version: 3}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().RevertRule(context.Background(), "rule-001", 1)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, 3, result.(*model.Rule).Version)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "missing version",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("RevertRule", mock.Anything, "rule-001", 9).Return(nil, repository.ErrRuleVersionNotFound)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().RevertRule(context.Background(), "rule-001", 9)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule rule-001 has no version 9")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "archived rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("RevertRule", mock.Anything, "rule-001", 1).Return(nil, repository.ErrRuleArchived)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().RevertRule(context.Background(), "rule-001", 1)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule rule-001 is archived")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestRuleAuthorization(t *testing.T) {
+	draftRule := &models.Rule{ID: "rule-001", EventType: "COURSE_COMPLETED", Status: models.RuleDraft}
+	activeRule := &models.Rule{ID: "rule-002", EventType: "COURSE_COMPLETED", Status: models.RuleActive, Enabled: true}
+
+	tests := []TestCase{
+		{
+			name: "editor creates a rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("CreateRule", mock.Anything, mock.Anything).Return(nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().CreateRule(asRole(auth.Editor), model.CreateRuleInput{
+					EventType: "COURSE_COMPLETED",
+					Reward:    &model.RewardInput{Type: model.RewardTypeBadge, Description: "Badge"},
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "editor updates a draft",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("GetRuleByID", mock.Anything, "rule-001").Return(draftRule, nil)
+				m.On("UpdateRule", mock.Anything, "rule-001", false, mock.Anything).Return(nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Editor), "rule-001", model.UpdateRuleInput{
+					Count: graphql.OmittableOf(ptrInt(3)),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "admin changes an active rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("UpdateRule", mock.Anything, "rule-002", true, mock.Anything).Return(nil)
+				m.On("GetRuleByID", mock.Anything, "rule-002").Return(activeRule, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Admin), "rule-002", model.UpdateRuleInput{
+					Count: graphql.OmittableOf(ptrInt(3)),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.RuleStatusActive, result.(*model.Rule).Status)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "editor cannot change an active rule",
+			setupMocks: func(m *MockRuleRepository) {
+				// The repository checks the status of the locked rule
+				m.On("UpdateRule", mock.Anything, "rule-002", false, mock.Anything).Return(repository.ErrRuleActive)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().UpdateRule(asRole(auth.Editor), "rule-002", model.UpdateRuleInput{
+					Count: graphql.OmittableOf(ptrInt(3)),
+				})
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				var forbidden *auth.ForbiddenError
+				assert.ErrorAs(t, err, &forbidden)
+				assert.Equal(t, auth.Admin, forbidden.Required)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+				m.AssertNotCalled(t, "GetRuleByID", mock.Anything, mock.Anything)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestRuleReview(t *testing.T) {
+	tests := []TestCase{
+		{
+			name: "submit rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "rule-001", models.RuleSubmitted, "").
+					Return(&models.Rule{ID: "rule-001", Status: models.RulePendingReview}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().SubmitRule(asRole(auth.Editor), "rule-001", nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.RuleStatusPendingReview, result.(*model.Rule).Status)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "approve with comment",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "rule-001", models.RuleApproval, "Looks good").
+					Return(&models.Rule{ID: "rule-001", Status: models.RuleApproved}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().ApproveRule(asRole(auth.Admin), "rule-001", ptrString("Looks good"))
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.RuleStatusApproved, result.(*model.Rule).Status)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "approve own submission",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "rule-001", models.RuleApproval, "").
+					Return(nil, repository.ErrSelfApproval)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().ApproveRule(asRole(auth.Admin), "rule-001", nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.ErrorIs(t, err, repository.ErrSelfApproval)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name:       "reject without comment",
+			setupMocks: func(m *MockRuleRepository) {},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().RejectRule(asRole(auth.Admin), "rule-001", " ")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "comment is required to reject a rule")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertNotCalled(t, "TransitionRule", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "reject with comment",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "rule-001", models.RuleRejected, "Count is too low").
+					Return(&models.Rule{ID: "rule-001", Status: models.RuleDraft}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().RejectRule(asRole(auth.Admin), "rule-001", "Count is too low")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, model.RuleStatusDraft, result.(*model.Rule).Status)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "publish a rule not approved",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "rule-001", models.RulePublished, "").
+					Return(nil, &repository.TransitionError{Change: models.RulePublished, Status: models.RuleDraft})
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().PublishRule(asRole(auth.Admin), "rule-001")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "cannot publish a rule that is DRAFT")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "publish missing rule",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("TransitionRule", mock.Anything, "missing", models.RulePublished, "").
+					Return(nil, gorm.ErrRecordNotFound)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().PublishRule(asRole(auth.Admin), "missing")
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.EqualError(t, err, "rule not found: missing")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+func TestRulesFile(t *testing.T) {
+	const file = `rules:
+  - id: rule-001
+    eventType: COURSE_COMPLETED
+    count: 5
+    conditions:
+      category: MATH
+    reward:
+      type: POINTS
+      amount: 100
+      description: Completed 5 math courses
+    enabled: true
+`
+	rule := models.Rule{
+		ID:                 "rule-001",
+		EventType:          "COURSE_COMPLETED",
+		Count:              5,
+		ConditionsCategory: ptrString("MATH"),
+		Reward:             models.Reward{Type: models.PointsReward, Amount: 100, Description: "Completed 5 math courses"},
+		Enabled:            true,
+	}
+
+	tests := []TestCase{
+		{
+			name: "export",
+			setupMocks: func(m *MockRuleRepository) {
+				active := rule
+				active.Status = models.RuleActive
+				m.On("GetUnarchivedRules", mock.Anything).Return([]models.Rule{active}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Query().ExportRules(context.Background(), nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, file, result)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "dry run",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("SyncRules", mock.Anything, []models.Rule{rule}, true, "").Return([]repository.RuleSyncChange{
+					{RuleID: "rule-001", Action: repository.RuleSyncUpdate, Diff: []models.FieldChange{
+						{Field: "count", From: ptrString("3"), To: ptrString("5")},
+					}},
+					{RuleID: "rule-002", Action: repository.RuleSyncDisable},
+				}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				dryRun := true
+				return r.Mutation().ApplyRules(context.Background(), file, nil, &dryRun, nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, []*model.RuleSyncChange{
+					{RuleID: "rule-001", Action: model.RuleSyncActionUpdate, Diff: []*model.FieldChange{
+						{Field: "count", From: ptrString("3"), To: ptrString("5")},
+					}},
+					{RuleID: "rule-002", Action: model.RuleSyncActionDisable, Diff: []*model.FieldChange{}},
+				}, result)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name: "apply JSON with comment",
+			setupMocks: func(m *MockRuleRepository) {
+				m.On("SyncRules", mock.Anything, []models.Rule{rule}, false, "abc123").Return([]repository.RuleSyncChange{}, nil)
+			},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				format := model.RulesFileFormatJSON
+				return r.Mutation().ApplyRules(context.Background(), `{"rules": [{
+					"id": "rule-001", "eventType": "COURSE_COMPLETED", "count": 5,
+					"conditions": {"category": "MATH"},
+					"reward": {"type": "POINTS", "amount": 100, "description": "Completed 5 math courses"},
+					"enabled": true
+				}]}`, &format, nil, ptrString("abc123"))
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, result)
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertExpectations(t)
+			},
+		},
+		{
+			name:       "invalid file is not applied",
+			setupMocks: func(m *MockRuleRepository) {},
+			runTest: func(r *resolver.Resolver) (interface{}, error) {
+				return r.Mutation().ApplyRules(context.Background(), "rules:\n  - id: rule-001\n    eventType: COURSE_COMPLETED\n", nil, nil, nil)
+			},
+			assertResult: func(t *testing.T, result interface{}, err error) {
+				assert.ErrorContains(t, err, "invalid rules file")
+			},
+			assertMocks: func(t *testing.T, m *MockRuleRepository) {
+				m.AssertNotCalled(t, "SyncRules", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			runTestCase(t, tc)
+		})
+	}
+}
+
+// catalogSource is a catalog.Source returning a fixed catalog or error
+type catalogSource struct {
+	catalog *catalog.Catalog
+	err     error
+}
+
+func (s catalogSource) Catalog(ctx context.Context) (*catalog.Catalog, error) {
+	return s.catalog, s.err
+}
+
+func TestAnalyzeRules(t *testing.T) {
+	current := []models.Rule{
+		{ID: "rule-001", EventType: "COURSE_COMPLETED", Count: 1, ConditionsCategory: ptrString("MATH"), Reward: models.Reward{Type: models.BadgeReward}},
+		{ID: "rule-002", EventType: "COURSE_COMPLETED", Count: 1, ConditionsCategory: ptrString("MATH"), Reward: models.Reward{Type: models.BadgeReward}},
+		{ID: "rule-003", EventType: "COURSE_COMPLETED", Count: 1, ConditionsCategory: ptrString("ART"), Reward: models.Reward{Type: models.BadgeReward}},
+	}
+
+	tests := []struct {
+		name           string
+		catalog        catalog.Source
+		kinds          []model.RuleFindingKind
+		catalogChecked bool
+	}{
+		{
+			name:           "with the catalog",
+			catalog:        catalogSource{catalog: &catalog.Catalog{Courses: map[string]int{"Math": 2}}},
+			kinds:          []model.RuleFindingKind{model.RuleFindingKindDuplicate, model.RuleFindingKindDuplicate, model.RuleFindingKindUnknownCategory},
+			catalogChecked: true,
+		},
+		{
+			name:    "catalog unavailable",
+			catalog: catalogSource{err: errors.New("connection refused")},
+			kinds:   []model.RuleFindingKind{model.RuleFindingKindDuplicate, model.RuleFindingKindDuplicate},
+		},
+		{
+			name:  "no catalog",
+			kinds: []model.RuleFindingKind{model.RuleFindingKindDuplicate, model.RuleFindingKindDuplicate},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mockRepo := setupTestResolver(t)
+			r.Catalog = tt.catalog
+			mockRepo.On("GetUnarchivedRules", mock.Anything).Return(current, nil)
+
+			analysis, err := r.Query().AnalyzeRules(asRole(auth.Viewer))
+			require.NoError(t, err)
+
+			kinds := make([]model.RuleFindingKind, len(analysis.Findings))
+			for i, f := range analysis.Findings {
+				kinds[i] = f.Kind
+			}
+			assert.Equal(t, tt.kinds, kinds)
+			assert.Equal(t, tt.catalogChecked, analysis.CatalogChecked)
+			assert.Equal(t, []string{"rule-002"}, analysis.Findings[0].RelatedRuleIds)
+		})
+	}
+}
+
+func TestCreateRuleWarnings(t *testing.T) {
+	r, mockRepo := setupTestResolver(t)
+	mockRepo.On("CreateRule", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Rule).ID = "rule-007"
+	}).Return(nil)
+	mockRepo.On("GetUnarchivedRules", mock.Anything).Return([]models.Rule{
+		{ID: "rule-001", EventType: "CHAPTER_COMPLETED", Count: 10, Reward: models.Reward{Type: models.PointsReward, Amount: 10}},
+		{ID: "rule-002", EventType: "COURSE_COMPLETED", Count: 1, Reward: models.Reward{Type: models.PointsReward, Amount: 5}},
+		{ID: "rule-003", EventType: "COURSE_COMPLETED", Count: 1, Reward: models.Reward{Type: models.PointsReward, Amount: 5}},
+		{ID: "rule-007", EventType: "CHAPTER_COMPLETED", Count: 10, Reward: models.Reward{Type: models.PointsReward, Amount: 10}},
+	}, nil)
+
+	rule, err := r.Mutation().CreateRule(asRole(auth.Editor), model.CreateRuleInput{
+		EventType: "CHAPTER_COMPLETED",
+		Count:     ptrInt(10),
+		Reward:    &model.RewardInput{Type: model.RewardTypePoints, Amount: ptrInt(10), Description: "10 chapters"},
+	})
+	require.NoError(t, err)
+
+	// Findings about the other rules are left out, and the pair of
+	// duplicates is reported once
+	require.Len(t, rule.Warnings, 1)
+	assert.Equal(t, "rule-007", rule.Warnings[0].RuleID)
+	assert.Equal(t, model.RuleFindingKindDuplicate, rule.Warnings[0].Kind)
+	assert.Equal(t, []string{"rule-001"}, rule.Warnings[0].RelatedRuleIds)
+	require.NotNil(t, rule.CatalogChecked)
+	assert.False(t, *rule.CatalogChecked)
+}
+
+func TestCreateRuleWarningsCatalog(t *testing.T) {
+	tests := []struct {
+		name           string
+		catalog        catalog.Source
+		kinds          []model.RuleFindingKind
+		catalogChecked bool
+	}{
+		{
+			name:           "with the catalog",
+			catalog:        catalogSource{catalog: &catalog.Catalog{Courses: map[string]int{"Math": 2}}},
+			kinds:          []model.RuleFindingKind{model.RuleFindingKindUnknownCategory},
+			catalogChecked: true,
+		},
+		{
+			name:    "catalog unavailable",
+			catalog: catalogSource{err: errors.New("connection refused")},
+			kinds:   []model.RuleFindingKind{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mockRepo := setupTestResolver(t)
+			r.Catalog = tt.catalog
+			mockRepo.On("CreateRule", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				args.Get(1).(*models.Rule).ID = "rule-007"
+			}).Return(nil)
+			mockRepo.On("GetUnarchivedRules", mock.Anything).Return([]models.Rule{}, nil)
+
+			rule, err := r.Mutation().CreateRule(asRole(auth.Editor), model.CreateRuleInput{
+				EventType:  "COURSE_COMPLETED",
+				Count:      ptrInt(1),
+				Conditions: &model.RuleConditionsInput{Category: ptrString("ART")},
+				Reward:     &model.RewardInput{Type: model.RewardTypeBadge, Description: "Art badge"},
+			})
+			require.NoError(t, err)
+
+			kinds := make([]model.RuleFindingKind, len(rule.Warnings))
+			for i, f := range rule.Warnings {
+				kinds[i] = f.Kind
+			}
+			assert.Equal(t, tt.kinds, kinds)
+			require.NotNil(t, rule.CatalogChecked)
+			assert.Equal(t, tt.catalogChecked, *rule.CatalogChecked)
+		})
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	next := func(ctx context.Context) (interface{}, error) {
+		return "resolved", nil
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		role     model.Role
+		expected error
+	}{
+		{name: "admin has editor", ctx: asRole(auth.Admin), role: model.RoleEditor},
+		{name: "viewer has viewer", ctx: asRole(auth.Viewer), role: model.RoleViewer},
+		{name: "editor lacks admin", ctx: asRole(auth.Editor), role: model.RoleAdmin, expected: &auth.ForbiddenError{Required: auth.Admin}},
+		{name: "unauthenticated", ctx: context.Background(), role: model.RoleViewer, expected: auth.ErrUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.HasRole(tt.ctx, nil, next, tt.role)
+			if tt.expected != nil {
+				assert.Equal(t, tt.expected, err)
+				assert.Nil(t, result)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "resolved", result)
+		})
+	}
+}
+
+// asRole returns a context authenticated with role
+func asRole(role auth.Role) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{Subject: "tester", Role: role})
+}
+
 // Helper function to create a pointer to an int
 func ptrInt(i int) *int {
 	return &i
@@ -6,11 +6,19 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/generated"
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/model"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rulesfile"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // CreateRule is the resolver for the createRule field.
@@ -29,7 +37,7 @@ func (r *mutationResolver) CreateRule(ctx context.Context, input model.CreateRul
 
 	r.Logger.Debug("Successfully created rule",
 		zap.String("ruleID", rule.ID))
-	return ConvertToGraphQLRule(rule), nil
+	return r.withWarnings(ctx, rule), nil
 }
 
 // UpdateRule is the resolver for the updateRule field.
@@ -38,48 +46,25 @@ func (r *mutationResolver) UpdateRule(ctx context.Context, id string, input mode
 		zap.String("ruleID", id),
 		zap.Any("input", input))
 
-	// First get the existing rule to update
-	existingRule, err := r.RuleRepository.GetRuleByID(ctx, id)
+	update, err := ConvertGraphQLRuleUpdate(&input)
 	if err != nil {
-		r.Logger.Debug("Failed to fetch existing rule",
-			zap.String("ruleID", id),
-			zap.Error(err))
-		return nil, fmt.Errorf("failed to fetch rule: %w", err)
-	}
-	if existingRule == nil {
-		r.Logger.Debug("Rule not found",
-			zap.String("ruleID", id))
-		return nil, fmt.Errorf("rule not found: %s", id)
-	}
-
-	// Apply updates
-	updates := ConvertGraphQLRuleToModel(&input)
-	if updates.EventType != "" {
-		existingRule.EventType = updates.EventType
-	}
-	if updates.Count > 0 {
-		existingRule.Count = updates.Count
-	}
-	if updates.ConditionsCategory != nil {
-		existingRule.ConditionsCategory = updates.ConditionsCategory
-	}
-	if updates.Reward.Type != "" {
-		existingRule.Reward.Type = updates.Reward.Type
-	}
-	if updates.Reward.Amount > 0 {
-		existingRule.Reward.Amount = updates.Reward.Amount
-	}
-	if updates.Reward.Description != "" {
-		existingRule.Reward.Description = updates.Reward.Description
-	}
-	if updates.Enabled {
-		existingRule.Enabled = updates.Enabled
+		return nil, err
 	}
 
-	if err := r.RuleRepository.UpdateRule(ctx, id, existingRule); err != nil {
+	// Editors change the rules that are not active only
+	allowActive := auth.Require(ctx, auth.Admin) == nil
+	if err := r.RuleRepository.UpdateRule(ctx, id, allowActive, update); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.Logger.Debug("Rule not found",
+				zap.String("ruleID", id))
+			return nil, fmt.Errorf("rule not found: %s", id)
+		}
+		if errors.Is(err, repository.ErrRuleActive) {
+			return nil, auth.RequireFor(ctx, auth.Admin, "change active rules")
+		}
 		r.Logger.Debug("Failed to update rule in repository",
 			zap.String("ruleID", id),
-			zap.Any("updatedRule", existingRule),
+			zap.Any("update", update),
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to update rule: %w", err)
 	}
@@ -92,24 +77,141 @@ func (r *mutationResolver) UpdateRule(ctx context.Context, id string, input mode
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch updated rule: %w", err)
 	}
+	if finalRule == nil {
+		return nil, fmt.Errorf("rule not found: %s", id)
+	}
 
 	r.Logger.Debug("Successfully updated rule",
 		zap.String("ruleID", id))
-	return ConvertToGraphQLRule(finalRule), nil
+	return r.withWarnings(ctx, finalRule), nil
+}
+
+// SubmitRule is the resolver for the submitRule field.
+func (r *mutationResolver) SubmitRule(ctx context.Context, id string, comment *string) (*model.Rule, error) {
+	return r.transition(ctx, id, models.RuleSubmitted, stringValue(comment))
+}
+
+// ApproveRule is the resolver for the approveRule field.
+func (r *mutationResolver) ApproveRule(ctx context.Context, id string, comment *string) (*model.Rule, error) {
+	return r.transition(ctx, id, models.RuleApproval, stringValue(comment))
+}
+
+// RejectRule is the resolver for the rejectRule field.
+func (r *mutationResolver) RejectRule(ctx context.Context, id string, comment string) (*model.Rule, error) {
+	if strings.TrimSpace(comment) == "" {
+		return nil, fmt.Errorf("comment is required to reject a rule")
+	}
+	return r.transition(ctx, id, models.RuleRejected, comment)
+}
+
+// PublishRule is the resolver for the publishRule field.
+func (r *mutationResolver) PublishRule(ctx context.Context, id string) (*model.Rule, error) {
+	return r.transition(ctx, id, models.RulePublished, "")
+}
+
+// DeleteRule is the resolver for the deleteRule field.
+func (r *mutationResolver) DeleteRule(ctx context.Context, id string) (bool, error) {
+	if err := r.RuleRepository.DeleteRule(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, fmt.Errorf("rule not found: %s", id)
+		}
+		return false, fmt.Errorf("failed to delete rule: %w", err)
+	}
+	r.Logger.Debug("Deleted rule", zap.String("ruleID", id))
+	return true, nil
+}
+
+// ArchiveRule is the resolver for the archiveRule field.
+func (r *mutationResolver) ArchiveRule(ctx context.Context, id string) (*model.Rule, error) {
+	rule, err := r.RuleRepository.ArchiveRule(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("rule not found: %s", id)
+		}
+		return nil, fmt.Errorf("failed to archive rule: %w", err)
+	}
+	r.Logger.Debug("Archived rule", zap.String("ruleID", id))
+	return ConvertToGraphQLRule(rule), nil
+}
+
+// RevertRule is the resolver for the revertRule field.
+func (r *mutationResolver) RevertRule(ctx context.Context, id string, version int) (*model.Rule, error) {
+	rule, err := r.RuleRepository.RevertRule(ctx, id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, fmt.Errorf("rule not found: %s", id)
+		case errors.Is(err, repository.ErrRuleVersionNotFound):
+			return nil, fmt.Errorf("rule %s has no version %d", id, version)
+		case errors.Is(err, repository.ErrRuleArchived):
+			return nil, fmt.Errorf("rule %s is archived", id)
+		}
+		return nil, fmt.Errorf("failed to revert rule: %w", err)
+	}
+	r.Logger.Debug("Reverted rule",
+		zap.String("ruleID", id),
+		zap.Int("toVersion", version),
+		zap.Int("version", rule.Version))
+	return ConvertToGraphQLRule(rule), nil
+}
+
+// ApplyRules is the resolver for the applyRules field.
+func (r *mutationResolver) ApplyRules(ctx context.Context, file string, format *model.RulesFileFormat, dryRun *bool, comment *string) ([]*model.RuleSyncChange, error) {
+	rules, err := ParseRulesFile(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	apply := dryRun == nil || !*dryRun
+	changes, err := r.RuleRepository.SyncRules(ctx, rules, !apply, stringValue(comment))
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rules: %w", err)
+	}
+	r.Logger.Debug("Applied rules file",
+		zap.Bool("dryRun", !apply),
+		zap.Int("rules", len(rules)),
+		zap.Int("changes", len(changes)))
+	return ConvertToGraphQLRuleSyncChanges(changes), nil
 }
 
 // Rules is the resolver for the rules field.
-func (r *queryResolver) Rules(ctx context.Context) ([]*model.Rule, error) {
-	rules, err := r.RuleRepository.GetEnabledRules(ctx)
+func (r *queryResolver) Rules(ctx context.Context, filter *model.RuleFilter, sort *model.RuleSort, first *int, after *string) (*model.RuleConnection, error) {
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	}
+	cursor := ""
+	if after != nil {
+		cursor = *after
+	}
+
+	order := ConvertGraphQLRuleSort(sort)
+	page, err := r.RuleRepository.ListRules(ctx, ConvertGraphQLRuleFilter(filter), order, limit, cursor)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, fmt.Errorf("invalid after cursor: %s", cursor)
+		}
 		return nil, fmt.Errorf("failed to fetch rules: %w", err)
 	}
 
-	result := make([]*model.Rule, len(rules))
-	for i, rule := range rules {
-		result[i] = ConvertToGraphQLRule(&rule)
+	conn := &model.RuleConnection{
+		Edges:      make([]*model.RuleEdge, len(page.Rules)),
+		PageInfo:   &model.PageInfo{HasNextPage: page.HasNextPage},
+		TotalCount: int(page.TotalCount),
 	}
-	return result, nil
+	for i, rule := range page.Rules {
+		conn.Edges[i] = &model.RuleEdge{
+			Cursor: repository.RuleCursor(rule, order),
+			Node:   ConvertToGraphQLRule(&rule),
+		}
+	}
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+	return conn, nil
 }
 
 // Rule is the resolver for the rule field.
@@ -124,6 +226,92 @@ func (r *queryResolver) Rule(ctx context.Context, id string) (*model.Rule, error
 	return ConvertToGraphQLRule(rule), nil
 }
 
+// ExplainRule is the resolver for the explainRule field.
+func (r *queryResolver) ExplainRule(ctx context.Context, userID string, ruleID string) (*model.RuleExplanation, error) {
+	if r.Engine == nil || r.Ledger == nil {
+		return nil, fmt.Errorf("rule explanations are not available")
+	}
+
+	rule, err := r.RuleRepository.GetRuleByID(ctx, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rule: %w", err)
+	}
+	if rule == nil {
+		return nil, nil
+	}
+
+	granted, err := r.Ledger.Get(ctx, userID, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch granted reward: %w", err)
+	}
+	var grantedAt *time.Time
+	if granted != nil {
+		grantedAt = &granted.GrantedAt
+	}
+
+	explanation, err := r.Engine.Explain(ctx, *rule, userID, grantedAt)
+	if err != nil {
+		r.Logger.Debug("Failed to explain rule",
+			zap.String("ruleID", ruleID),
+			zap.String("userID", userID),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to explain rule: %w", err)
+	}
+	return ConvertToGraphQLExplanation(explanation), nil
+}
+
+// RuleHistory is the resolver for the ruleHistory field.
+func (r *queryResolver) RuleHistory(ctx context.Context, id string) ([]*model.RuleVersion, error) {
+	versions, err := r.RuleRepository.GetRuleHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rule history: %w", err)
+	}
+	return ConvertToGraphQLRuleHistory(versions), nil
+}
+
+// RuleVersion is the resolver for the ruleVersion field.
+func (r *queryResolver) RuleVersion(ctx context.Context, id string, version int) (*model.RuleVersion, error) {
+	history, err := r.RuleHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range history {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// ExportRules is the resolver for the exportRules field.
+func (r *queryResolver) ExportRules(ctx context.Context, format *model.RulesFileFormat) (string, error) {
+	rules, err := r.RuleRepository.GetUnarchivedRules(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch rules: %w", err)
+	}
+	data, err := rulesfile.Marshal(rulesfile.FromModels(rules), ConvertGraphQLRulesFileFormat(format))
+	if err != nil {
+		return "", fmt.Errorf("failed to export rules: %w", err)
+	}
+	return string(data), nil
+}
+
+// AnalyzeRules is the resolver for the analyzeRules field.
+func (r *queryResolver) AnalyzeRules(ctx context.Context) (*model.RuleAnalysis, error) {
+	findings, catalogChecked, err := r.analyze(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.RuleAnalysis{
+		Findings:       make([]*model.RuleFinding, len(findings)),
+		CatalogChecked: catalogChecked,
+	}
+	for i, f := range findings {
+		result.Findings[i] = ConvertToGraphQLFinding(f)
+	}
+	return result, nil
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
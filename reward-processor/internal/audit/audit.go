@@ -0,0 +1,48 @@
+// Package audit identifies who makes the changes recorded in the history of
+// rules.
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// AuthorHeader is the request header naming the author of the changes made
+// by a request
+const AuthorHeader = "X-Author"
+
+// Anonymous is the author of changes made without one
+const Anonymous = "anonymous"
+
+// maxAuthorLength bounds the author names stored in the history
+const maxAuthorLength = 255
+
+type authorKey struct{}
+
+// WithAuthor returns ctx with the author of the changes made in it
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// Author returns the author of the changes made in ctx, or Anonymous
+func Author(ctx context.Context) string {
+	if author, ok := ctx.Value(authorKey{}).(string); ok && author != "" {
+		return author
+	}
+	return Anonymous
+}
+
+// Middleware sets the author of a request's changes from its AuthorHeader
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		author := strings.TrimSpace(r.Header.Get(AuthorHeader))
+		if len(author) > maxAuthorLength {
+			author = author[:maxAuthorLength]
+		}
+		if author != "" {
+			r = r.WithContext(WithAuthor(r.Context(), author))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthor(t *testing.T) {
+	assert.Equal(t, Anonymous, Author(context.Background()))
+	assert.Equal(t, Anonymous, Author(WithAuthor(context.Background(), "")))
+	assert.Equal(t, "alice", Author(WithAuthor(context.Background(), "alice")))
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{name: "no header", header: "", expected: Anonymous},
+		{name: "header", header: " alice ", expected: "alice"},
+		{name: "long header", header: strings.Repeat("a", 300), expected: strings.Repeat("a", maxAuthorLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var author string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				author = Author(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/query", nil)
+			if tt.header != "" {
+				req.Header.Set(AuthorHeader, tt.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.expected, author)
+		})
+	}
+}
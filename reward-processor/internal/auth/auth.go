@@ -0,0 +1,104 @@
+// Package auth authenticates the users of the rules API with JWTs and
+// authorizes them by role.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Role is the role of a user of the rules API. Each role has the
+// permissions of the roles below it.
+type Role string
+
+const (
+	// Viewer reads rules
+	Viewer Role = "VIEWER"
+	// Editor drafts rules: creates them and updates the disabled ones
+	Editor Role = "EDITOR"
+	// Admin enables, archives, deletes and reverts rules
+	Admin Role = "ADMIN"
+)
+
+// rank orders the roles; unknown roles have none
+func (r Role) rank() int {
+	switch r {
+	case Viewer:
+		return 1
+	case Editor:
+		return 2
+	case Admin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Includes reports whether r has the permissions of role
+func (r Role) Includes(role Role) bool {
+	return role.rank() > 0 && r.rank() >= role.rank()
+}
+
+// ParseRole parses a role name, ignoring case
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToUpper(s))
+	if role.rank() == 0 {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Principal is an authenticated user
+type Principal struct {
+	Subject string
+	Role    Role
+}
+
+// ErrUnauthenticated is returned when a request carries no principal
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ForbiddenError is returned when a principal lacks the role an operation
+// requires
+type ForbiddenError struct {
+	Required Role
+	Action   string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Action != "" {
+		return fmt.Sprintf("forbidden: only %s users can %s", strings.ToLower(string(e.Required)), e.Action)
+	}
+	return fmt.Sprintf("forbidden: requires role %s", e.Required)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx with the authenticated principal
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal of ctx, if any
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Require returns an error unless the principal of ctx has role
+func Require(ctx context.Context, role Role) error {
+	return RequireFor(ctx, role, "")
+}
+
+// RequireFor is Require naming the action in the error
+func RequireFor(ctx context.Context, role Role, action string) error {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !p.Role.Includes(role) {
+		return &ForbiddenError{Required: role, Action: action}
+	}
+	return nil
+}
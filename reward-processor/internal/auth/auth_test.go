@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/audit"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte(strings.Repeat("s", minSecretLength))
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	a, err := NewAuthenticator(Config{Secret: testSecret, Issuer: "learning-rewards"})
+	require.NoError(t, err)
+	return a
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, Admin.Includes(Editor))
+	assert.True(t, Editor.Includes(Editor))
+	assert.True(t, Editor.Includes(Viewer))
+	assert.False(t, Editor.Includes(Admin))
+	assert.False(t, Viewer.Includes(Editor))
+	assert.False(t, Role("OWNER").Includes(Viewer))
+	assert.False(t, Admin.Includes(Role("")))
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("editor")
+	require.NoError(t, err)
+	assert.Equal(t, Editor, role)
+
+	_, err = ParseRole("owner")
+	assert.Error(t, err)
+}
+
+func TestRequire(t *testing.T) {
+	assert.ErrorIs(t, Require(context.Background(), Viewer), ErrUnauthenticated)
+
+	ctx := WithPrincipal(context.Background(), Principal{Subject: "alice", Role: Editor})
+	assert.NoError(t, Require(ctx, Editor))
+	assert.EqualError(t, Require(ctx, Admin), "forbidden: requires role ADMIN")
+	assert.EqualError(t, RequireFor(ctx, Admin, "enable rules"), "forbidden: only admin users can enable rules")
+}
+
+func TestNewAuthenticatorRejectsShortSecret(t *testing.T) {
+	_, err := NewAuthenticator(Config{Secret: []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	sign := func(c claims, secret []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		require.NoError(t, err)
+		return token
+	}
+	valid := func() claims {
+		return claims{
+			Role: "admin",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "alice",
+				Issuer:    "learning-rewards",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+	}
+
+	issued, err := a.Issue(Principal{Subject: "bob", Role: Editor}, time.Hour)
+	require.NoError(t, err)
+	p, err := a.Authenticate(issued)
+	require.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "bob", Role: Editor}, p)
+
+	p, err = a.Authenticate(sign(valid(), testSecret))
+	require.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "alice", Role: Admin}, p)
+
+	_, err = a.Authenticate("")
+	assert.ErrorIs(t, err, ErrMissingToken)
+
+	invalid := map[string]func() string{
+		"wrong secret": func() string {
+			return sign(valid(), []byte(strings.Repeat("x", minSecretLength)))
+		},
+		"expired": func() string {
+			c := valid()
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+			return sign(c, testSecret)
+		},
+		"no expiry": func() string {
+			c := valid()
+			c.ExpiresAt = nil
+			return sign(c, testSecret)
+		},
+		"wrong issuer": func() string {
+			c := valid()
+			c.Issuer = "someone-else"
+			return sign(c, testSecret)
+		},
+		"no subject": func() string {
+			c := valid()
+			c.Subject = ""
+			return sign(c, testSecret)
+		},
+		"unknown role": func() string {
+			c := valid()
+			c.Role = "owner"
+			return sign(c, testSecret)
+		},
+		"unsigned": func() string {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			require.NoError(t, err)
+			return token
+		},
+		"malformed": func() string { return "not-a-jwt" },
+	}
+	for name, token := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := a.Authenticate(token())
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	var principal Principal
+	var author string
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFrom(r.Context())
+		author = audit.Author(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/query", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+
+	token, err := a.Issue(Principal{Subject: "alice", Role: Viewer}, time.Hour)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(audit.AuthorHeader, "mallory")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Principal{Subject: "alice", Role: Viewer}, principal)
+	assert.Equal(t, "alice", author, "the author is the subject, not the header")
+}
+
+func TestAllowAll(t *testing.T) {
+	var principal Principal
+	handler := AllowAll(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set(audit.AuthorHeader, "alice")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, Principal{Subject: "alice", Role: Admin}, principal)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/audit"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minSecretLength is the minimum length of an HS256 secret, the size of
+// the hash
+const minSecretLength = 32
+
+var (
+	// ErrMissingToken is returned when a request carries no bearer token
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken is returned for tokens that are malformed, expired,
+	// wrongly signed or without a subject and role
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Config configures the validation of tokens
+type Config struct {
+	// Secret is the HS256 key tokens are signed with
+	Secret []byte
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string
+	Audience string
+}
+
+// claims are the claims of a token. The role claim holds the role of the
+// subject.
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator validates the JWTs of the users of the API
+type Authenticator struct {
+	cfg    Config
+	parser *jwt.Parser
+}
+
+// NewAuthenticator creates an authenticator accepting HS256 tokens signed
+// with the configured secret
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+	if len(cfg.Secret) < minSecretLength {
+		return nil, fmt.Errorf("JWT secret must be at least %d bytes", minSecretLength)
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &Authenticator{cfg: cfg, parser: jwt.NewParser(opts...)}, nil
+}
+
+// Authenticate validates a token and returns its principal
+func (a *Authenticator) Authenticate(token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, ErrMissingToken
+	}
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return a.cfg.Secret, nil
+	}); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if c.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return Principal{Subject: c.Subject, Role: role}, nil
+}
+
+// Issue signs a token for p valid for ttl
+func (a *Authenticator) Issue(p Principal, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c := claims{
+		Role: string(p.Role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   p.Subject,
+			Issuer:    a.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if a.cfg.Audience != "" {
+		c.Audience = jwt.ClaimStrings{a.cfg.Audience}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(a.cfg.Secret)
+}
+
+// Middleware rejects requests without a valid bearer token. The principal
+// of the token is stored in the request context, and its subject is the
+// author of the changes the request makes.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(bearerToken(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="reward-processor"`)
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx := audit.WithAuthor(WithPrincipal(r.Context(), p), p.Subject)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AllowAll is the middleware used when authentication is disabled: every
+// request is an admin, and the author of its changes comes from the
+// audit.AuthorHeader
+func AllowAll(next http.Handler) http.Handler {
+	return audit.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := Principal{Subject: audit.Author(r.Context()), Role: Admin}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	}))
+}
+
+// bearerToken returns the bearer token of the Authorization header
+func bearerToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
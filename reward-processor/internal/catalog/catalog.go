@@ -0,0 +1,164 @@
+// Package catalog reads the course catalog of catalog-api, which rules are
+// checked against.
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Catalog is the categories of the course catalog and their courses
+type Catalog struct {
+	// Courses is the number of courses of each category, by name
+	Courses map[string]int
+}
+
+// Category returns the name of category in the catalog, compared
+// case-insensitively since events carry categories in upper case, and its
+// number of courses. It returns false if the catalog has no such category.
+func (c *Catalog) Category(category string) (name string, courses int, ok bool) {
+	for name, courses := range c.Courses {
+		if strings.EqualFold(name, category) {
+			return name, courses, true
+		}
+	}
+	return "", 0, false
+}
+
+// Categories returns the names of the categories of the catalog, sorted
+func (c *Catalog) Categories() []string {
+	names := make([]string, 0, len(c.Courses))
+	for name := range c.Courses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// catalogQuery asks catalog-api for every category and the category of every
+// course
+const catalogQuery = `{ categories { name } courses { category { name } } }`
+
+// catalogResponse mirrors the GraphQL response of catalogQuery
+type catalogResponse struct {
+	Data struct {
+		Categories []struct {
+			Name string `json:"name"`
+		} `json:"categories"`
+		Courses []struct {
+			Category struct {
+				Name string `json:"name"`
+			} `json:"category"`
+		} `json:"courses"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Source provides the catalog
+type Source interface {
+	Catalog(ctx context.Context) (*Catalog, error)
+}
+
+// Client reads the catalog from the GraphQL API of catalog-api
+type Client struct {
+	// BaseURL is the address of catalog-api, e.g. http://catalog-api:8080
+	BaseURL string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+var _ Source = (*Client)(nil)
+
+// Catalog fetches the catalog
+func (c *Client) Catalog(ctx context.Context) (*Catalog, error) {
+	body, err := json.Marshal(map[string]string{"query": catalogQuery})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+"/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch catalog: unexpected status %s", resp.Status)
+	}
+
+	var result catalogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		errs := make([]error, len(result.Errors))
+		for i, e := range result.Errors {
+			errs[i] = errors.New(e.Message)
+		}
+		return nil, fmt.Errorf("failed to fetch catalog: %w", errors.Join(errs...))
+	}
+
+	catalog := &Catalog{Courses: make(map[string]int, len(result.Data.Categories))}
+	for _, category := range result.Data.Categories {
+		catalog.Courses[category.Name] = 0
+	}
+	for _, course := range result.Data.Courses {
+		catalog.Courses[course.Category.Name]++
+	}
+	return catalog, nil
+}
+
+// Cache serves the catalog of Source for TTL after fetching it. A failed
+// fetch is kept for TTL too, so that a catalog-api that is down is not waited
+// for on every call; a fetch ended by the caller's context is not.
+type Cache struct {
+	Source Source
+	TTL    time.Duration
+
+	mu        sync.Mutex
+	catalog   *Catalog
+	err       error
+	fetchedAt time.Time
+}
+
+var _ Source = (*Cache)(nil)
+
+// NewCache creates a cache of the catalog of source
+func NewCache(source Source, ttl time.Duration) *Cache {
+	return &Cache{Source: source, TTL: ttl}
+}
+
+// Catalog returns the cached catalog, fetching it when it expired
+func (c *Cache) Catalog(ctx context.Context) (*Catalog, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < c.TTL {
+		return c.catalog, c.err
+	}
+
+	catalog, err := c.Source.Catalog(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	c.catalog, c.err, c.fetchedAt = catalog, err, time.Now()
+	return catalog, err
+}
@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCatalog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/query", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, catalogQuery, body["query"])
+
+		w.Write([]byte(`{"data": {
+			"categories": [{"name": "Math"}, {"name": "Science"}, {"name": "Art"}],
+			"courses": [
+				{"category": {"name": "Math"}},
+				{"category": {"name": "Math"}},
+				{"category": {"name": "Science"}}
+			]
+		}}`))
+	}))
+	defer server.Close()
+
+	cat, err := (&Client{BaseURL: server.URL + "/"}).Catalog(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"Math": 2, "Science": 1, "Art": 0}, cat.Courses)
+	assert.Equal(t, []string{"Art", "Math", "Science"}, cat.Categories())
+
+	name, courses, ok := cat.Category("MATH")
+	assert.True(t, ok)
+	assert.Equal(t, "Math", name)
+	assert.Equal(t, 2, courses)
+
+	_, _, ok = cat.Category("PROGRAMMING")
+	assert.False(t, ok)
+}
+
+func TestClientCatalogErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		err    string
+	}{
+		{name: "status", status: http.StatusBadGateway, err: "unexpected status 502 Bad Gateway"},
+		{name: "GraphQL errors", status: http.StatusOK, body: `{"errors": [{"message": "database unavailable"}]}`, err: "database unavailable"},
+		{name: "invalid body", status: http.StatusOK, body: `<html>`, err: "failed to decode catalog"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := (&Client{BaseURL: server.URL}).Catalog(context.Background())
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+// countingSource is a Source returning a fixed catalog or error, counting
+// its calls
+type countingSource struct {
+	catalog *Catalog
+	err     error
+	calls   int
+}
+
+func (s *countingSource) Catalog(ctx context.Context) (*Catalog, error) {
+	s.calls++
+	return s.catalog, s.err
+}
+
+func TestCache(t *testing.T) {
+	source := &countingSource{catalog: &Catalog{Courses: map[string]int{"Math": 2}}}
+	cache := NewCache(source, time.Hour)
+
+	for range 2 {
+		cat, err := cache.Catalog(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, source.catalog, cat)
+	}
+	assert.Equal(t, 1, source.calls)
+
+	// An expired catalog is fetched again
+	cache.fetchedAt = time.Now().Add(-2 * time.Hour)
+	_, err := cache.Catalog(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, source.calls)
+}
+
+func TestCacheErrors(t *testing.T) {
+	source := &countingSource{err: errors.New("connection refused")}
+	cache := NewCache(source, time.Hour)
+
+	// A fetch ended by the context is not kept
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.Catalog(ctx)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.True(t, cache.fetchedAt.IsZero())
+
+	// Other failures are
+	for range 2 {
+		_, err = cache.Catalog(context.Background())
+		assert.ErrorContains(t, err, "connection refused")
+	}
+	assert.Equal(t, 2, source.calls)
+}
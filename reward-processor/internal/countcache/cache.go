@@ -0,0 +1,449 @@
+// Package countcache keeps the event counts of active users in memory and
+// writes them to the database in batches.
+package countcache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Config holds the cache configuration
+type Config struct {
+	// Size is the number of user event types kept in memory; zero disables
+	// the cache
+	Size int
+	// Shards is the number of independently locked parts of the cache
+	Shards int
+	// FlushInterval is the time between two writes to the database
+	FlushInterval time.Duration
+	// FlushSize is the number of pending increments that triggers a write
+	// before FlushInterval
+	FlushSize int
+}
+
+// DefaultConfig returns the default cache configuration, with the cache
+// disabled
+func DefaultConfig() Config {
+	return Config{
+		Shards:        16,
+		FlushInterval: time.Second,
+		FlushSize:     1000,
+	}
+}
+
+// Enabled reports whether the configuration enables the cache
+func (c Config) Enabled() bool {
+	return c.Size > 0
+}
+
+// errNotOwned is returned for a message whose partition was revoked while it
+// was handled
+var errNotOwned = errors.New("partition is no longer owned")
+
+// Ensure Cache implements UserEventRepository and PartitionObserver
+var (
+	_ repository.UserEventRepository = (*Cache)(nil)
+	_ kafka.PartitionObserver        = (*Cache)(nil)
+)
+
+// Cache is a write-behind cache of event counts.
+//
+// It only caches the users of the partitions it owns, as reported by the
+// consumer group, and relies on events being keyed by user: the owner of a
+// partition is then the only writer of its users' counts. Events without an
+// owned position are counted in the database directly.
+//
+// For the partitions it owns the cache also stores the watermarks, in the
+// same transaction as the counts. A batch holds the increments of the
+// messages before the watermark only, so after a crash the consumer resumes
+// right after the last counted message.
+type Cache struct {
+	store   repository.CountStore
+	offsets repository.OffsetRepository
+	tx      repository.Transactor
+	group   string
+	cfg     Config
+	logger  *zap.Logger
+
+	shards  []*shard
+	pending atomic.Int64
+	flushCh chan struct{}
+
+	// flushMu serializes flushes, and revocations
+	flushMu sync.Mutex
+	// mu guards partitions. It is taken after a shard lock, never before.
+	mu         sync.Mutex
+	partitions map[partitionKey]*partition
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partition is the state of an owned partition
+type partition struct {
+	watermark int64
+	saved     int64
+	// increments are the increments not written yet, by message offset
+	increments map[int64]countKey
+	// processed are the messages after the watermark that were processed
+	// before the cache owned the partition
+	processed map[int64]bool
+}
+
+type countKey struct {
+	entryKey
+	category string
+}
+
+// New creates a count cache in front of store. Watermarks are saved in
+// offsets, for the consumer group group.
+func New(store repository.CountStore, offsets repository.OffsetRepository, tx repository.Transactor, group string, cfg Config, logger *zap.Logger) *Cache {
+	if cfg.Shards <= 0 {
+		cfg.Shards = 1
+	}
+	perShard := (cfg.Size + cfg.Shards - 1) / cfg.Shards
+	c := &Cache{
+		store:      store,
+		offsets:    offsets,
+		tx:         tx,
+		group:      group,
+		cfg:        cfg,
+		logger:     logger,
+		shards:     make([]*shard, cfg.Shards),
+		flushCh:    make(chan struct{}, 1),
+		partitions: make(map[partitionKey]*partition),
+	}
+	for i := range c.shards {
+		c.shards[i] = newShard(perShard)
+	}
+	return c
+}
+
+// Increment implements UserEventRepository. Increments of an owned partition
+// are applied in memory once per message, so a handler retrying a message
+// gets the same counts back.
+func (c *Cache) Increment(ctx context.Context, userID, eventType, category string) (repository.EventCounts, error) {
+	key := entryKey{userID: userID, eventType: eventType}
+	s := c.shard(userID)
+
+	pos, ok := kafka.PositionFrom(ctx)
+	if !ok || !c.Owns(pos) {
+		counts, err := c.store.Increment(ctx, userID, eventType, category)
+		if err != nil {
+			return counts, err
+		}
+		s.mu.Lock()
+		if e := s.get(key); e != nil {
+			// The database was incremented too, so the entry stays in sync
+			e.counts[category]++
+		}
+		s.mu.Unlock()
+		return counts, nil
+	}
+
+	e, err := c.entry(ctx, s, key)
+	if err != nil {
+		return repository.EventCounts{}, err
+	}
+	defer s.mu.Unlock()
+
+	c.mu.Lock()
+	part := c.partitions[partitionKey{pos.Topic, pos.Partition}]
+	if part == nil {
+		// Revoked since Owns: the message is redelivered to the next owner
+		c.mu.Unlock()
+		return repository.EventCounts{}, errNotOwned
+	}
+	if _, retried := part.increments[pos.Offset]; !retried {
+		part.increments[pos.Offset] = countKey{entryKey: key, category: category}
+		e.counts[category]++
+		e.dirty++
+		if c.pending.Add(1) >= int64(c.cfg.FlushSize) {
+			c.requestFlush()
+		}
+	}
+	c.mu.Unlock()
+
+	return e.eventCounts(category), nil
+}
+
+// GetCount implements UserEventRepository
+func (c *Cache) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
+	s := c.shard(userID)
+	s.mu.Lock()
+	if e := s.get(entryKey{userID: userID, eventType: eventType}); e != nil {
+		counts := e.eventCounts(category)
+		s.mu.Unlock()
+		if category == "" {
+			return counts.Total, nil
+		}
+		return counts.Category, nil
+	}
+	s.mu.Unlock()
+	return c.store.GetCount(ctx, userID, eventType, category)
+}
+
+// entry returns the entry of key, loading it on a miss, with its shard
+// locked
+func (c *Cache) entry(ctx context.Context, s *shard, key entryKey) (*entry, error) {
+	s.mu.Lock()
+	if e := s.get(key); e != nil {
+		return e, nil
+	}
+	s.mu.Unlock()
+
+	counts, err := c.store.Counts(ctx, key.userID, key.eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if e := s.get(key); e != nil {
+		return e, nil
+	}
+	return s.add(key, counts), nil
+}
+
+func (c *Cache) shard(userID string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// Owns reports whether the cache counts the message at pos
+func (c *Cache) Owns(pos kafka.Position) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.partitions[partitionKey{pos.Topic, pos.Partition}]
+	return ok
+}
+
+// Processed reports whether the message at pos was processed before the
+// cache owned its partition, in which case its count is already stored
+func (c *Cache) Processed(pos kafka.Position) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	part := c.partitions[partitionKey{pos.Topic, pos.Partition}]
+	return part != nil && part.processed[pos.Offset]
+}
+
+// Assigned implements PartitionObserver
+func (c *Cache) Assigned(ctx context.Context, claims map[string][]int32) error {
+	for topic, partitions := range claims {
+		processed, err := c.offsets.Processed(ctx, c.group, topic)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		for _, p := range partitions {
+			part := &partition{
+				increments: make(map[int64]countKey),
+				processed:  make(map[int64]bool),
+			}
+			for _, offset := range processed[p] {
+				part.processed[offset] = true
+			}
+			c.partitions[partitionKey{topic, p}] = part
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// Advanced implements PartitionObserver
+func (c *Cache) Advanced(topic string, partition int32, watermark int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if part := c.partitions[partitionKey{topic, partition}]; part != nil && watermark > part.watermark {
+		part.watermark = watermark
+	}
+}
+
+// Revoked implements PartitionObserver. It writes what it can, then forgets
+// everything: another member may update the counts of these users next.
+// Increments after the watermarks are dropped, since their messages are
+// consumed again.
+func (c *Cache) Revoked(ctx context.Context, claims map[string][]int32) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	err := c.flush(ctx)
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.reset()
+		s.mu.Unlock()
+	}
+	c.mu.Lock()
+	c.partitions = make(map[partitionKey]*partition)
+	c.mu.Unlock()
+	c.pending.Store(0)
+
+	return err
+}
+
+// Run writes pending increments every FlushInterval, or sooner once
+// FlushSize are pending, until ctx is done
+func (c *Cache) Run(ctx context.Context) {
+	c.logger.Info("Starting count cache",
+		zap.Int("size", c.cfg.Size),
+		zap.Int("shards", len(c.shards)),
+		zap.Duration("flush_interval", c.cfg.FlushInterval))
+
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.Flush(context.WithoutCancel(ctx)); err != nil {
+				c.logger.Error("Failed to flush counts", zap.Error(err))
+			}
+			c.logger.Info("Count cache stopped")
+			return
+		case <-ticker.C:
+		case <-c.flushCh:
+		}
+		if err := c.Flush(ctx); err != nil {
+			c.logger.Error("Failed to flush counts, retrying on next flush", zap.Error(err))
+		}
+	}
+}
+
+func (c *Cache) requestFlush() {
+	select {
+	case c.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// Flush writes the increments of the messages before the watermarks, and the
+// watermarks, in one transaction
+func (c *Cache) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	return c.flush(ctx)
+}
+
+type batch struct {
+	deltas     map[countKey]int
+	increments map[partitionKey]map[int64]countKey
+	watermarks map[partitionKey]int64
+}
+
+func (c *Cache) flush(ctx context.Context) error {
+	b := c.takeBatch()
+	if len(b.watermarks) == 0 {
+		return nil
+	}
+
+	deltas := make([]repository.CountDelta, 0, len(b.deltas))
+	for key, delta := range b.deltas {
+		deltas = append(deltas, repository.CountDelta{
+			UserID:    key.userID,
+			EventType: key.eventType,
+			Category:  key.category,
+			Delta:     delta,
+		})
+	}
+
+	err := c.tx.RunInTx(ctx, func(ctx context.Context) error {
+		if err := c.store.AddCounts(ctx, deltas); err != nil {
+			return err
+		}
+		for key, watermark := range b.watermarks {
+			if err := c.offsets.Save(ctx, c.group, key.topic, key.partition, watermark); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.restoreBatch(b)
+		return err
+	}
+
+	c.mu.Lock()
+	for key, watermark := range b.watermarks {
+		if part := c.partitions[key]; part != nil {
+			part.saved = watermark
+		}
+	}
+	c.mu.Unlock()
+
+	written := 0
+	dirty := make(map[entryKey]int)
+	for key, delta := range b.deltas {
+		dirty[key.entryKey] += delta
+		written += delta
+	}
+	for key, n := range dirty {
+		s := c.shard(key.userID)
+		s.mu.Lock()
+		if e := s.entries[key]; e != nil {
+			e.dirty -= n
+		}
+		s.mu.Unlock()
+	}
+	c.pending.Add(-int64(written))
+
+	c.logger.Debug("Flushed counts",
+		zap.Int("increments", written),
+		zap.Int("counts", len(deltas)),
+		zap.Int("partitions", len(b.watermarks)))
+	return nil
+}
+
+// takeBatch removes the increments before the watermarks from the pending
+// ones
+func (c *Cache) takeBatch() batch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := batch{
+		deltas:     make(map[countKey]int),
+		increments: make(map[partitionKey]map[int64]countKey),
+		watermarks: make(map[partitionKey]int64),
+	}
+	for pk, part := range c.partitions {
+		if part.watermark <= part.saved {
+			continue
+		}
+		b.watermarks[pk] = part.watermark
+		taken := make(map[int64]countKey)
+		for offset, key := range part.increments {
+			if offset < part.watermark {
+				taken[offset] = key
+				b.deltas[key]++
+				delete(part.increments, offset)
+			}
+		}
+		b.increments[pk] = taken
+	}
+	return b
+}
+
+// restoreBatch puts the increments of a failed batch back
+func (c *Cache) restoreBatch(b batch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for pk, increments := range b.increments {
+		part := c.partitions[pk]
+		if part == nil {
+			continue
+		}
+		for offset, key := range increments {
+			part.increments[offset] = key
+		}
+	}
+}
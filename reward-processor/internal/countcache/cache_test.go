@@ -0,0 +1,231 @@
+package countcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testTopic = "learning-events"
+
+// memoryStore is an in-memory CountStore and OffsetRepository
+type memoryStore struct {
+	counts    map[countKey]int
+	offsets   map[int32]int64
+	processed map[int32][]int64
+	loads     int
+	addErr    error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		counts:    map[countKey]int{},
+		offsets:   map[int32]int64{},
+		processed: map[int32][]int64{},
+	}
+}
+
+func (m *memoryStore) Increment(ctx context.Context, userID, eventType, category string) (repository.EventCounts, error) {
+	m.counts[countKey{entryKey{userID, eventType}, category}]++
+	total, _ := m.GetCount(ctx, userID, eventType, "")
+	return repository.EventCounts{Category: m.counts[countKey{entryKey{userID, eventType}, category}], Total: total}, nil
+}
+
+func (m *memoryStore) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
+	total := 0
+	for key, count := range m.counts {
+		if key.userID == userID && key.eventType == eventType && (category == "" || key.category == category) {
+			total += count
+		}
+	}
+	return total, nil
+}
+
+func (m *memoryStore) Counts(ctx context.Context, userID, eventType string) (map[string]int, error) {
+	m.loads++
+	counts := map[string]int{}
+	for key, count := range m.counts {
+		if key.userID == userID && key.eventType == eventType {
+			counts[key.category] = count
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryStore) AddCounts(ctx context.Context, deltas []repository.CountDelta) error {
+	if m.addErr != nil {
+		return m.addErr
+	}
+	for _, d := range deltas {
+		m.counts[countKey{entryKey{d.UserID, d.EventType}, d.Category}] += d.Delta
+	}
+	return nil
+}
+
+func (m *memoryStore) MarkProcessed(ctx context.Context, groupID, topic string, partition int32, offset int64) (bool, error) {
+	return true, nil
+}
+
+func (m *memoryStore) Save(ctx context.Context, groupID, topic string, partition int32, nextOffset int64) error {
+	m.offsets[partition] = max(m.offsets[partition], nextOffset)
+	return nil
+}
+
+func (m *memoryStore) Offsets(ctx context.Context, groupID, topic string) (map[int32]int64, error) {
+	return m.offsets, nil
+}
+
+func (m *memoryStore) Processed(ctx context.Context, groupID, topic string) (map[int32][]int64, error) {
+	return m.processed, nil
+}
+
+func (m *memoryStore) ClearProcessed(ctx context.Context, groupID, topic string) error {
+	return nil
+}
+
+func (m *memoryStore) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func newTestCache(t *testing.T, store *memoryStore, size int) *Cache {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Size = size
+	cfg.Shards = 1
+	c := New(store, store, store, "reward-processor", cfg, zap.NewNop())
+	require.NoError(t, c.Assigned(context.Background(), map[string][]int32{testTopic: {0}}))
+	return c
+}
+
+func at(offset int64) context.Context {
+	return kafka.WithPosition(context.Background(), kafka.Position{Topic: testTopic, Offset: offset})
+}
+
+func TestIncrementCountsInMemory(t *testing.T) {
+	store := newMemoryStore()
+	store.counts[countKey{entryKey{"user-1", "COURSE_COMPLETED"}, "ART"}] = 2
+	c := newTestCache(t, store, 10)
+
+	counts, err := c.Increment(at(0), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Equal(t, repository.EventCounts{Category: 1, Total: 3}, counts)
+
+	counts, err = c.Increment(at(1), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Equal(t, repository.EventCounts{Category: 2, Total: 4}, counts)
+
+	assert.Equal(t, 1, store.loads)
+	assert.Zero(t, store.counts[countKey{entryKey{"user-1", "COURSE_COMPLETED"}, "MATH"}], "increments are written behind")
+}
+
+func TestIncrementIsIdempotentPerMessage(t *testing.T) {
+	store := newMemoryStore()
+	c := newTestCache(t, store, 10)
+
+	_, err := c.Increment(at(0), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	// The handler retries the message after its transaction failed
+	counts, err := c.Increment(at(0), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Equal(t, repository.EventCounts{Category: 1, Total: 1}, counts)
+}
+
+func TestFlushWritesIncrementsBeforeWatermark(t *testing.T) {
+	store := newMemoryStore()
+	c := newTestCache(t, store, 10)
+	ctx := context.Background()
+	key := countKey{entryKey{"user-1", "COURSE_COMPLETED"}, "MATH"}
+
+	for offset := int64(0); offset < 3; offset++ {
+		_, err := c.Increment(at(offset), "user-1", "COURSE_COMPLETED", "MATH")
+		require.NoError(t, err)
+	}
+
+	// Offsets 0 and 1 are done, 2 is still in flight
+	c.Advanced(testTopic, 0, 2)
+	require.NoError(t, c.Flush(ctx))
+	assert.Equal(t, 2, store.counts[key])
+	assert.Equal(t, int64(2), store.offsets[0])
+
+	c.Advanced(testTopic, 0, 3)
+	require.NoError(t, c.Flush(ctx))
+	assert.Equal(t, 3, store.counts[key])
+	assert.Equal(t, int64(3), store.offsets[0])
+}
+
+func TestFlushFailureKeepsIncrements(t *testing.T) {
+	store := newMemoryStore()
+	c := newTestCache(t, store, 10)
+	ctx := context.Background()
+
+	_, err := c.Increment(at(0), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	c.Advanced(testTopic, 0, 1)
+
+	store.addErr = errors.New("database unavailable")
+	assert.Error(t, c.Flush(ctx))
+	assert.Empty(t, store.offsets)
+
+	store.addErr = nil
+	require.NoError(t, c.Flush(ctx))
+	assert.Equal(t, 1, store.counts[countKey{entryKey{"user-1", "COURSE_COMPLETED"}, "MATH"}])
+	assert.Equal(t, int64(1), store.offsets[0])
+}
+
+func TestEvictionKeepsDirtyEntries(t *testing.T) {
+	store := newMemoryStore()
+	c := newTestCache(t, store, 1)
+
+	_, err := c.Increment(at(0), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	_, err = c.Increment(at(1), "user-2", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Len(t, c.shards[0].entries, 2, "dirty entries exceed the capacity")
+
+	c.Advanced(testTopic, 0, 2)
+	require.NoError(t, c.Flush(context.Background()))
+
+	_, err = c.Increment(at(2), "user-3", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Len(t, c.shards[0].entries, 1)
+}
+
+func TestRevokedFlushesAndForgets(t *testing.T) {
+	store := newMemoryStore()
+	c := newTestCache(t, store, 10)
+	ctx := context.Background()
+	key := countKey{entryKey{"user-1", "COURSE_COMPLETED"}, "MATH"}
+
+	for offset := int64(0); offset < 2; offset++ {
+		_, err := c.Increment(at(offset), "user-1", "COURSE_COMPLETED", "MATH")
+		require.NoError(t, err)
+	}
+	// Offset 1 was never done: it is consumed again by the next owner
+	c.Advanced(testTopic, 0, 1)
+
+	require.NoError(t, c.Revoked(ctx, map[string][]int32{testTopic: {0}}))
+	assert.Equal(t, 1, store.counts[key])
+	assert.Equal(t, int64(1), store.offsets[0])
+	assert.False(t, c.Owns(kafka.Position{Topic: testTopic}))
+
+	// Without an owned partition, increments go to the store
+	counts, err := c.Increment(at(1), "user-1", "COURSE_COMPLETED", "MATH")
+	require.NoError(t, err)
+	assert.Equal(t, 2, counts.Category)
+	assert.Equal(t, 2, store.counts[key])
+}
+
+func TestProcessedBeforeAssignment(t *testing.T) {
+	store := newMemoryStore()
+	store.processed[0] = []int64{5}
+	c := newTestCache(t, store, 10)
+
+	assert.True(t, c.Processed(kafka.Position{Topic: testTopic, Offset: 5}))
+	assert.False(t, c.Processed(kafka.Position{Topic: testTopic, Offset: 6}))
+}
@@ -0,0 +1,83 @@
+package countcache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+)
+
+type entryKey struct {
+	userID    string
+	eventType string
+}
+
+// entry holds the counts of each category of a user's event type
+type entry struct {
+	key    entryKey
+	counts map[string]int
+	// dirty is the number of increments not written yet. Dirty entries are
+	// never evicted.
+	dirty int
+	elem  *list.Element
+}
+
+func (e *entry) eventCounts(category string) repository.EventCounts {
+	counts := repository.EventCounts{Category: e.counts[category]}
+	for _, count := range e.counts {
+		counts.Total += count
+	}
+	return counts
+}
+
+// shard is a part of the cache with its own lock and LRU list
+type shard struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[entryKey]*entry
+	lru      *list.List // most recently used first
+}
+
+func newShard(capacity int) *shard {
+	return &shard{
+		capacity: capacity,
+		entries:  make(map[entryKey]*entry),
+		lru:      list.New(),
+	}
+}
+
+// get returns the entry of key and marks it as used
+func (s *shard) get(key entryKey) *entry {
+	e := s.entries[key]
+	if e != nil {
+		s.lru.MoveToFront(e.elem)
+	}
+	return e
+}
+
+// add adds an entry, evicting the least recently used clean entries when the
+// shard is full. The shard may exceed its capacity while its entries are
+// dirty.
+func (s *shard) add(key entryKey, counts map[string]int) *entry {
+	if counts == nil {
+		counts = make(map[string]int)
+	}
+	e := &entry{key: key, counts: counts}
+	e.elem = s.lru.PushFront(e)
+	s.entries[key] = e
+
+	for elem := s.lru.Back(); len(s.entries) > s.capacity && elem != nil; {
+		victim := elem.Value.(*entry)
+		elem = elem.Prev()
+		if victim.dirty == 0 && victim != e {
+			s.lru.Remove(victim.elem)
+			delete(s.entries, victim.key)
+		}
+	}
+	return e
+}
+
+func (s *shard) reset() {
+	s.entries = make(map[entryKey]*entry)
+	s.lru.Init()
+}
@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -58,10 +62,112 @@ func Connect(dsn string) (*gorm.DB, error) {
 
 	log.Println("Connected to DB successfully")
 
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %w", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
+	}
+
+	if err := mergeDuplicateCounts(db); err != nil {
+		return nil, fmt.Errorf("failed to merge duplicate event counts: %w", err)
+	}
+
+	// Rules created before the review workflow get their status from
+	// enabled and archived_at once the column is added
+	migrator := db.Migrator()
+	backfillStatus := migrator.HasTable(&models.Rule{}) && !migrator.HasColumn(&models.Rule{}, "status")
+
 	// Auto-migrate the schema
-	if err := db.AutoMigrate(&models.UserEventCount{}, &models.Rule{}); err != nil {
+	if err := db.AutoMigrate(&models.UserEventCount{}, &models.Rule{}, &models.GrantedReward{}, &models.ConsumerOffset{}, &models.ProcessedMessage{}, &models.OutboxReward{}, &models.RuleVersion{}, &models.DeadLetterEvent{}); err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
 
+	if backfillStatus {
+		if err := backfillRuleStatus(db); err != nil {
+			return nil, fmt.Errorf("failed to backfill rule status: %w", err)
+		}
+	}
+
+	if err := backfillRuleVersions(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill rule versions: %w", err)
+	}
+
 	return db, nil
 }
+
+// mergeDuplicateCounts merges the duplicate event count rows that concurrent
+// increments created before the counts table had a unique key, so that
+// AutoMigrate can add it. Duplicates hold separate increments, so they are
+// summed.
+func mergeDuplicateCounts(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.UserEventCount{}) || migrator.HasIndex(&models.UserEventCount{}, "idx_user_event_counts_key") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			"LOCK TABLE user_event_counts IN ACCESS EXCLUSIVE MODE",
+			`CREATE TEMP TABLE user_event_counts_merged ON COMMIT DROP AS
+				SELECT user_id, event_type, COALESCE(category, '') AS category,
+					SUM(count) AS count, MAX(updated_at) AS updated_at
+				FROM user_event_counts
+				GROUP BY user_id, event_type, COALESCE(category, '')`,
+			"DELETE FROM user_event_counts",
+			`INSERT INTO user_event_counts (user_id, event_type, category, count, updated_at)
+				SELECT user_id, event_type, category, count, updated_at FROM user_event_counts_merged`,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillRuleStatus sets the status of the rules and rule versions written
+// before rules had one: archived rules are retired, enabled ones active and
+// the others drafts
+func backfillRuleStatus(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"rules", "rule_versions"} {
+			if err := tx.Exec(`UPDATE `+table+` SET status = CASE
+					WHEN archived_at IS NOT NULL THEN ?
+					WHEN enabled THEN ?
+					ELSE ? END`,
+				models.RuleRetired, models.RuleActive, models.RuleDraft).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillRuleVersions records the current definition of the rules created
+// before rules were versioned as their first version
+func backfillRuleVersions(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO rule_versions (rule_id, version, change, event_type, count,
+			conditions_category, type, amount, description, status, enabled, archived_at, author, created_at)
+		SELECT id, version, ?, event_type, count,
+			conditions_category, type, amount, description, status, enabled, archived_at, ?, updated_at
+		FROM rules r
+		WHERE NOT EXISTS (SELECT 1 FROM rule_versions v WHERE v.rule_id = r.id)`,
+		models.RuleCreated, "system").Error
+}
+
+// Check pings the database, reporting the stats of the connection pool
+func Check(db *gorm.DB) health.Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		details := map[string]any{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+		}
+		return details, sqlDB.PingContext(ctx)
+	}
+}
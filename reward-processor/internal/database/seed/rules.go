@@ -2,14 +2,23 @@ package seed
 
 import (
 	"context"
+	_ "embed"
+	"fmt"
 	"os"
 
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/audit"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rulesfile"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// rulesYAML is the rules file of the seeded rules
+//
+//go:embed rules.yaml
+var rulesYAML []byte
+
 // SeedRules seeds the database with initial rules if none exist
 // Only runs in non-production environments
 func SeedRules(ctx context.Context, db *gorm.DB, log *zap.Logger) error {
@@ -21,110 +30,41 @@ func SeedRules(ctx context.Context, db *gorm.DB, log *zap.Logger) error {
 
 	ruleRepo := repository.NewGormRuleRepository(db)
 
-	// Check if we already have rules
-	rules, err := ruleRepo.GetEnabledRules(ctx)
+	// Check if we already have rules, archived or disabled ones included
+	total, _, err := ruleRepo.CountRules(ctx)
 	if err != nil {
 		return err
 	}
 
 	// If we already have rules, skip seeding
-	if len(rules) > 0 {
+	if total > 0 {
 		log.Info("Rules already exist in database, skipping seed")
 		return nil
 	}
 
-	var (
-		categoryMath        string = "MATH"
-		categoryProgramming string = "PROGRAMMING"
-	)
-
-	// Define initial rules
-	initialRules := []models.Rule{
-		{
-			ID:                 "rule-001",
-			EventType:          "COURSE_COMPLETED",
-			ConditionsCategory: &categoryMath,
-			Count:              1,
-			Reward: models.Reward{
-				Type:        models.BadgeReward,
-				Description: "Finished a Math course",
-			},
-			Enabled: true,
-		},
-		{
-			ID:                 "rule-002",
-			EventType:          "COURSE_COMPLETED",
-			Count:              5,
-			ConditionsCategory: &categoryMath,
-			Reward: models.Reward{
-				Type:        models.PointsReward,
-				Amount:      100,
-				Description: "Completed 5 math courses",
-			},
-			Enabled: true,
-		},
-		{
-			ID:        "rule-003",
-			EventType: "COURSE_COMPLETED",
-			Count:     30,
-			Reward: models.Reward{
-				Type:        models.PointsReward,
-				Amount:      30,
-				Description: "Completed 30 courses",
-			},
-			Enabled: true,
-		},
-		{
-			ID:        "rule-004",
-			EventType: "CHAPTER_COMPLETED",
-			Count:     10,
-			Reward: models.Reward{
-				Type:        models.PointsReward,
-				Amount:      10,
-				Description: "Completed 10 chapters",
-			},
-			Enabled: true,
-		},
-		{
-			ID:                 "rule-005",
-			EventType:          "COURSE_COMPLETED",
-			Count:              1,
-			ConditionsCategory: &categoryProgramming,
-			Reward: models.Reward{
-				Type:        models.BadgeReward,
-				Description: "Finished a Programming course",
-			},
-			Enabled: true,
-		},
-		{
-			ID:                 "rule-006",
-			EventType:          "COURSE_COMPLETED",
-			Count:              5,
-			ConditionsCategory: &categoryProgramming,
-			Reward: models.Reward{
-				Type:        models.PointsReward,
-				Amount:      150,
-				Description: "Completed 5 programming courses",
-			},
-			Enabled: true,
-		},
+	file, err := rulesfile.Parse(rulesYAML, rulesfile.YAML)
+	if err != nil {
+		return err
+	}
+	if err := file.Validate(); err != nil {
+		return fmt.Errorf("invalid seed rules: %w", err)
 	}
 
-	// Insert rules in a transaction
-	err = db.Transaction(func(tx *gorm.DB) error {
-		for _, rule := range initialRules {
-			if err := tx.Create(&rule).Error; err != nil {
-				return err
-			}
-			log.Info("Seeded rule", zap.String("rule_id", rule.ID), zap.String("description", rule.Reward.Description))
+	// Seeded rules are created as they are in the file, enabled ones ACTIVE:
+	// unlike SyncRules, which leaves new rules to review, the seed only
+	// bootstraps an empty development database
+	ctx = audit.WithAuthor(ctx, "seed")
+	rules := file.Models()
+	for i := range rules {
+		if rules[i].Enabled {
+			rules[i].Status = models.RuleActive
 		}
-		return nil
-	})
-
-	if err != nil {
-		return err
+		if err := ruleRepo.CreateRule(ctx, &rules[i]); err != nil {
+			return err
+		}
+		log.Info("Seeded rule", zap.String("rule_id", rules[i].ID))
 	}
 
-	log.Info("Successfully seeded rules", zap.Int("count", len(initialRules)))
+	log.Info("Successfully seeded rules", zap.Int("count", len(rules)))
 	return nil
 }
@@ -0,0 +1,16 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rulesfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesFileIsValid(t *testing.T) {
+	file, err := rulesfile.Parse(rulesYAML, rulesfile.YAML)
+	require.NoError(t, err)
+	assert.NoError(t, file.Validate())
+	assert.Len(t, file.Rules, 6)
+}
@@ -0,0 +1,165 @@
+// Package health serves the liveness and readiness probes of a service.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Statuses of a check and of a report
+const (
+	StatusOK   = "ok"
+	StatusDown = "down"
+)
+
+// Check reports the state of a dependency: details shown in the report, and
+// an error if the dependency is unusable
+type Check func(ctx context.Context) (map[string]any, error)
+
+// Func adapts a check without details
+func Func(check func(ctx context.Context) error) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		return nil, check(ctx)
+	}
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Status  string         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Report is the outcome of every check. Its status is down if any check is.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the readiness checks of a service
+type Checker struct {
+	mu      sync.RWMutex
+	names   []string
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+// New creates a checker failing checks that take longer than timeout
+func New(timeout time.Duration) *Checker {
+	return &Checker{checks: map[string]Check{}, timeout: timeout}
+}
+
+// Add adds a check, replacing any check of the same name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run runs every check concurrently
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run runs a check within the timeout. A check ignoring its context is
+// left running in the background.
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		details map[string]any
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("check panicked: %v", r)}
+			}
+		}()
+		details, err := check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			return Result{Status: StatusDown, Error: o.err.Error(), Details: o.details}
+		}
+		return Result{Status: StatusOK, Details: o.details}
+	case <-ctx.Done():
+		return Result{Status: StatusDown, Error: fmt.Sprintf("check timed out after %s", c.timeout)}
+	}
+}
+
+// LiveHandler answers whether the process is up. It runs no check, so that a
+// dependency going down does not get the service restarted.
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Result{}})
+	})
+}
+
+// ReadyHandler answers whether every dependency is usable, with the result
+// of each check. It responds 503 Service Unavailable if any is down.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// Register serves the liveness probe at /health/live and the readiness probe
+// at /health/ready and /health
+func (c *Checker) Register(router *mux.Router) {
+	router.Handle("/health/live", LiveHandler()).Methods(http.MethodGet)
+	router.Handle("/health/ready", c.ReadyHandler()).Methods(http.MethodGet)
+	router.Handle("/health", c.ReadyHandler()).Methods(http.MethodGet)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
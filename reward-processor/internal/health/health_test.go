@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, checker *Checker, path string) (int, Report) {
+	t.Helper()
+	router := mux.NewRouter()
+	checker.Register(router)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReadyReportsEveryCheck(t *testing.T) {
+	checker := New(time.Second)
+	checker.Add("postgres", Func(func(context.Context) error { return nil }))
+	checker.Add("kafka_consumer", func(context.Context) (map[string]any, error) {
+		return map[string]any{"group": "reward-processor"}, errors.New("not a member of consumer group reward-processor")
+	})
+
+	for _, path := range []string{"/health/ready", "/health"} {
+		code, report := serve(t, checker, path)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code, path)
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, Result{Status: StatusOK}, report.Checks["postgres"])
+		assert.Equal(t, Result{
+			Status:  StatusDown,
+			Error:   "not a member of consumer group reward-processor",
+			Details: map[string]any{"group": "reward-processor"},
+		}, report.Checks["kafka_consumer"])
+	}
+}
+
+func TestReadyWhenEveryCheckPasses(t *testing.T) {
+	checker := New(time.Second)
+	checker.Add("rules", func(context.Context) (map[string]any, error) {
+		return map[string]any{"loaded": 3}, nil
+	})
+
+	code, report := serve(t, checker, "/health/ready")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, float64(3), report.Checks["rules"].Details["loaded"])
+}
+
+func TestSlowCheckTimesOut(t *testing.T) {
+	checker := New(10 * time.Millisecond)
+	checker.Add("kafka_producer", Func(func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	start := time.Now()
+	report := checker.Run(context.Background())
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, StatusDown, report.Checks["kafka_producer"].Status)
+	assert.Contains(t, report.Checks["kafka_producer"].Error, "timed out")
+}
+
+func TestLiveRunsNoCheck(t *testing.T) {
+	checker := New(time.Second)
+	checker.Add("postgres", Func(func(context.Context) error { return errors.New("connection refused") }))
+
+	code, report := serve(t, checker, "/health/live")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Empty(t, report.Checks)
+}
@@ -0,0 +1,250 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// CloudEventsMode selects how messages are wrapped in the CloudEvents Kafka
+// protocol binding
+type CloudEventsMode string
+
+const (
+	// CloudEventsDisabled publishes the bare JSON payload without metadata
+	CloudEventsDisabled CloudEventsMode = "none"
+	// CloudEventsBinary keeps the payload as message value and carries the
+	// event attributes in ce_* headers
+	CloudEventsBinary CloudEventsMode = "binary"
+	// CloudEventsStructured wraps attributes and payload in a single
+	// application/cloudevents+json document
+	CloudEventsStructured CloudEventsMode = "structured"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+	jsonContentType        = "application/json"
+)
+
+// CloudEvents types of the messages exchanged by the reward processor
+const (
+	LearningEventType   = "dev.learning-rewards.learning-event.v1"
+	RewardTriggeredType = "dev.learning-rewards.reward-triggered.v1"
+)
+
+// ParseCloudEventsMode parses a configured mode, defaulting to binary
+func ParseCloudEventsMode(s string) (CloudEventsMode, error) {
+	switch mode := CloudEventsMode(s); mode {
+	case "":
+		return CloudEventsBinary, nil
+	case CloudEventsDisabled, CloudEventsBinary, CloudEventsStructured:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown CloudEvents mode %q, expected one of none, binary, structured", s)
+	}
+}
+
+// CloudEvents holds the attributes stamped on every produced message
+type CloudEvents struct {
+	Mode   CloudEventsMode
+	Source string
+	Type   string
+}
+
+// payload is an encoded message body along with its content type and the
+// URI of the schema it conforms to
+type payload struct {
+	data        []byte
+	contentType string
+	dataSchema  string
+}
+
+// CloudEvent holds the context attributes of a received message. It is the
+// zero value for legacy messages published without CloudEvents metadata.
+type CloudEvent struct {
+	SpecVersion string
+	ID          string
+	Source      string
+	Type        string
+	Subject     string
+	Time        time.Time
+	DataSchema  string
+	// DataContentType is the content type of the payload; empty for legacy
+	// messages
+	DataContentType string
+}
+
+// structuredEvent is the JSON event format used by the structured mode
+type structuredEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// encode sets the value and headers of msg according to the configured mode.
+// An empty id is replaced by a random one. Non-JSON payloads are carried as
+// data_base64 in structured mode.
+func (c CloudEvents) encode(msg *sarama.ProducerMessage, body payload, id, subject string) error {
+	if c.Mode == "" || c.Mode == CloudEventsDisabled {
+		msg.Value = sarama.ByteEncoder(body.data)
+		return nil
+	}
+
+	if id == "" {
+		id = uuid.New().String()
+	}
+	now := time.Now().UTC()
+
+	switch c.Mode {
+	case CloudEventsBinary:
+		headers := []sarama.RecordHeader{
+			{Key: []byte("ce_specversion"), Value: []byte(cloudEventsSpecVersion)},
+			{Key: []byte("ce_id"), Value: []byte(id)},
+			{Key: []byte("ce_source"), Value: []byte(c.Source)},
+			{Key: []byte("ce_type"), Value: []byte(c.Type)},
+			{Key: []byte("ce_time"), Value: []byte(now.Format(time.RFC3339Nano))},
+			{Key: []byte("content-type"), Value: []byte(body.contentType)},
+		}
+		if subject != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte("ce_subject"), Value: []byte(subject)})
+		}
+		if body.dataSchema != "" {
+			headers = append(headers, sarama.RecordHeader{Key: []byte("ce_dataschema"), Value: []byte(body.dataSchema)})
+		}
+		msg.Headers = append(msg.Headers, headers...)
+		msg.Value = sarama.ByteEncoder(body.data)
+
+	case CloudEventsStructured:
+		event := structuredEvent{
+			SpecVersion:     cloudEventsSpecVersion,
+			ID:              id,
+			Source:          c.Source,
+			Type:            c.Type,
+			Subject:         subject,
+			Time:            now,
+			DataContentType: body.contentType,
+			DataSchema:      body.dataSchema,
+		}
+		if body.contentType == jsonContentType {
+			event.Data = body.data
+		} else {
+			event.DataBase64 = body.data
+		}
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal CloudEvent: %w", err)
+		}
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{
+			Key:   []byte("content-type"),
+			Value: []byte(cloudEventsContentType + "; charset=UTF-8"),
+		})
+		msg.Value = sarama.ByteEncoder(value)
+
+	default:
+		return fmt.Errorf("unknown CloudEvents mode %q", c.Mode)
+	}
+
+	return nil
+}
+
+// decodeMessage extracts the event payload of a message published in binary
+// mode, structured mode or as a legacy bare payload. The returned CloudEvent
+// is empty for legacy messages. The payload itself may be JSON or Avro.
+func decodeMessage(msg *sarama.ConsumerMessage) ([]byte, CloudEvent, error) {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers[strings.ToLower(string(h.Key))] = string(h.Value)
+		}
+	}
+
+	// Binary mode: attributes live in ce_* headers
+	if specVersion, ok := headers["ce_specversion"]; ok {
+		ce := CloudEvent{
+			SpecVersion: specVersion,
+			ID:          headers["ce_id"],
+			Source:      headers["ce_source"],
+			Type:        headers["ce_type"],
+			Subject:     headers["ce_subject"],
+			DataSchema:  headers["ce_dataschema"],
+			// In binary mode content-type describes the data itself
+			DataContentType: headers["content-type"],
+		}
+		if t, ok := headers["ce_time"]; ok {
+			parsed, err := time.Parse(time.RFC3339Nano, t)
+			if err != nil {
+				return nil, CloudEvent{}, fmt.Errorf("invalid ce_time header: %w", err)
+			}
+			ce.Time = parsed
+		}
+		if err := ce.validate(); err != nil {
+			return nil, CloudEvent{}, err
+		}
+		return msg.Value, ce, nil
+	}
+
+	// Structured mode is announced by the content type. Producers that do not
+	// set headers are detected by the presence of the specversion attribute.
+	if strings.HasPrefix(headers["content-type"], cloudEventsContentType) || looksStructured(msg.Value) {
+		var event structuredEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			return nil, CloudEvent{}, fmt.Errorf("invalid structured CloudEvent: %w", err)
+		}
+		ce := CloudEvent{
+			SpecVersion:     event.SpecVersion,
+			ID:              event.ID,
+			Source:          event.Source,
+			Type:            event.Type,
+			Subject:         event.Subject,
+			Time:            event.Time,
+			DataSchema:      event.DataSchema,
+			DataContentType: event.DataContentType,
+		}
+		if err := ce.validate(); err != nil {
+			return nil, CloudEvent{}, err
+		}
+		if event.DataBase64 != nil {
+			return event.DataBase64, ce, nil
+		}
+		return event.Data, ce, nil
+	}
+
+	// Legacy bare payload
+	return msg.Value, CloudEvent{}, nil
+}
+
+// looksStructured reports whether a JSON value carries a top-level
+// specversion attribute
+func looksStructured(value []byte) bool {
+	if !bytes.Contains(value, []byte(`"specversion"`)) {
+		return false
+	}
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	return json.Unmarshal(value, &probe) == nil && probe.SpecVersion != ""
+}
+
+// validate checks the required context attributes
+func (ce CloudEvent) validate() error {
+	if !strings.HasPrefix(ce.SpecVersion, "1.") {
+		return fmt.Errorf("unsupported CloudEvents specversion %q", ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return fmt.Errorf("CloudEvent is missing required attributes (id, source, type)")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eventJSON = `{"user_id":"user-001","event_type":"COURSE_COMPLETED","category":"MATH"}`
+
+const eventSchema = "urn:learning-rewards:schema:learning-events-value:1"
+
+// toConsumerMessage converts a produced message into the form a consumer receives
+func toConsumerMessage(t *testing.T, msg *sarama.ProducerMessage) *sarama.ConsumerMessage {
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+
+	consumed := &sarama.ConsumerMessage{Value: value}
+	for i := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &msg.Headers[i])
+	}
+	return consumed
+}
+
+func TestCloudEventsRoundTrip(t *testing.T) {
+	jsonBody := payload{data: []byte(eventJSON), contentType: jsonContentType, dataSchema: eventSchema}
+	avroBody := payload{data: []byte{0xC3, 0x01, 1, 2, 3, 4, 5, 6, 7, 8, 0}, contentType: "application/avro", dataSchema: eventSchema}
+
+	tests := []struct {
+		name    string
+		mode    CloudEventsMode
+		body    payload
+		wantCE  bool
+		headers int
+	}{
+		{name: "binary mode", mode: CloudEventsBinary, body: jsonBody, wantCE: true, headers: 8},
+		{name: "binary mode avro", mode: CloudEventsBinary, body: avroBody, wantCE: true, headers: 8},
+		{name: "structured mode", mode: CloudEventsStructured, body: jsonBody, wantCE: true, headers: 1},
+		{name: "structured mode avro", mode: CloudEventsStructured, body: avroBody, wantCE: true, headers: 1},
+		{name: "disabled", mode: CloudEventsDisabled, body: jsonBody, wantCE: false, headers: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := CloudEvents{
+				Mode:   tt.mode,
+				Source: "/event-processor",
+				Type:   LearningEventType,
+			}
+			msg := &sarama.ProducerMessage{}
+			require.NoError(t, ce.encode(msg, tt.body, "event-1", "user-001"))
+			assert.Len(t, msg.Headers, tt.headers)
+
+			data, decoded, err := decodeMessage(toConsumerMessage(t, msg))
+			require.NoError(t, err)
+			assert.Equal(t, tt.body.data, data)
+
+			if !tt.wantCE {
+				assert.Equal(t, CloudEvent{}, decoded)
+				return
+			}
+			assert.Equal(t, "1.0", decoded.SpecVersion)
+			assert.Equal(t, "event-1", decoded.ID)
+			assert.Equal(t, "/event-processor", decoded.Source)
+			assert.Equal(t, LearningEventType, decoded.Type)
+			assert.Equal(t, "user-001", decoded.Subject)
+			assert.Equal(t, eventSchema, decoded.DataSchema)
+			assert.Equal(t, tt.body.contentType, decoded.DataContentType)
+			assert.False(t, decoded.Time.IsZero())
+		})
+	}
+}
+
+func TestDecodeMessage_StructuredWithoutHeaders(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Value: []byte(`{
+		"specversion": "1.0",
+		"id": "event-2",
+		"source": "/lms",
+		"type": "` + LearningEventType + `",
+		"time": "2025-06-03T14:00:00Z",
+		"data": ` + eventJSON + `
+	}`)}
+
+	payload, ce, err := decodeMessage(msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, eventJSON, string(payload))
+	assert.Equal(t, "event-2", ce.ID)
+}
+
+func TestDecodeMessage_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *sarama.ConsumerMessage
+	}{
+		{
+			name: "unsupported spec version",
+			msg: &sarama.ConsumerMessage{
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte("ce_specversion"), Value: []byte("0.3")},
+					{Key: []byte("ce_id"), Value: []byte("1")},
+					{Key: []byte("ce_source"), Value: []byte("/s")},
+					{Key: []byte("ce_type"), Value: []byte("t")},
+				},
+				Value: []byte(eventJSON),
+			},
+		},
+		{
+			name: "missing attributes",
+			msg: &sarama.ConsumerMessage{
+				Headers: []*sarama.RecordHeader{{Key: []byte("ce_specversion"), Value: []byte("1.0")}},
+				Value:   []byte(eventJSON),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeMessage(tt.msg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseCloudEventsMode(t *testing.T) {
+	mode, err := ParseCloudEventsMode("")
+	require.NoError(t, err)
+	assert.Equal(t, CloudEventsBinary, mode)
+
+	_, err = ParseCloudEventsMode("envelope")
+	assert.Error(t, err)
+}
@@ -4,20 +4,116 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/metrics"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/schema"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// Handler processes a single user event. The context is cancelled when the
+// consumer group session ends and carries the Position of the message.
+type Handler func(ctx context.Context, event models.UserEvent) error
+
+// Position identifies a consumed message
+type Position struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	// Watermark is an offset before which every message of the partition was
+	// handled when this one was dispatched. Messages are handled
+	// concurrently, so some after it may be done already.
+	Watermark int64
+}
+
+type positionKey struct{}
+
+// WithPosition returns a copy of ctx carrying pos
+func WithPosition(ctx context.Context, pos Position) context.Context {
+	return context.WithValue(ctx, positionKey{}, pos)
+}
+
+// PositionFrom returns the position of the message being handled
+func PositionFrom(ctx context.Context) (Position, bool) {
+	pos, ok := ctx.Value(positionKey{}).(Position)
+	return pos, ok
+}
+
+// OffsetStore holds offsets stored outside of Kafka. A handler saving the
+// watermark of its message, and recording the message as processed, in the
+// same transaction as its other writes makes processing effectively
+// exactly-once.
+type OffsetStore interface {
+	// Offsets returns the watermark of each partition of a topic that has a
+	// stored one
+	Offsets(ctx context.Context, groupID, topic string) (map[int32]int64, error)
+}
+
+// PartitionObserver follows the partitions owned by a consumer. Since a
+// partition is consumed by a single member of the group, an observer may
+// keep state about the messages of its partitions in memory.
+type PartitionObserver interface {
+	// Assigned is called when a session starts, before any message of the
+	// claimed partitions is handled. An error ends the session.
+	Assigned(ctx context.Context, claims map[string][]int32) error
+	// Advanced is called when the watermark of a partition moves. It must
+	// not block.
+	Advanced(topic string, partition int32, watermark int64)
+	// Revoked is called when a session ends, once the messages in flight
+	// are done. The partitions may be assigned to another member next.
+	Revoked(ctx context.Context, claims map[string][]int32) error
+}
+
+// Membership is the state of a consumer in its group while it takes part in
+// a session
+type Membership struct {
+	MemberID     string
+	GenerationID int32
+	// Claims are the partitions assigned to the consumer, by topic. A member
+	// of a group with more members than partitions may have none.
+	Claims map[string][]int32
+	Since  time.Time
+}
+
+const (
+	// commitInterval is the time between two offset commits to Kafka
+	commitInterval = time.Second
+	// statsInterval is how often the worker pool stats are logged
+	statsInterval = time.Minute
+	// maxRetryBackoff caps the delay between two attempts at a failed event
+	maxRetryBackoff = 30 * time.Second
+)
+
 // Consumer represents a Kafka consumer for user events
 type Consumer struct {
 	consumer sarama.ConsumerGroup
+	groupID  string
 	topics   []string
+	offsets  OffsetStore
+	observer PartitionObserver
+	pool     PoolConfig
+	stats    atomic.Pointer[workerPool]
+	member   atomic.Pointer[Membership]
 	log      *zap.Logger
-	handler  func(models.UserEvent) error
+	handler  Handler
+	ceTypes  map[string]bool
+	registry *schema.Registry
+
+	retry      RetryConfig
+	classifier ErrorClassifier
+	deadLetter DeadLetterFunc
+	retrying   *retryTracker
 }
 
 // NewConsumer creates a new Kafka consumer
@@ -27,8 +123,13 @@ func NewConsumer(brokers []string, groupID string, topics []string) (*Consumer,
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-	config.Consumer.Offsets.AutoCommit.Enable = true
-	config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
+	// Offsets are committed explicitly, once their message was handled
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	registry, err := schema.Default()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema registry: %w", err)
+	}
 
 	log.Info("Creating Kafka consumer",
 		zap.Strings("brokers", brokers),
@@ -47,16 +148,82 @@ func NewConsumer(brokers []string, groupID string, topics []string) (*Consumer,
 	log.Info("Successfully created Kafka consumer")
 	return &Consumer{
 		consumer: consumer,
+		groupID:  groupID,
 		topics:   topics,
+		pool:     DefaultPoolConfig(),
 		log:      log,
+		ceTypes:  map[string]bool{LearningEventType: true},
+		registry: registry,
+		retry:    DefaultRetryConfig(),
+		retrying: newRetryTracker(),
 	}, nil
 }
 
 // SetHandler sets the event handler function
-func (c *Consumer) SetHandler(handler func(models.UserEvent) error) {
+func (c *Consumer) SetHandler(handler Handler) {
 	c.handler = handler
 }
 
+// SetOffsetStore makes the consumer resume each partition from the offset
+// stored in store, which takes precedence over the offset committed to Kafka
+func (c *Consumer) SetOffsetStore(store OffsetStore) {
+	c.offsets = store
+}
+
+// SetPartitionObserver sets an observer notified of the partitions the
+// consumer owns and of their watermarks
+func (c *Consumer) SetPartitionObserver(observer PartitionObserver) {
+	c.observer = observer
+}
+
+// SetRetry sets how failed events are retried: cfg bounds the attempts,
+// classifier tells transient errors from permanent ones, and deadLetter
+// stores the events given up on. Without deadLetter, failed events are
+// retried until they succeed.
+func (c *Consumer) SetRetry(cfg RetryConfig, classifier ErrorClassifier, deadLetter DeadLetterFunc) {
+	c.retry = cfg
+	c.classifier = classifier
+	c.deadLetter = deadLetter
+}
+
+// Stuck returns the events retried for longer than RetryConfig.StuckAfter,
+// which hold back the watermark of their partition
+func (c *Consumer) Stuck() []RetryingEvent {
+	return c.retrying.since(time.Now().Add(-c.retry.StuckAfter))
+}
+
+// SetPool sets the size of the worker pool handling events
+func (c *Consumer) SetPool(cfg PoolConfig) {
+	c.pool = cfg
+}
+
+// PoolStats returns a snapshot of the worker pool, zero before Start
+func (c *Consumer) PoolStats() PoolStats {
+	if pool := c.stats.Load(); pool != nil {
+		return pool.stats()
+	}
+	return PoolStats{}
+}
+
+// Membership returns the state of the consumer in its group, or false
+// outside of a session: before joining, and while rebalancing
+func (c *Consumer) Membership() (Membership, bool) {
+	if member := c.member.Load(); member != nil {
+		return *member, true
+	}
+	return Membership{}, false
+}
+
+// SetCloudEventTypes restricts CloudEvents messages to the given types;
+// messages of other types are skipped. Legacy messages without CloudEvents
+// metadata are always accepted. Passing no types accepts every type.
+func (c *Consumer) SetCloudEventTypes(types ...string) {
+	c.ceTypes = make(map[string]bool, len(types))
+	for _, t := range types {
+		c.ceTypes[t] = true
+	}
+}
+
 // Start begins consuming messages
 func (c *Consumer) Start(ctx context.Context) error {
 	if c.handler == nil {
@@ -64,12 +231,33 @@ func (c *Consumer) Start(ctx context.Context) error {
 	}
 
 	consumer := &consumerGroupHandler{
-		handler: c.handler,
-		log:     c.log,
+		handler:  c.handler,
+		groupID:  c.groupID,
+		offsets:  c.offsets,
+		observer: c.observer,
+		member:   &c.member,
+		log:      c.log,
+		ceTypes:  c.ceTypes,
+		registry: c.registry,
+
+		retry:      c.retry,
+		classifier: c.classifier,
+		deadLetter: c.deadLetter,
+		retrying:   c.retrying,
 	}
 
+	// The pool outlives consumer group sessions, so that a user keeps its
+	// worker across rebalances
+	pool := newWorkerPool(c.pool, consumer.handleWithRetry)
+	defer pool.close()
+	consumer.pool = pool
+	c.stats.Store(pool)
+	go c.logStats(ctx, pool)
+
 	c.log.Info("Starting Kafka consumer",
-		zap.Strings("topics", c.topics))
+		zap.Strings("topics", c.topics),
+		zap.Int("workers", c.pool.Workers),
+		zap.Int("queue_size", c.pool.QueueSize))
 
 	for {
 		select {
@@ -101,6 +289,27 @@ func (c *Consumer) Start(ctx context.Context) error {
 	}
 }
 
+// logStats periodically logs the worker pool stats
+func (c *Consumer) logStats(ctx context.Context, pool *workerPool) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := pool.stats()
+			c.log.Info("Worker pool stats",
+				zap.Int("workers", stats.Workers),
+				zap.Int("busy", stats.Busy),
+				zap.Int("queued", stats.Queued),
+				zap.Int("queue_capacity", stats.QueueCapacity),
+				zap.Int64("handled", stats.Handled),
+				zap.Float64("saturation", stats.Saturation()))
+		}
+	}
+}
+
 // Close closes the consumer
 func (c *Consumer) Close() error {
 	c.log.Info("Closing Kafka consumer")
@@ -115,8 +324,20 @@ func (c *Consumer) Close() error {
 
 // consumerGroupHandler implements sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
-	handler func(models.UserEvent) error
-	log     *zap.Logger
+	handler  Handler
+	groupID  string
+	offsets  OffsetStore
+	observer PartitionObserver
+	member   *atomic.Pointer[Membership]
+	pool     *workerPool
+	log      *zap.Logger
+	ceTypes  map[string]bool
+	registry *schema.Registry
+
+	retry      RetryConfig
+	classifier ErrorClassifier
+	deadLetter DeadLetterFunc
+	retrying   *retryTracker
 }
 
 // Setup is run at the beginning of a new session
@@ -133,15 +354,56 @@ func (h *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error
 			zap.Int32s("partitions", partitions))
 	}
 
+	if h.offsets != nil {
+		if err := h.resumeFromStore(session); err != nil {
+			return err
+		}
+	}
+
+	if h.observer != nil {
+		if err := h.observer.Assigned(session.Context(), claims); err != nil {
+			return fmt.Errorf("failed to assign partitions: %w", err)
+		}
+	}
+
+	h.member.Store(&Membership{
+		MemberID:     session.MemberID(),
+		GenerationID: session.GenerationID(),
+		Claims:       claims,
+		Since:        time.Now(),
+	})
 	h.log.Info("Consumer group handler setup completed")
 	return nil
 }
 
+// resumeFromStore moves the claimed partitions to their stored offsets. Kafka
+// commits may lag behind the store after a crash, or be ahead of it when a
+// transaction failed after the commit.
+func (h *consumerGroupHandler) resumeFromStore(session sarama.ConsumerGroupSession) error {
+	for topic, partitions := range session.Claims() {
+		stored, err := h.offsets.Offsets(session.Context(), h.groupID, topic)
+		if err != nil {
+			return fmt.Errorf("failed to load stored offsets: %w", err)
+		}
+		for _, partition := range partitions {
+			if offset, ok := stored[partition]; ok {
+				session.ResetOffset(topic, partition, offset, "")
+				h.log.Info("Resuming partition from stored offset",
+					zap.String("topic", topic),
+					zap.Int32("partition", partition),
+					zap.Int64("offset", offset))
+			}
+		}
+	}
+	return nil
+}
+
 // Cleanup is run at the end of a session
 func (h *consumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
 	h.log.Info("Consumer group handler cleanup started",
 		zap.String("member_id", session.MemberID()),
 		zap.Int32("generation_id", session.GenerationID()))
+	h.member.Store(nil)
 
 	// Log the claims that were being processed
 	claims := session.Claims()
@@ -151,11 +413,25 @@ func (h *consumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) erro
 			zap.Int32s("partitions", partitions))
 	}
 
+	// Sarama runs Cleanup once every ConsumeClaim returned, so no message
+	// is in flight anymore. The session context is already cancelled.
+	if h.observer != nil {
+		if err := h.observer.Revoked(context.WithoutCancel(session.Context()), claims); err != nil {
+			h.log.Error("Failed to revoke partitions", zap.Error(err))
+		}
+	}
+
+	// Commit what was marked since the last commit before giving up the claims
+	session.Commit()
+
 	h.log.Info("Consumer group handler cleanup completed")
 	return nil
 }
 
-// ConsumeClaim processes messages from a claim
+// ConsumeClaim dispatches the messages of a claim to the worker pool. Sarama
+// runs one ConsumeClaim per assigned partition concurrently. Messages are
+// handled out of order across users, so the offset marked for commit is the
+// lowest one not handled yet.
 func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	h.log.Info("Starting to consume messages",
 		zap.String("topic", claim.Topic()),
@@ -163,20 +439,57 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 		zap.Int64("initial_offset", claim.InitialOffset()),
 		zap.Int64("high_water_mark", claim.HighWaterMarkOffset()))
 
+	lag := metrics.KafkaConsumerLag.WithLabelValues(claim.Topic(), strconv.Itoa(int(claim.Partition())))
+	consumed := metrics.KafkaConsumed.WithLabelValues(claim.Topic(), "handled")
+	dropped := metrics.KafkaConsumed.WithLabelValues(claim.Topic(), "dropped")
+
+	tracker := newOffsetTracker(claim.InitialOffset())
+	markDone := func(offset int64) {
+		if watermark, moved := tracker.done(offset); moved {
+			session.MarkOffset(claim.Topic(), claim.Partition(), watermark, "")
+			if h.observer != nil {
+				h.observer.Advanced(claim.Topic(), claim.Partition(), watermark)
+			}
+		}
+	}
+
+	// Wait for the messages in flight before giving up the claim, so that a
+	// partition is never handled by two members at once
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+
+	commit := time.NewTicker(commitInterval)
+	defer commit.Stop()
+
 	messageCount := 0
-	lastOffset := claim.InitialOffset()
+	for {
+		var message *sarama.ConsumerMessage
+		select {
+		case <-session.Context().Done():
+			return nil
+		case <-commit.C:
+			session.Commit()
+			continue
+		case m, ok := <-claim.Messages():
+			if !ok {
+				h.log.Info("Finished consuming messages",
+					zap.String("topic", claim.Topic()),
+					zap.Int32("partition", claim.Partition()),
+					zap.Int64("high_water_mark", claim.HighWaterMarkOffset()),
+					zap.Int("total_messages_dispatched", messageCount))
+				return nil
+			}
+			message = m
+		}
 
-	for message := range claim.Messages() {
 		messageCount++
-		lastOffset = message.Offset
-
 		if messageCount%100 == 0 {
 			h.log.Info("Message consumption progress",
 				zap.String("topic", message.Topic),
 				zap.Int32("partition", message.Partition),
 				zap.Int64("current_offset", message.Offset),
 				zap.Int64("high_water_mark", claim.HighWaterMarkOffset()),
-				zap.Int("messages_processed", messageCount))
+				zap.Int("messages_dispatched", messageCount))
 		}
 
 		h.log.Debug("Processing message",
@@ -186,31 +499,164 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 			zap.Time("timestamp", message.Timestamp),
 			zap.Int("message_size", len(message.Value)))
 
-		var event models.UserEvent
-		if err := json.Unmarshal(message.Value, &event); err != nil {
-			h.log.Error("Failed to unmarshal event", zap.Error(err))
+		watermark := tracker.add(message.Offset)
+		lag.Set(float64(claim.HighWaterMarkOffset() - message.Offset - 1))
+
+		// The span continues the trace of the producer, and covers the
+		// time the message waits for a worker
+		ctx := tracing.Extract(session.Context(), consumerHeaders(message.Headers))
+		ctx, span := tracing.Tracer().Start(ctx, message.Topic+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", message.Topic),
+				attribute.Int("messaging.destination.partition.id", int(message.Partition)),
+				attribute.Int64("messaging.kafka.offset", message.Offset),
+				attribute.String("messaging.kafka.message.key", string(message.Key)),
+			))
+
+		event, ok := h.decode(message)
+		if !ok {
+			metrics.KafkaConsumed.WithLabelValues(message.Topic, "skipped").Inc()
+			span.SetStatus(codes.Error, "message skipped")
+			span.End()
+			markDone(message.Offset)
 			continue
 		}
+		span.SetAttributes(
+			attribute.String("event.id", event.ID),
+			attribute.String("event.type", event.EventType),
+			attribute.String("user.id", event.UserID),
+		)
 
-		if err := h.handler(event); err != nil {
-			h.log.Error("Failed to process event",
-				zap.Error(err),
-				zap.Any("event", event))
-			continue
+		ctx = WithPosition(ctx, Position{
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			Watermark: watermark,
+		})
+		offset := message.Offset
+		inflight.Add(1)
+		submitted := h.pool.submit(ctx, job{
+			ctx:   ctx,
+			event: event,
+			done: func(handled bool) {
+				defer inflight.Done()
+				// Unhandled messages keep the watermark below them, so
+				// they are redelivered to the next owner of the partition
+				if handled {
+					consumed.Inc()
+					markDone(offset)
+				} else {
+					dropped.Inc()
+					span.SetStatus(codes.Error, "session ended before the message was handled")
+				}
+				span.End()
+			},
+		})
+		if !submitted {
+			dropped.Inc()
+			span.SetStatus(codes.Error, "session ended before the message was handled")
+			span.End()
+			inflight.Done()
+			return nil
 		}
+	}
+}
 
-		session.MarkMessage(message, "")
-		h.log.Debug("Successfully processed and marked message",
-			zap.String("topic", message.Topic),
-			zap.Int32("partition", message.Partition),
-			zap.Int64("offset", message.Offset))
+// decode decodes a message into an event. Messages that cannot be decoded,
+// or carry another CloudEvents type, are skipped.
+func (h *consumerGroupHandler) decode(message *sarama.ConsumerMessage) (models.UserEvent, bool) {
+	// Accept both CloudEvents and legacy bare payloads during migration
+	payload, ce, err := decodeMessage(message)
+	if err != nil {
+		h.log.Error("Failed to decode message", zap.Error(err))
+		return models.UserEvent{}, false
+	}
+	if ce.Type != "" && len(h.ceTypes) > 0 && !h.ceTypes[ce.Type] {
+		h.log.Debug("Skipping message with unexpected CloudEvents type",
+			zap.String("type", ce.Type),
+			zap.String("source", ce.Source),
+			zap.String("id", ce.ID))
+		return models.UserEvent{}, false
 	}
 
-	h.log.Info("Finished consuming messages",
-		zap.String("topic", claim.Topic()),
-		zap.Int32("partition", claim.Partition()),
-		zap.Int64("last_offset", lastOffset),
-		zap.Int64("high_water_mark", claim.HighWaterMarkOffset()),
-		zap.Int("total_messages_processed", messageCount))
-	return nil
+	event, err := decodeEvent(h.registry, payload, ce)
+	if err != nil {
+		h.log.Error("Failed to unmarshal event",
+			zap.Error(err),
+			zap.String("content_type", ce.DataContentType),
+			zap.String("dataschema", ce.DataSchema))
+		return models.UserEvent{}, false
+	}
+	return event, true
+}
+
+// handleWithRetry calls the handler until it succeeds, backing off between
+// attempts. Transient errors are retried until they go away. An event failing
+// with a permanent error, or with other errors MaxAttempts times, is
+// dead-lettered and counts as handled; it is retried on if dead-lettering
+// fails, so that no event is ever lost. It returns false if the session ended
+// first.
+func (h *consumerGroupHandler) handleWithRetry(ctx context.Context, event models.UserEvent) bool {
+	pos, _ := PositionFrom(ctx)
+	if h.retrying != nil {
+		defer h.retrying.done(pos)
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := h.handler(ctx, event)
+		if err == nil {
+			return true
+		}
+		metrics.KafkaHandlerErrors.WithLabelValues(pos.Topic).Inc()
+		trace.SpanFromContext(ctx).RecordError(err, trace.WithAttributes(attribute.Int("attempt", attempt)))
+		if h.retrying != nil {
+			h.retrying.failed(pos, event.ID, attempt, err)
+		}
+
+		class := classify(err, h.classifier)
+		exhausted := class == ErrorPermanent || class == ErrorUnknown && h.retry.MaxAttempts > 0 && attempt >= h.retry.MaxAttempts
+		if exhausted && h.deadLetter != nil && ctx.Err() == nil {
+			dlErr := h.deadLetter(ctx, event, err, attempt)
+			if dlErr == nil {
+				metrics.KafkaDeadLettered.WithLabelValues(pos.Topic).Inc()
+				h.log.Error("Gave up on event, dead-lettered",
+					zap.Error(err),
+					zap.Int("attempts", attempt),
+					zap.Any("position", pos),
+					zap.Any("event", event))
+				return true
+			}
+			h.log.Error("Failed to dead-letter event, retrying",
+				zap.Error(dlErr),
+				zap.Any("position", pos))
+		}
+
+		h.log.Error("Failed to process event, retrying",
+			zap.Error(err),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Any("event", event))
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// decodeEvent decodes an Avro or JSON payload. Avro payloads are recognized by
+// their content type or, for legacy messages, by the single object marker.
+func decodeEvent(registry *schema.Registry, payload []byte, ce CloudEvent) (models.UserEvent, error) {
+	var event models.UserEvent
+	if strings.HasPrefix(ce.DataContentType, schema.AvroContentType) || (ce.DataContentType == "" && schema.IsSingleObject(payload)) {
+		err := registry.Unmarshal(schema.LearningEventsSubject, payload, &event)
+		return event, err
+	}
+	err := json.Unmarshal(payload, &event)
+	return event, err
 }
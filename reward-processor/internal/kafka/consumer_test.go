@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHandleWithRetryRetriesUntilSuccess(t *testing.T) {
+	attempts := 0
+	h := &consumerGroupHandler{
+		log: zap.NewNop(),
+		handler: func(ctx context.Context, event models.UserEvent) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("database unavailable")
+			}
+			pos, ok := PositionFrom(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, int64(42), pos.Offset)
+			return nil
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), positionKey{}, Position{Topic: "learning-events", Offset: 42})
+	assert.True(t, h.handleWithRetry(ctx, models.UserEvent{UserID: "user-001"}))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestHandleWithRetryStopsWithSession(t *testing.T) {
+	h := &consumerGroupHandler{
+		log: zap.NewNop(),
+		handler: func(ctx context.Context, event models.UserEvent) error {
+			return errors.New("database unavailable")
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, h.handleWithRetry(ctx, models.UserEvent{UserID: "user-001"}))
+}
@@ -0,0 +1,196 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+// PoolConfig sizes the worker pool handling consumed events
+type PoolConfig struct {
+	// Workers is the number of events handled concurrently
+	Workers int
+	// QueueSize is the number of events each worker buffers. Reading from a
+	// partition blocks while the queue of the next event's worker is full.
+	QueueSize int
+}
+
+// DefaultPoolConfig returns the default pool configuration, which handles
+// events one at a time
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{Workers: 1, QueueSize: 64}
+}
+
+// PoolStats is a snapshot of the worker pool
+type PoolStats struct {
+	Workers int
+	// Busy is the number of workers handling an event
+	Busy int
+	// Queued is the number of events waiting for a worker
+	Queued int
+	// QueueCapacity is the number of events all queues can hold
+	QueueCapacity int
+	// Handled is the number of events handled since the consumer started
+	Handled int64
+}
+
+// Saturation returns the share of the pool in use, from 0 to 1: 1 means
+// every worker is busy and every queue is full, so reading is blocked
+func (s PoolStats) Saturation() float64 {
+	total := s.Workers + s.QueueCapacity
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Busy+s.Queued) / float64(total)
+}
+
+// job is an event to handle. done is called with whether it was handled.
+type job struct {
+	ctx   context.Context
+	event models.UserEvent
+	done  func(handled bool)
+}
+
+// workerPool handles events concurrently. Events are assigned to workers by
+// a hash of their user, so the events of a user are handled one at a time
+// and in the order they were submitted, whichever partition they come from.
+type workerPool struct {
+	queues  []chan job
+	handle  func(ctx context.Context, event models.UserEvent) bool
+	wg      sync.WaitGroup
+	busy    atomic.Int64
+	handled atomic.Int64
+}
+
+func newWorkerPool(cfg PoolConfig, handle func(ctx context.Context, event models.UserEvent) bool) *workerPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 0 {
+		cfg.QueueSize = 0
+	}
+
+	p := &workerPool{
+		queues: make([]chan job, cfg.Workers),
+		handle: handle,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan job, cfg.QueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *workerPool) work(queue chan job) {
+	defer p.wg.Done()
+	for j := range queue {
+		if j.ctx.Err() != nil {
+			// The session ended while the event was queued
+			j.done(false)
+			continue
+		}
+		p.busy.Add(1)
+		handled := p.handle(j.ctx, j.event)
+		p.busy.Add(-1)
+		p.handled.Add(1)
+		j.done(handled)
+	}
+}
+
+// submit queues a job on the worker of its user. It returns false if ctx
+// ended first.
+func (p *workerPool) submit(ctx context.Context, j job) bool {
+	h := fnv.New32a()
+	h.Write([]byte(j.event.UserID))
+	queue := p.queues[h.Sum32()%uint32(len(p.queues))]
+
+	select {
+	case queue <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *workerPool) stats() PoolStats {
+	s := PoolStats{
+		Workers: len(p.queues),
+		Busy:    int(p.busy.Load()),
+		Handled: p.handled.Load(),
+	}
+	for _, queue := range p.queues {
+		s.Queued += len(queue)
+		s.QueueCapacity += cap(queue)
+	}
+	return s
+}
+
+// close stops the workers once their queues are drained
+func (p *workerPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// offsetTracker follows the messages of a partition handled out of order. Its
+// watermark is the lowest offset not yet done, the offset a consumer may
+// safely resume from.
+type offsetTracker struct {
+	mu        sync.Mutex
+	inflight  []trackedOffset // in offset order
+	watermark int64
+}
+
+type trackedOffset struct {
+	offset int64
+	done   bool
+}
+
+func newOffsetTracker(initial int64) *offsetTracker {
+	return &offsetTracker{watermark: initial}
+}
+
+// add starts tracking a message; offsets must be added in increasing order.
+// It returns the current watermark.
+func (t *offsetTracker) add(offset int64) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.inflight) == 0 && offset > t.watermark {
+		// Skipped offsets, e.g. compacted messages, are never delivered
+		t.watermark = offset
+	}
+	t.inflight = append(t.inflight, trackedOffset{offset: offset})
+	return t.watermark
+}
+
+// done marks a message as done. It returns the new watermark and whether it
+// moved.
+func (t *offsetTracker) done(offset int64) (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := sort.Search(len(t.inflight), func(i int) bool { return t.inflight[i].offset >= offset })
+	if i == len(t.inflight) || t.inflight[i].offset != offset {
+		return t.watermark, false
+	}
+	t.inflight[i].done = true
+
+	moved := false
+	for len(t.inflight) > 0 && t.inflight[0].done {
+		t.watermark = t.inflight[0].offset + 1
+		t.inflight = t.inflight[1:]
+		moved = true
+	}
+	if moved && len(t.inflight) > 0 {
+		// The next message in flight may be further than the last done one
+		t.watermark = t.inflight[0].offset
+	}
+	return t.watermark, moved
+}
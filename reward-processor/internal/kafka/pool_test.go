@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetTrackerWatermark(t *testing.T) {
+	tracker := newOffsetTracker(10)
+
+	for _, offset := range []int64{10, 11, 13} {
+		assert.Equal(t, int64(10), tracker.add(offset))
+	}
+
+	// Done out of order: 11 finishes before 10
+	watermark, moved := tracker.done(11)
+	assert.False(t, moved)
+	assert.Equal(t, int64(10), watermark)
+
+	// 12 was never delivered, so the watermark jumps to 13
+	watermark, moved = tracker.done(10)
+	assert.True(t, moved)
+	assert.Equal(t, int64(13), watermark)
+
+	watermark, moved = tracker.done(13)
+	assert.True(t, moved)
+	assert.Equal(t, int64(14), watermark)
+
+	// The next message starts from the watermark, or past it after a gap
+	assert.Equal(t, int64(20), tracker.add(20))
+}
+
+func TestOffsetTrackerIgnoresUnknownOffsets(t *testing.T) {
+	tracker := newOffsetTracker(0)
+	tracker.add(0)
+
+	watermark, moved := tracker.done(5)
+	assert.False(t, moved)
+	assert.Equal(t, int64(0), watermark)
+}
+
+func TestWorkerPoolKeepsPerUserOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	pool := newWorkerPool(PoolConfig{Workers: 4, QueueSize: 2}, func(ctx context.Context, event models.UserEvent) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[event.UserID] = append(handled[event.UserID], event.ID)
+		return true
+	})
+
+	ctx := context.Background()
+	var done sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		event := models.UserEvent{ID: fmt.Sprint(i), UserID: fmt.Sprintf("user-%d", i%3)}
+		done.Add(1)
+		require.True(t, pool.submit(ctx, job{ctx: ctx, event: event, done: func(bool) { done.Done() }}))
+	}
+	done.Wait()
+	pool.close()
+
+	assert.Equal(t, []string{"0", "3", "6", "9", "12", "15", "18"}, handled["user-0"])
+	assert.Equal(t, []string{"1", "4", "7", "10", "13", "16", "19"}, handled["user-1"])
+	assert.Equal(t, int64(20), pool.stats().Handled)
+}
+
+func TestWorkerPoolStatsAndCancellation(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(PoolConfig{Workers: 1, QueueSize: 1}, func(ctx context.Context, event models.UserEvent) bool {
+		<-release
+		return true
+	})
+	defer pool.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan bool, 3)
+	submit := func() bool {
+		return pool.submit(ctx, job{ctx: ctx, event: models.UserEvent{UserID: "user-1"}, done: func(handled bool) { results <- handled }})
+	}
+
+	require.True(t, submit())
+	require.Eventually(t, func() bool { return pool.stats().Busy == 1 }, time.Second, time.Millisecond)
+	require.True(t, submit())
+
+	stats := pool.stats()
+	assert.Equal(t, PoolStats{Workers: 1, Busy: 1, Queued: 1, QueueCapacity: 1}, stats)
+	assert.Equal(t, 1.0, stats.Saturation())
+
+	// The pool is full: submitting blocks until the session ends
+	cancel()
+	assert.False(t, submit())
+
+	// The queued job is dropped unhandled since its session ended
+	close(release)
+	assert.True(t, <-results)
+	assert.False(t, <-results)
+}
@@ -1,20 +1,31 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"github.com/IBM/sarama"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/metrics"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/schema"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/contracts"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // Producer represents a Kafka producer for reward events
 type Producer struct {
+	client   sarama.Client
 	producer sarama.SyncProducer
 	topic    string
 	log      *zap.Logger
+	ce       CloudEvents
+	format   schema.Format
+	registry *schema.Registry
 }
 
 // NewProducer creates a new Kafka producer
@@ -25,12 +36,20 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
+	// Rewards are keyed by user so that a user's rewards stay ordered on one partition
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+
+	registry, err := schema.Default()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema registry: %w", err)
+	}
 
 	log.Info("Creating Kafka producer",
 		zap.Strings("brokers", brokers),
 		zap.String("topic", topic))
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	// The client is kept to check the connection to the brokers
+	client, err := sarama.NewClient(brokers, config)
 	if err != nil {
 		log.Error("Failed to create Kafka producer",
 			zap.Strings("brokers", brokers),
@@ -38,35 +57,123 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to create producer: %w", err)
 	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
 
 	log.Info("Successfully created Kafka producer")
 	return &Producer{
+		client:   client,
 		producer: producer,
 		topic:    topic,
 		log:      log,
+		ce: CloudEvents{
+			Mode:   CloudEventsBinary,
+			Source: "/reward-processor",
+			Type:   RewardTriggeredType,
+		},
+		format:   schema.FormatJSON,
+		registry: registry,
 	}, nil
 }
 
-// SendReward sends a reward event to Kafka
-func (p *Producer) SendReward(reward models.RewardTriggered) error {
-	value, err := json.Marshal(reward)
+// SetCloudEvents sets the CloudEvents binding used for produced messages.
+// By default messages use the binary mode.
+func (p *Producer) SetCloudEvents(ce CloudEvents) {
+	p.ce = ce
+}
+
+// SetFormat sets the payload format of produced rewards. By default rewards
+// are encoded as JSON.
+func (p *Producer) SetFormat(format schema.Format) {
+	p.format = format
+}
+
+// encodeReward encodes a reward as a user-rewards-value contract in the
+// configured format
+func (p *Producer) encodeReward(reward models.RewardTriggered) (payload, error) {
+	contract := contracts.RewardTriggered{
+		UserID: reward.UserID,
+		RuleID: reward.RuleID,
+		Reward: contracts.Reward{
+			Type:        string(reward.Reward.Type),
+			Amount:      reward.Reward.Amount,
+			Description: reward.Reward.Description,
+		},
+		Timestamp:   reward.Timestamp,
+		RuleVersion: reward.RuleVersion,
+	}
+
+	if p.format == schema.FormatAvro {
+		data, version, err := p.registry.Marshal(schema.UserRewardsSubject, contract)
+		if err != nil {
+			return payload{}, err
+		}
+		return payload{data: data, contentType: schema.AvroContentType, dataSchema: version.DataSchema()}, nil
+	}
+
+	latest, err := p.registry.Latest(schema.UserRewardsSubject)
+	if err != nil {
+		return payload{}, err
+	}
+	data, err := json.Marshal(contract)
 	if err != nil {
-		p.log.Error("Failed to marshal reward",
+		return payload{}, err
+	}
+	return payload{data: data, contentType: jsonContentType, dataSchema: latest.DataSchema()}, nil
+}
+
+// rewardEventID is the CloudEvents id of a reward. A rule grants its reward at
+// most once per user, so a reward sent again after a crash or by a replay
+// keeps its id and consumers can drop the duplicate.
+func rewardEventID(reward models.RewardTriggered) string {
+	return reward.RuleID + ":" + reward.UserID
+}
+
+// SendReward sends a reward event to Kafka, in a producer span whose
+// context is propagated in the message headers
+func (p *Producer) SendReward(ctx context.Context, reward models.RewardTriggered) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, p.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.topic),
+			attribute.String("messaging.message.id", rewardEventID(reward)),
+			attribute.String("rule.id", reward.RuleID),
+			attribute.Int("rule.version", reward.RuleVersion),
+			attribute.String("user.id", reward.UserID),
+		))
+	defer func() { tracing.End(span, err) }()
+
+	body, err := p.encodeReward(reward)
+	if err != nil {
+		p.log.Error("Failed to encode reward",
 			zap.Error(err),
+			zap.String("format", string(p.format)),
 			zap.Any("reward", reward))
-		return fmt.Errorf("failed to marshal reward: %w", err)
+		return fmt.Errorf("failed to encode reward: %w", err)
 	}
 
 	msg := &sarama.ProducerMessage{
 		Topic: p.topic,
-		Value: sarama.StringEncoder(value),
+		Key:   sarama.StringEncoder(reward.UserID),
 	}
+	if err := p.ce.encode(msg, body, rewardEventID(reward), reward.UserID); err != nil {
+		p.log.Error("Failed to encode CloudEvent",
+			zap.Error(err),
+			zap.Any("reward", reward))
+		return err
+	}
+	tracing.Inject(ctx, producerHeaders{msg})
 
 	p.log.Debug("Sending reward message",
 		zap.String("topic", p.topic),
 		zap.Any("reward", reward))
 
 	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.KafkaPublished.WithLabelValues(p.topic, metrics.Status(err)).Inc()
 	if err != nil {
 		p.log.Error("Failed to send reward message",
 			zap.String("topic", p.topic),
@@ -85,6 +192,25 @@ func (p *Producer) SendReward(reward models.RewardTriggered) error {
 	return nil
 }
 
+// Ping fetches the metadata of the producer's topic from the brokers, and
+// returns the number of brokers known to the client
+func (p *Producer) Ping(ctx context.Context) (int, error) {
+	if p.client.Closed() {
+		return 0, sarama.ErrClosedClient
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.client.RefreshMetadata(p.topic) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch metadata of topic %s: %w", p.topic, err)
+		}
+		return len(p.client.Brokers()), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
 // Close closes the producer
 func (p *Producer) Close() error {
 	p.log.Info("Closing Kafka producer")
@@ -93,6 +219,9 @@ func (p *Producer) Close() error {
 			zap.Error(err))
 		return fmt.Errorf("error closing producer: %w", err)
 	}
+	if err := p.client.Close(); err != nil && err != sarama.ErrClosedClient {
+		return fmt.Errorf("error closing client: %w", err)
+	}
 	p.log.Info("Successfully closed Kafka producer")
 	return nil
 }
@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/schema"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ReadOptions bounds the messages read by a Reader
+type ReadOptions struct {
+	// StartOffset is the first offset read in every partition. It defaults to
+	// sarama.OffsetOldest.
+	StartOffset int64
+	// StartTime, when set, starts every partition at its first message
+	// produced at or after it and takes precedence over StartOffset
+	StartTime time.Time
+	// EndOffsets holds the exclusive end offset of each partition. Partitions
+	// without one are read up to their high watermark when reading starts.
+	EndOffsets map[int32]int64
+}
+
+// Reader reads a bounded range of user events from a topic, outside of any
+// consumer group. It is used to replay the event history.
+type Reader struct {
+	client   sarama.Client
+	topic    string
+	log      *zap.Logger
+	ceTypes  map[string]bool
+	registry *schema.Registry
+}
+
+// NewReader creates a new Kafka reader for a topic
+func NewReader(brokers []string, topic string) (*Reader, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+
+	registry, err := schema.Default()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema registry: %w", err)
+	}
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	return &Reader{
+		client:   client,
+		topic:    topic,
+		log:      logger.Get(),
+		ceTypes:  map[string]bool{LearningEventType: true},
+		registry: registry,
+	}, nil
+}
+
+// GroupOffsets returns the committed offsets of a consumer group on the
+// reader's topic, i.e. the position up to which the group processed each
+// partition, and whether the group currently has active members
+func (r *Reader) GroupOffsets(group string) (map[int32]int64, bool, error) {
+	admin, err := sarama.NewClusterAdminFromClient(r.client)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cluster admin: %w", err)
+	}
+	// Closing the admin would close the shared client
+
+	partitions, err := r.client.Partitions(r.topic)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list partitions of %s: %w", r.topic, err)
+	}
+
+	resp, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{r.topic: partitions})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch offsets of group %s: %w", group, err)
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		block := resp.GetBlock(r.topic, partition)
+		if block == nil || block.Offset < 0 {
+			// Nothing was committed, so the group processed nothing yet
+			offsets[partition] = 0
+			continue
+		}
+		offsets[partition] = block.Offset
+	}
+
+	groups, err := admin.DescribeConsumerGroups([]string{group})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to describe group %s: %w", group, err)
+	}
+	active := len(groups) > 0 && len(groups[0].Members) > 0
+
+	return offsets, active, nil
+}
+
+// Read calls handler for every event in the range, partition by partition,
+// and returns the end offset of every partition. Messages that cannot be
+// decoded are logged and skipped, like the consumer does; an error of the
+// handler stops the read.
+func (r *Reader) Read(ctx context.Context, opts ReadOptions, handler Handler) (map[int32]int64, error) {
+	partitions, err := r.client.Partitions(r.topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", r.topic, err)
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	ends := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		start, end, err := r.bounds(partition, opts)
+		if err != nil {
+			return nil, err
+		}
+		ends[partition] = end
+		r.log.Info("Replaying partition",
+			zap.String("topic", r.topic),
+			zap.Int32("partition", partition),
+			zap.Int64("start_offset", start),
+			zap.Int64("end_offset", end))
+		if start >= end {
+			continue
+		}
+
+		if err := r.readPartition(ctx, consumer, partition, start, end, handler); err != nil {
+			return nil, err
+		}
+	}
+	return ends, nil
+}
+
+// bounds resolves the offsets range of a partition
+func (r *Reader) bounds(partition int32, opts ReadOptions) (int64, int64, error) {
+	newest, err := r.client.GetOffset(r.topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get high watermark of partition %d: %w", partition, err)
+	}
+	oldest, err := r.client.GetOffset(r.topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get oldest offset of partition %d: %w", partition, err)
+	}
+
+	end := newest
+	if offset, ok := opts.EndOffsets[partition]; ok && offset < end {
+		end = offset
+	}
+
+	start := opts.StartOffset
+	switch {
+	case !opts.StartTime.IsZero():
+		start, err = r.client.GetOffset(r.topic, partition, opts.StartTime.UnixMilli())
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get offset of partition %d at %s: %w", partition, opts.StartTime, err)
+		}
+		if start < 0 {
+			// No message at or after the start time
+			start = newest
+		}
+	case start == 0 || start == sarama.OffsetOldest:
+		start = oldest
+	case start < oldest:
+		return 0, 0, fmt.Errorf("offset %d of partition %d was already deleted, oldest is %d", start, partition, oldest)
+	}
+
+	return start, end, nil
+}
+
+func (r *Reader) readPartition(ctx context.Context, consumer sarama.Consumer, partition int32, start, end int64, handler Handler) error {
+	pc, err := consumer.ConsumePartition(r.topic, partition, start)
+	if err != nil {
+		return fmt.Errorf("failed to consume partition %d: %w", partition, err)
+	}
+	defer pc.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-pc.Errors():
+			return fmt.Errorf("failed to read partition %d: %w", partition, err)
+		case message := <-pc.Messages():
+			if message.Offset >= end {
+				return nil
+			}
+			if err := r.handle(ctx, message, handler); err != nil {
+				return err
+			}
+			// Stop at the last message instead of waiting for one past end
+			if message.Offset+1 >= end {
+				return nil
+			}
+		}
+	}
+}
+
+func (r *Reader) handle(ctx context.Context, message *sarama.ConsumerMessage, handler Handler) error {
+	payload, ce, err := decodeMessage(message)
+	if err != nil {
+		r.log.Error("Failed to decode message",
+			zap.Int32("partition", message.Partition),
+			zap.Int64("offset", message.Offset),
+			zap.Error(err))
+		return nil
+	}
+	if ce.Type != "" && len(r.ceTypes) > 0 && !r.ceTypes[ce.Type] {
+		return nil
+	}
+
+	event, err := decodeEvent(r.registry, payload, ce)
+	if err != nil {
+		r.log.Error("Failed to unmarshal event",
+			zap.Int32("partition", message.Partition),
+			zap.Int64("offset", message.Offset),
+			zap.Error(err))
+		return nil
+	}
+	return handler(ctx, event)
+}
+
+// Close closes the reader
+func (r *Reader) Close() error {
+	return r.client.Close()
+}
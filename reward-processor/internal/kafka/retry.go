@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/metrics"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+// ErrorClass tells whether retrying a failed event may succeed
+type ErrorClass int
+
+const (
+	// ErrorUnknown is an error that may or may not go away: the event is
+	// retried up to RetryConfig.MaxAttempts times
+	ErrorUnknown ErrorClass = iota
+	// ErrorTransient is an error of a dependency, such as a lost database
+	// connection, rather than of the event: it is retried until it succeeds
+	ErrorTransient
+	// ErrorPermanent is an error retrying cannot fix, such as a constraint
+	// violation: the event is dead-lettered at once
+	ErrorPermanent
+)
+
+// ErrorClassifier classifies the errors of a handler
+type ErrorClassifier func(err error) ErrorClass
+
+// DeadLetterFunc stores an event the handler gave up on, with the error of
+// its last attempt. The message is then done: its offset is committed.
+type DeadLetterFunc func(ctx context.Context, event models.UserEvent, cause error, attempts int) error
+
+// RetryConfig bounds the retries of failed events
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts at an event failing with an
+	// ErrorUnknown error before it is dead-lettered
+	MaxAttempts int
+	// StuckAfter is how long an event is retried before it is reported
+	// stuck
+	StuckAfter time.Duration
+}
+
+// DefaultRetryConfig returns the default retry configuration
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{MaxAttempts: 10, StuckAfter: time.Minute}
+}
+
+// permanentError marks an error as permanent
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as an error retrying the event cannot fix
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// classify returns the class of err: permanent if marked with Permanent,
+// transient if the context ended, and as told by classifier otherwise
+func classify(err error, classifier ErrorClassifier) ErrorClass {
+	var permanent *permanentError
+	switch {
+	case errors.As(err, &permanent):
+		return ErrorPermanent
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTransient
+	case classifier != nil:
+		return classifier(err)
+	}
+	return ErrorUnknown
+}
+
+// RetryingEvent is an event being retried
+type RetryingEvent struct {
+	Position
+	EventID   string
+	Since     time.Time
+	Attempts  int
+	LastError string
+}
+
+// retryTracker follows the events being retried, so that partitions held
+// back by an event can be reported
+type retryTracker struct {
+	mu     sync.Mutex
+	events map[Position]*RetryingEvent
+}
+
+func newRetryTracker() *retryTracker {
+	return &retryTracker{events: make(map[Position]*RetryingEvent)}
+}
+
+// failed records a failed attempt at the event at pos
+func (t *retryTracker) failed(pos Position, eventID string, attempt int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := trackerKey(pos)
+	e, ok := t.events[key]
+	if !ok {
+		e = &RetryingEvent{Position: key, EventID: eventID, Since: time.Now()}
+		t.events[key] = e
+		metrics.KafkaRetryingEvents.WithLabelValues(pos.Topic, strconv.Itoa(int(pos.Partition))).Inc()
+	}
+	e.Attempts = attempt
+	e.LastError = err.Error()
+}
+
+// done forgets the event at pos, handled or abandoned
+func (t *retryTracker) done(pos Position) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := trackerKey(pos)
+	if _, ok := t.events[key]; ok {
+		delete(t.events, key)
+		metrics.KafkaRetryingEvents.WithLabelValues(pos.Topic, strconv.Itoa(int(pos.Partition))).Dec()
+	}
+}
+
+// since returns the events retried since before cutoff, by position
+func (t *retryTracker) since(cutoff time.Time) []RetryingEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var stuck []RetryingEvent
+	for _, e := range t.events {
+		if !e.Since.After(cutoff) {
+			stuck = append(stuck, *e)
+		}
+	}
+	sort.Slice(stuck, func(i, j int) bool {
+		a, b := stuck[i], stuck[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		if a.Partition != b.Partition {
+			return a.Partition < b.Partition
+		}
+		return a.Offset < b.Offset
+	})
+	return stuck
+}
+
+// trackerKey identifies a message regardless of its watermark
+func trackerKey(pos Position) Position {
+	return Position{Topic: pos.Topic, Partition: pos.Partition, Offset: pos.Offset}
+}
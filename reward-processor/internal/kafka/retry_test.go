@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var errTransient = errors.New("connection refused")
+
+func classifyTest(err error) ErrorClass {
+	if errors.Is(err, errTransient) {
+		return ErrorTransient
+	}
+	return ErrorUnknown
+}
+
+type deadLetters struct {
+	letters  []models.UserEvent
+	attempts []int
+	err      error
+}
+
+func (d *deadLetters) add(ctx context.Context, event models.UserEvent, cause error, attempts int) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.letters = append(d.letters, event)
+	d.attempts = append(d.attempts, attempts)
+	return nil
+}
+
+func retryHandler(handler Handler, dl *deadLetters, maxAttempts int) *consumerGroupHandler {
+	return &consumerGroupHandler{
+		log:        zap.NewNop(),
+		handler:    handler,
+		retry:      RetryConfig{MaxAttempts: maxAttempts, StuckAfter: time.Minute},
+		classifier: classifyTest,
+		deadLetter: dl.add,
+		retrying:   newRetryTracker(),
+	}
+}
+
+func TestHandleWithRetryDeadLettersPermanentErrors(t *testing.T) {
+	attempts := 0
+	dl := &deadLetters{}
+	h := retryHandler(func(ctx context.Context, event models.UserEvent) error {
+		attempts++
+		return Permanent(errors.New("violates check constraint"))
+	}, dl, 10)
+
+	ctx := context.WithValue(context.Background(), positionKey{}, Position{Topic: "learning-events", Offset: 42})
+	assert.True(t, h.handleWithRetry(ctx, models.UserEvent{ID: "event-1", UserID: "user-001"}))
+	assert.Equal(t, 1, attempts)
+	require.Len(t, dl.letters, 1)
+	assert.Equal(t, "event-1", dl.letters[0].ID)
+	assert.Equal(t, []int{1}, dl.attempts)
+	assert.Empty(t, h.retrying.since(time.Now()))
+}
+
+func TestHandleWithRetryDeadLettersAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	dl := &deadLetters{}
+	h := retryHandler(func(ctx context.Context, event models.UserEvent) error {
+		attempts++
+		return errors.New("unexpected")
+	}, dl, 3)
+
+	assert.True(t, h.handleWithRetry(context.Background(), models.UserEvent{UserID: "user-001"}))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []int{3}, dl.attempts)
+}
+
+func TestHandleWithRetryRetriesTransientErrors(t *testing.T) {
+	attempts := 0
+	dl := &deadLetters{}
+	h := retryHandler(func(ctx context.Context, event models.UserEvent) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("increment: %w", errTransient)
+		}
+		return nil
+	}, dl, 1)
+
+	assert.True(t, h.handleWithRetry(context.Background(), models.UserEvent{UserID: "user-001"}))
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, dl.letters)
+}
+
+func TestHandleWithRetryKeepsRetryingIfDeadLetteringFails(t *testing.T) {
+	dl := &deadLetters{err: errors.New("database unavailable")}
+	h := retryHandler(func(ctx context.Context, event models.UserEvent) error {
+		return Permanent(errors.New("violates check constraint"))
+	}, dl, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, h.handleWithRetry(ctx, models.UserEvent{UserID: "user-001"}))
+	assert.Empty(t, dl.letters)
+}
+
+func TestRetryTrackerReportsStuckEvents(t *testing.T) {
+	tracker := newRetryTracker()
+	pos := Position{Topic: "learning-events", Partition: 2, Offset: 42, Watermark: 40}
+	tracker.failed(pos, "event-1", 1, errors.New("database unavailable"))
+	tracker.failed(pos, "event-1", 2, errors.New("still unavailable"))
+
+	assert.Empty(t, tracker.since(time.Now().Add(-time.Minute)))
+	stuck := tracker.since(time.Now())
+	require.Len(t, stuck, 1)
+	assert.Equal(t, int32(2), stuck[0].Partition)
+	assert.Equal(t, int64(42), stuck[0].Offset)
+	assert.Equal(t, 2, stuck[0].Attempts)
+	assert.Equal(t, "still unavailable", stuck[0].LastError)
+
+	tracker.done(pos)
+	assert.Empty(t, tracker.since(time.Now()))
+}
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, ErrorPermanent, classify(fmt.Errorf("handle: %w", Permanent(errTransient)), classifyTest))
+	assert.Equal(t, ErrorTransient, classify(context.DeadlineExceeded, nil))
+	assert.Equal(t, ErrorTransient, classify(errTransient, classifyTest))
+	assert.Equal(t, ErrorUnknown, classify(errors.New("unexpected"), nil))
+}
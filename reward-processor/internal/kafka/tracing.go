@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// consumerHeaders reads the trace context of a consumed message
+type consumerHeaders []*sarama.RecordHeader
+
+var _ propagation.TextMapCarrier = consumerHeaders(nil)
+
+func (h consumerHeaders) Get(key string) string {
+	for _, header := range h {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set is a no-op: consumed messages are read-only
+func (h consumerHeaders) Set(key, value string) {}
+
+func (h consumerHeaders) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for _, header := range h {
+		if header != nil {
+			keys = append(keys, string(header.Key))
+		}
+	}
+	return keys
+}
+
+// producerHeaders writes the trace context of a message to publish
+type producerHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+var _ propagation.TextMapCarrier = producerHeaders{}
+
+func (h producerHeaders) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (h producerHeaders) Set(key, value string) {
+	for i, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (h producerHeaders) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, header := range h.msg.Headers {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
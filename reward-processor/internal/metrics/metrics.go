@@ -0,0 +1,70 @@
+// Package metrics defines the Prometheus metrics of the reward processor. The
+// HTTP, GraphQL and database metrics common to the services are defined in
+// the observability module.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// KafkaConsumed counts consumed messages by topic and outcome: handled,
+	// skipped (undecodable or of another type) or dropped (session ended)
+	KafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Consumed Kafka messages by topic and status (handled, skipped or dropped).",
+	}, []string{"topic", "status"})
+
+	// KafkaHandlerErrors counts failed attempts at handling a message
+	KafkaHandlerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_handler_errors_total",
+		Help: "Failed attempts at handling a consumed Kafka message, by topic.",
+	}, []string{"topic"})
+
+	// KafkaDeadLettered counts the events given up on and dead-lettered
+	KafkaDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_dead_lettered_total",
+		Help: "Consumed Kafka messages given up on and stored as dead letters, by topic.",
+	}, []string{"topic"})
+
+	// KafkaRetryingEvents is the number of events of a partition being
+	// retried. Each holds back the watermark of its partition.
+	KafkaRetryingEvents = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_retrying_events",
+		Help: "Events of a partition being retried after failing, which hold back its committed offset.",
+	}, []string{"topic", "partition"})
+
+	// KafkaConsumerLag is the number of messages of a partition after the
+	// last one read
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages of a partition after the last message read by the consumer.",
+	}, []string{"topic", "partition"})
+
+	// KafkaPublished counts published messages by topic and status
+	KafkaPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_published_total",
+		Help: "Published Kafka messages by topic and status (ok or error).",
+	}, []string{"topic", "status"})
+
+	// RulesEvaluated counts the rule evaluations, by rule
+	RulesEvaluated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rules_evaluated_total",
+		Help: "Events evaluated against a rule whose event type and conditions matched, by rule ID.",
+	}, []string{"rule_id"})
+
+	// RulesTriggered counts the triggered rules, by rule
+	RulesTriggered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rules_triggered_total",
+		Help: "Triggered rules by rule ID.",
+	}, []string{"rule_id"})
+)
+
+// Status returns the status label of an outcome
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,116 @@
+// Package outbox publishes the rewards queued in the reward outbox.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Sender publishes a reward. The context carries the trace of the event
+// that triggered it.
+type Sender interface {
+	SendReward(ctx context.Context, reward models.RewardTriggered) error
+}
+
+// Config holds the relay configuration
+type Config struct {
+	// BatchSize is the maximum number of rewards published per transaction
+	BatchSize int
+	// PollInterval is the wait between two polls once the outbox is empty
+	PollInterval time.Duration
+	// MaxBackoff caps the wait after failed publishes, which doubles from
+	// PollInterval on every consecutive failure
+	MaxBackoff time.Duration
+	// Retention is how long sent rewards are kept; zero keeps them forever
+	Retention time.Duration
+}
+
+// DefaultConfig returns the default relay configuration
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		PollInterval: 500 * time.Millisecond,
+		MaxBackoff:   30 * time.Second,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// cleanupInterval is how often sent rewards past the retention are deleted
+const cleanupInterval = time.Hour
+
+// Relay publishes outbox rewards until they are sent
+type Relay struct {
+	repo   repository.OutboxRepository
+	sender Sender
+	cfg    Config
+	logger *zap.Logger
+}
+
+// NewRelay creates an outbox relay
+func NewRelay(repo repository.OutboxRepository, sender Sender, cfg Config, logger *zap.Logger) *Relay {
+	return &Relay{repo: repo, sender: sender, cfg: cfg, logger: logger}
+}
+
+// Run publishes rewards until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	r.logger.Info("Starting outbox relay",
+		zap.Int("batch_size", r.cfg.BatchSize),
+		zap.Duration("poll_interval", r.cfg.PollInterval))
+
+	wait := time.Duration(0)
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Outbox relay stopped")
+			return
+		case <-time.After(wait):
+		}
+
+		wait = r.relayBatch(ctx, wait)
+
+		if r.cfg.Retention > 0 && time.Since(lastCleanup) >= cleanupInterval {
+			lastCleanup = time.Now()
+			r.cleanup(ctx)
+		}
+	}
+}
+
+// relayBatch publishes one batch and returns the wait before the next one
+func (r *Relay) relayBatch(ctx context.Context, previousWait time.Duration) time.Duration {
+	sent, err := r.repo.ProcessPending(ctx, r.cfg.BatchSize, func(reward models.OutboxReward) error {
+		return r.sender.SendReward(tracing.Deserialize(ctx, reward.TraceContext), reward.Triggered())
+	})
+	if sent > 0 {
+		r.logger.Debug("Published outbox rewards", zap.Int("count", sent))
+	}
+	if err != nil {
+		wait := min(max(previousWait*2, r.cfg.PollInterval), r.cfg.MaxBackoff)
+		r.logger.Error("Failed to publish outbox rewards, retrying",
+			zap.Error(err),
+			zap.Int("published", sent),
+			zap.Duration("backoff", wait))
+		return wait
+	}
+	if sent == r.cfg.BatchSize {
+		// More rewards are probably pending
+		return 0
+	}
+	return r.cfg.PollInterval
+}
+
+func (r *Relay) cleanup(ctx context.Context) {
+	deleted, err := r.repo.DeleteSentBefore(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Error("Failed to delete sent outbox rewards", zap.Error(err))
+		return
+	}
+	if deleted > 0 {
+		r.logger.Info("Deleted sent outbox rewards", zap.Int64("count", deleted))
+	}
+}
@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// memoryOutbox mimics the ordering and failure handling of the outbox table
+type memoryOutbox struct {
+	pending []models.OutboxReward
+	sent    []models.OutboxReward
+}
+
+func (m *memoryOutbox) Add(ctx context.Context, reward models.RewardTriggered) error {
+	m.pending = append(m.pending, models.OutboxReward{ID: uint64(len(m.pending) + len(m.sent) + 1), UserID: reward.UserID, RuleID: reward.RuleID})
+	return nil
+}
+
+func (m *memoryOutbox) ProcessPending(ctx context.Context, limit int, send func(models.OutboxReward) error) (int, error) {
+	sent := 0
+	for len(m.pending) > 0 && sent < limit {
+		if err := send(m.pending[0]); err != nil {
+			m.pending[0].Attempts++
+			return sent, err
+		}
+		m.sent = append(m.sent, m.pending[0])
+		m.pending = m.pending[1:]
+		sent++
+	}
+	return sent, nil
+}
+
+func (m *memoryOutbox) DeleteSentBefore(ctx context.Context, t time.Time) (int64, error) {
+	return 0, nil
+}
+
+// flakySender fails as many sends as failures before succeeding
+type flakySender struct {
+	failures int
+	sent     []string
+}
+
+func (s *flakySender) SendReward(_ context.Context, reward models.RewardTriggered) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("kafka unavailable")
+	}
+	s.sent = append(s.sent, reward.UserID)
+	return nil
+}
+
+func testConfig() Config {
+	return Config{BatchSize: 2, PollInterval: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}
+}
+
+func TestRelayBatchBacksOffOnFailure(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.Add(context.Background(), models.RewardTriggered{UserID: "user-1"})
+	sender := &flakySender{failures: 3}
+	relay := NewRelay(repo, sender, testConfig(), zap.NewNop())
+
+	var waits []time.Duration
+	wait := time.Duration(0)
+	for i := 0; i < 4; i++ {
+		wait = relay.relayBatch(context.Background(), wait)
+		waits = append(waits, wait)
+	}
+
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 10 * time.Millisecond}, waits)
+	assert.Equal(t, []string{"user-1"}, sender.sent)
+	assert.Equal(t, 3, repo.sent[0].Attempts)
+}
+
+func TestRelayBatchContinuesWhileFull(t *testing.T) {
+	repo := &memoryOutbox{}
+	for _, user := range []string{"user-1", "user-2", "user-3"} {
+		repo.Add(context.Background(), models.RewardTriggered{UserID: user})
+	}
+	sender := &flakySender{}
+	relay := NewRelay(repo, sender, testConfig(), zap.NewNop())
+
+	assert.Equal(t, time.Duration(0), relay.relayBatch(context.Background(), 0))
+	assert.Equal(t, 10*time.Millisecond, relay.relayBatch(context.Background(), 0))
+	assert.Equal(t, []string{"user-1", "user-2", "user-3"}, sender.sent)
+}
+
+func TestRunPublishesUntilStopped(t *testing.T) {
+	repo := &memoryOutbox{}
+	repo.Add(context.Background(), models.RewardTriggered{UserID: "user-1"})
+	sender := &flakySender{failures: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	NewRelay(repo, sender, testConfig(), zap.NewNop()).Run(ctx)
+
+	assert.Equal(t, []string{"user-1"}, sender.sent)
+	assert.Empty(t, repo.pending)
+}
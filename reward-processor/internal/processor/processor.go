@@ -2,10 +2,18 @@ package processor
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/countcache"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/outbox"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/schema"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"go.uber.org/zap"
 )
@@ -17,20 +25,77 @@ type Config struct {
 	ConsumerTopics []string
 	ProducerTopic  string
 	Rules          []models.Rule
+	// RuleSource loads the rules again every RuleReload, so that rules
+	// published after the start are evaluated; without it, Rules are kept
+	RuleSource func(ctx context.Context) ([]models.Rule, error)
+	RuleReload time.Duration
+	// CloudEventsMode selects the CloudEvents binding of produced rewards.
+	// Consumed events are accepted in any mode as well as without CloudEvents.
+	CloudEventsMode   kafka.CloudEventsMode
+	CloudEventsSource string
+	// PayloadFormat is the encoding of produced rewards. Consumed events are
+	// decoded according to their content type.
+	PayloadFormat schema.Format
+	// Outbox configures the relay publishing triggered rewards
+	Outbox outbox.Config
+	// Pool sizes the worker pool handling events; by default events are
+	// handled one at a time
+	Pool kafka.PoolConfig
+	// CountCache configures the write-behind cache of event counts; the
+	// cache is disabled by default. It requires events keyed by user.
+	CountCache countcache.Config
+	// Retry bounds the retries of events failing to be handled
+	Retry kafka.RetryConfig
+}
+
+// Repositories holds the storage used by the processor
+type Repositories struct {
+	Events repository.UserEventRepository
+	// Ledger records sent rewards, which lets a replay tell which rewards
+	// are missing
+	Ledger repository.RewardLedgerRepository
+	// Offsets stores the consumer offsets in the transaction of each event
+	Offsets repository.OffsetRepository
+	// Outbox queues triggered rewards until the relay publishes them
+	Outbox repository.OutboxRepository
+	// DeadLetters stores the events given up on; without it, failed events
+	// are retried until they succeed
+	DeadLetters repository.DeadLetterRepository
+	Tx          repository.Transactor
 }
 
 // Processor handles the reward processing logic
 type Processor struct {
 	consumer *kafka.Consumer
 	producer *kafka.Producer
+	relay    *outbox.Relay
+	cache    *countcache.Cache
+	wg       sync.WaitGroup
+	stop     context.CancelFunc
 	engine   *rules.Engine
+	repos    Repositories
+	group    string
+	retry    kafka.RetryConfig
+	source   func(ctx context.Context) ([]models.Rule, error)
+	reload   time.Duration
 	logger   *zap.Logger
 }
 
 // New creates a new reward processor
-func New(cfg Config, eventRepo repository.UserEventRepository, logger *zap.Logger) (*Processor, error) {
+func New(cfg Config, repos Repositories, logger *zap.Logger) (*Processor, error) {
+	counts := repos.Events
+	var cache *countcache.Cache
+	if cfg.CountCache.Enabled() {
+		store, ok := repos.Events.(repository.CountStore)
+		if !ok {
+			return nil, fmt.Errorf("count cache requires a count store, got %T", repos.Events)
+		}
+		cache = countcache.New(store, repos.Offsets, repos.Tx, cfg.ConsumerGroup, cfg.CountCache, logger)
+		counts = cache
+	}
+
 	// Create rules engine with repository
-	engine := rules.NewEngine(cfg.Rules, eventRepo, logger)
+	engine := rules.NewEngine(cfg.Rules, counts, logger)
 
 	// Create Kafka consumer
 	consumer, err := kafka.NewConsumer(
@@ -41,6 +106,13 @@ func New(cfg Config, eventRepo repository.UserEventRepository, logger *zap.Logge
 	if err != nil {
 		return nil, err
 	}
+	consumer.SetOffsetStore(repos.Offsets)
+	if cfg.Pool.Workers > 0 {
+		consumer.SetPool(cfg.Pool)
+	}
+	if cache != nil {
+		consumer.SetPartitionObserver(cache)
+	}
 
 	// Create Kafka producer
 	producer, err := kafka.NewProducer(
@@ -51,55 +123,265 @@ func New(cfg Config, eventRepo repository.UserEventRepository, logger *zap.Logge
 		consumer.Close()
 		return nil, err
 	}
+	if cfg.CloudEventsMode != "" {
+		producer.SetCloudEvents(kafka.CloudEvents{
+			Mode:   cfg.CloudEventsMode,
+			Source: cfg.CloudEventsSource,
+			Type:   kafka.RewardTriggeredType,
+		})
+	}
+	if cfg.PayloadFormat != "" {
+		producer.SetFormat(cfg.PayloadFormat)
+	}
 
+	retry := cfg.Retry
+	if retry == (kafka.RetryConfig{}) {
+		retry = kafka.DefaultRetryConfig()
+	}
 	p := &Processor{
 		consumer: consumer,
 		producer: producer,
+		relay:    outbox.NewRelay(repos.Outbox, producer, cfg.Outbox, logger),
+		cache:    cache,
 		engine:   engine,
+		repos:    repos,
+		group:    cfg.ConsumerGroup,
+		retry:    retry,
+		source:   cfg.RuleSource,
+		reload:   cfg.RuleReload,
 		logger:   logger,
 	}
 
 	// Set up event handler
 	consumer.SetHandler(p.handleEvent)
+	var deadLetter kafka.DeadLetterFunc
+	if repos.DeadLetters != nil {
+		deadLetter = p.deadLetter
+	}
+	consumer.SetRetry(retry, classifyError, deadLetter)
 
 	return p, nil
 }
 
-// handleEvent processes a single user event
-func (p *Processor) handleEvent(event models.UserEvent) error {
-	// Process event through rules engine
-	triggered, err := p.engine.EvaluateEvent(context.Background(), event)
-	if err != nil {
-		p.logger.Error("Failed to evaluate event",
-			zap.Error(err),
-			zap.Any("event", event))
-		return err
-	}
+// handleEvent processes a single user event. The consumer's worker pool
+// never handles two events of the same user at once, even when they arrive on
+// different partitions.
+//
+// The count increment, the ledger entries, the outbox rewards, the processed
+// message and the partition watermark are committed in one transaction. A
+// crash before the commit makes the event be processed again from scratch,
+// and a committed reward is published by the relay even if Kafka is down at
+// the time.
+//
+// With the count cache, the cache writes the counts and the watermark of the
+// partitions it owns instead. The transaction then only holds the ledger
+// entries and the outbox rewards, which a redelivered event does not grant
+// twice.
+func (p *Processor) handleEvent(ctx context.Context, event models.UserEvent) error {
+	return p.repos.Tx.RunInTx(ctx, func(ctx context.Context) error {
+		pos, hasPos := kafka.PositionFrom(ctx)
+		cached := hasPos && p.cache != nil && p.cache.Owns(pos)
+		if cached && p.cache.Processed(pos) {
+			p.logger.Debug("Skipping already processed message", zap.Any("position", pos))
+			return nil
+		}
+		if hasPos && !cached {
+			first, err := p.repos.Offsets.MarkProcessed(ctx, p.group, pos.Topic, pos.Partition, pos.Offset)
+			if err != nil {
+				p.logger.Error("Failed to mark message as processed",
+					zap.Error(err),
+					zap.Any("position", pos))
+				return err
+			}
+			if !first {
+				p.logger.Debug("Skipping already processed message", zap.Any("position", pos))
+				return nil
+			}
+		}
 
-	// Send triggered rewards
-	for _, reward := range triggered {
-		if err := p.producer.SendReward(reward); err != nil {
-			p.logger.Error("Failed to send reward",
+		// Process event through rules engine
+		triggered, err := p.engine.EvaluateEvent(ctx, event)
+		if err != nil {
+			p.logger.Error("Failed to evaluate event",
 				zap.Error(err),
-				zap.Any("reward", reward))
+				zap.Any("event", event))
 			return err
 		}
+
+		for _, reward := range triggered {
+			granted, err := p.repos.Ledger.Record(ctx, reward)
+			if err != nil {
+				p.logger.Error("Failed to record reward in ledger",
+					zap.Error(err),
+					zap.Any("reward", reward))
+				return err
+			}
+			if !granted {
+				// Already granted, e.g. by a replay
+				continue
+			}
+			if err := p.repos.Outbox.Add(ctx, reward); err != nil {
+				p.logger.Error("Failed to queue reward in outbox",
+					zap.Error(err),
+					zap.Any("reward", reward))
+				return err
+			}
+		}
+
+		if hasPos && !cached {
+			if err := p.repos.Offsets.Save(ctx, p.group, pos.Topic, pos.Partition, pos.Watermark); err != nil {
+				p.logger.Error("Failed to store consumer offset",
+					zap.Error(err),
+					zap.Any("position", pos))
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// deadLetter stores an event given up on and marks its message as
+// processed, in one transaction, so that its partition moves on
+func (p *Processor) deadLetter(ctx context.Context, event models.UserEvent, cause error, attempts int) error {
+	pos, _ := kafka.PositionFrom(ctx)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
 	}
+	return p.repos.Tx.RunInTx(ctx, func(ctx context.Context) error {
+		if p.cache == nil || !p.cache.Owns(pos) {
+			if _, err := p.repos.Offsets.MarkProcessed(ctx, p.group, pos.Topic, pos.Partition, pos.Offset); err != nil {
+				return err
+			}
+			if err := p.repos.Offsets.Save(ctx, p.group, pos.Topic, pos.Partition, pos.Watermark); err != nil {
+				return err
+			}
+		}
+		return p.repos.DeadLetters.Add(ctx, models.DeadLetterEvent{
+			GroupID:   p.group,
+			Topic:     pos.Topic,
+			Partition: pos.Partition,
+			Offset:    pos.Offset,
+			EventID:   event.ID,
+			UserID:    event.UserID,
+			Payload:   string(payload),
+			Error:     cause.Error(),
+			Attempts:  attempts,
+			CreatedAt: time.Now(),
+		})
+	})
+}
 
-	return nil
+// classifyError tells database errors retrying may fix from those caused by
+// the event
+func classifyError(err error) kafka.ErrorClass {
+	switch {
+	case repository.IsTransient(err):
+		return kafka.ErrorTransient
+	case repository.IsPermanent(err):
+		return kafka.ErrorPermanent
+	}
+	return kafka.ErrorUnknown
+}
+
+// PoolStats returns a snapshot of the worker pool handling events
+func (p *Processor) PoolStats() kafka.PoolStats {
+	return p.consumer.PoolStats()
+}
+
+// RegisterChecks adds the readiness checks of the processor: membership of
+// the consumer group, events held back by failures, connection of the
+// producer to the brokers, and the rules loaded in the engine
+func (p *Processor) RegisterChecks(checker *health.Checker) {
+	checker.Add("kafka_consumer", func(ctx context.Context) (map[string]any, error) {
+		member, ok := p.consumer.Membership()
+		if !ok {
+			return map[string]any{"group": p.group}, fmt.Errorf("not a member of consumer group %s", p.group)
+		}
+		return map[string]any{
+			"group":         p.group,
+			"member_id":     member.MemberID,
+			"generation_id": member.GenerationID,
+			"partitions":    member.Claims,
+			"since":         member.Since,
+		}, nil
+	})
+	checker.Add("kafka_retries", func(ctx context.Context) (map[string]any, error) {
+		stuck := p.consumer.Stuck()
+		if len(stuck) > 0 {
+			return map[string]any{"stuck": stuck}, fmt.Errorf("%d events failing since over %s", len(stuck), p.retry.StuckAfter)
+		}
+		return nil, nil
+	})
+	checker.Add("kafka_producer", func(ctx context.Context) (map[string]any, error) {
+		brokers, err := p.producer.Ping(ctx)
+		return map[string]any{"brokers": brokers}, err
+	})
+	checker.Add("rules", rules.Check(p.engine))
 }
 
 // Start begins processing events
 func (p *Processor) Start(ctx context.Context) error {
 	p.logger.Info("Starting reward processor")
+
+	runCtx, stop := context.WithCancel(ctx)
+	p.stop = stop
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.relay.Run(runCtx)
+	}()
+	if p.cache != nil {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.cache.Run(runCtx)
+		}()
+	}
+	if p.source != nil && p.reload > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.reloadRules(runCtx)
+		}()
+	}
+
 	return p.consumer.Start(ctx)
 }
 
+// reloadRules loads the rules into the engine every reload interval until ctx
+// is done. A failed load keeps the previous rules and fails the readiness
+// check until a load succeeds.
+func (p *Processor) reloadRules(ctx context.Context) {
+	ticker := time.NewTicker(p.reload)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := p.engine.Reload(ctx, p.source); err != nil {
+			p.logger.Error("Failed to reload rules", zap.Error(err))
+			continue
+		}
+		status := p.engine.Status()
+		p.logger.Debug("Reloaded rules", zap.Int("rules", status.Rules), zap.Int("active", status.Active))
+	}
+}
+
 // Close closes the processor and its resources
 func (p *Processor) Close() error {
 	if err := p.consumer.Close(); err != nil {
 		p.logger.Error("Error closing consumer", zap.Error(err))
 	}
+	// Let the relay finish its batch before closing the producer, and the
+	// count cache its last flush
+	if p.stop != nil {
+		p.stop()
+	}
+	p.wg.Wait()
 	if err := p.producer.Close(); err != nil {
 		p.logger.Error("Error closing producer", zap.Error(err))
 	}
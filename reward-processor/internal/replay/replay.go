@@ -0,0 +1,147 @@
+// Package replay rebuilds the event counts, and optionally the rewards, from
+// the raw history of learning events.
+package replay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Mode selects what a replay does with the rewards its rules trigger
+type Mode string
+
+const (
+	// ModeCountsOnly only rebuilds the event counts
+	ModeCountsOnly Mode = "counts-only"
+	// ModeNoEmit re-evaluates the rules and reports the rewards missing from
+	// the ledger without sending them
+	ModeNoEmit Mode = "no-emit"
+	// ModeEmitMissing re-evaluates the rules and queues the rewards missing
+	// from the ledger in the reward outbox
+	ModeEmitMissing Mode = "emit-missing"
+)
+
+// ParseMode parses a replay mode, defaulting to ModeCountsOnly
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeCountsOnly:
+		return ModeCountsOnly, nil
+	case ModeNoEmit, ModeEmitMissing:
+		return Mode(s), nil
+	default:
+		return "", fmt.Errorf("invalid replay mode %q: must be %s, %s or %s", s, ModeCountsOnly, ModeNoEmit, ModeEmitMissing)
+	}
+}
+
+// Source yields the events of the history in order
+type Source interface {
+	Events(ctx context.Context, fn func(ctx context.Context, event models.UserEvent) error) error
+}
+
+// Outbox queues rewards for publishing
+type Outbox interface {
+	Add(ctx context.Context, reward models.RewardTriggered) error
+}
+
+// Result summarizes a replay
+type Result struct {
+	// Events is the number of events replayed
+	Events int
+	// Triggered is the number of rewards the rules triggered
+	Triggered int
+	// Missing is the number of triggered rewards absent from the ledger
+	Missing int
+	// Emitted is the number of missing rewards queued for publishing
+	Emitted int
+}
+
+// Replayer replays events into an event counts repository, usually a shadow
+// table
+type Replayer struct {
+	mode   Mode
+	engine *rules.Engine
+	ledger repository.RewardLedgerRepository
+	outbox Outbox
+	logger *zap.Logger
+}
+
+// New creates a replayer. The ledger is required unless mode is
+// ModeCountsOnly, and the outbox only for ModeEmitMissing.
+func New(mode Mode, ruleSet []models.Rule, counts repository.UserEventRepository, ledger repository.RewardLedgerRepository, outbox Outbox, logger *zap.Logger) (*Replayer, error) {
+	if mode != ModeCountsOnly && ledger == nil {
+		return nil, fmt.Errorf("replay mode %s requires a reward ledger", mode)
+	}
+	if mode == ModeEmitMissing && outbox == nil {
+		return nil, fmt.Errorf("replay mode %s requires an outbox", mode)
+	}
+
+	// The engine logs every event at info level, which floods a replay
+	quiet := logger.WithOptions(zap.IncreaseLevel(zap.WarnLevel))
+	return &Replayer{
+		mode:   mode,
+		engine: rules.NewEngine(ruleSet, counts, quiet),
+		ledger: ledger,
+		outbox: outbox,
+		logger: logger,
+	}, nil
+}
+
+// Run replays every event of the source
+func (r *Replayer) Run(ctx context.Context, source Source) (Result, error) {
+	var result Result
+	err := source.Events(ctx, func(ctx context.Context, event models.UserEvent) error {
+		triggered, err := r.engine.EvaluateEvent(ctx, event)
+		if err != nil {
+			return fmt.Errorf("failed to replay event %s: %w", event.ID, err)
+		}
+		result.Events++
+		if result.Events%10000 == 0 {
+			r.logger.Info("Replay progress", zap.Int("events", result.Events))
+		}
+
+		if r.mode == ModeCountsOnly {
+			return nil
+		}
+		for _, reward := range triggered {
+			result.Triggered++
+			if err := r.handleReward(ctx, reward, &result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r *Replayer) handleReward(ctx context.Context, reward models.RewardTriggered, result *Result) error {
+	granted, err := r.ledger.Has(ctx, reward.UserID, reward.RuleID)
+	if err != nil {
+		return fmt.Errorf("failed to check reward ledger: %w", err)
+	}
+	if granted {
+		return nil
+	}
+
+	result.Missing++
+	r.logger.Info("Missing reward",
+		zap.String("user_id", reward.UserID),
+		zap.String("rule_id", reward.RuleID),
+		zap.String("reward_type", string(reward.Reward.Type)))
+	if r.mode != ModeEmitMissing {
+		return nil
+	}
+
+	if err := r.outbox.Add(ctx, reward); err != nil {
+		return fmt.Errorf("failed to queue reward: %w", err)
+	}
+	result.Emitted++
+	if _, err := r.ledger.Record(ctx, reward); err != nil {
+		return fmt.Errorf("failed to record reward: %w", err)
+	}
+	return nil
+}
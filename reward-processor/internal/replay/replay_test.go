@@ -0,0 +1,194 @@
+package replay_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/replay"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryCounts counts events in memory
+type memoryCounts map[string]int
+
+func (m memoryCounts) Increment(ctx context.Context, userID, eventType, category string) (repository.EventCounts, error) {
+	m[userID+"/"+eventType+"/"+category]++
+	total, _ := m.GetCount(ctx, userID, eventType, "")
+	return repository.EventCounts{Category: m[userID+"/"+eventType+"/"+category], Total: total}, nil
+}
+
+func (m memoryCounts) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
+	if category != "" {
+		return m[userID+"/"+eventType+"/"+category], nil
+	}
+	total := 0
+	for key, count := range m {
+		if strings.HasPrefix(key, userID+"/"+eventType+"/") {
+			total += count
+		}
+	}
+	return total, nil
+}
+
+// memoryLedger holds granted rewards in memory
+type memoryLedger map[string]bool
+
+func (m memoryLedger) Record(ctx context.Context, reward models.RewardTriggered) (bool, error) {
+	key := reward.UserID + "/" + reward.RuleID
+	recorded := !m[key]
+	m[key] = true
+	return recorded, nil
+}
+
+func (m memoryLedger) Has(ctx context.Context, userID, ruleID string) (bool, error) {
+	return m[userID+"/"+ruleID], nil
+}
+
+func (m memoryLedger) Get(ctx context.Context, userID, ruleID string) (*models.GrantedReward, error) {
+	if !m[userID+"/"+ruleID] {
+		return nil, nil
+	}
+	return &models.GrantedReward{UserID: userID, RuleID: ruleID}, nil
+}
+
+type recordingOutbox struct {
+	queued []models.RewardTriggered
+}
+
+func (o *recordingOutbox) Add(ctx context.Context, reward models.RewardTriggered) error {
+	o.queued = append(o.queued, reward)
+	return nil
+}
+
+type sliceSource []models.UserEvent
+
+func (s sliceSource) Events(ctx context.Context, fn func(ctx context.Context, event models.UserEvent) error) error {
+	for _, event := range s {
+		if err := fn(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var testRules = []models.Rule{
+	{ID: "two-courses", EventType: "COURSE_COMPLETED", Count: 2, Reward: models.Reward{Type: models.BadgeReward}, Enabled: true},
+}
+
+func history() sliceSource {
+	return sliceSource{
+		{ID: "1", UserID: "user-1", EventType: "COURSE_COMPLETED", Category: "MATH"},
+		{ID: "2", UserID: "user-1", EventType: "COURSE_COMPLETED", Category: "ART"},
+		{ID: "3", UserID: "user-2", EventType: "COURSE_COMPLETED", Category: "MATH"},
+		{ID: "4", UserID: "user-2", EventType: "COURSE_COMPLETED", Category: "MATH"},
+		{ID: "5", UserID: "user-3", EventType: "COURSE_COMPLETED", Category: "MATH"},
+	}
+}
+
+func TestRunCountsOnly(t *testing.T) {
+	counts := memoryCounts{}
+	replayer, err := replay.New(replay.ModeCountsOnly, testRules, counts, nil, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	result, err := replayer.Run(context.Background(), history())
+	require.NoError(t, err)
+
+	assert.Equal(t, replay.Result{Events: 5}, result)
+	assert.Equal(t, 2, counts["user-2/COURSE_COMPLETED/MATH"])
+	assert.Equal(t, 1, counts["user-1/COURSE_COMPLETED/ART"])
+}
+
+func TestRunReportsAndEmitsMissingRewards(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        replay.Mode
+		wantEmitted int
+	}{
+		{name: "no emit", mode: replay.ModeNoEmit},
+		{name: "emit missing", mode: replay.ModeEmitMissing, wantEmitted: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// user-1 already got the reward, user-2 never did
+			ledger := memoryLedger{"user-1/two-courses": true}
+			outbox := &recordingOutbox{}
+			replayer, err := replay.New(tt.mode, testRules, memoryCounts{}, ledger, outbox, zap.NewNop())
+			require.NoError(t, err)
+
+			result, err := replayer.Run(context.Background(), history())
+			require.NoError(t, err)
+
+			assert.Equal(t, replay.Result{Events: 5, Triggered: 2, Missing: 1, Emitted: tt.wantEmitted}, result)
+			assert.Len(t, outbox.queued, tt.wantEmitted)
+			assert.Equal(t, tt.mode == replay.ModeEmitMissing, ledger["user-2/two-courses"])
+		})
+	}
+}
+
+func TestNewRequiresLedgerAndOutbox(t *testing.T) {
+	_, err := replay.New(replay.ModeNoEmit, testRules, memoryCounts{}, nil, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = replay.New(replay.ModeEmitMissing, testRules, memoryCounts{}, memoryLedger{}, nil, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := replay.ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, replay.ModeCountsOnly, mode)
+
+	_, err = replay.ParseMode("emit-all")
+	assert.Error(t, err)
+}
+
+func TestArchiveSourcePages(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer archive-key", r.Header.Get("Authorization"))
+		assert.Equal(t, from.Format(time.RFC3339Nano), r.URL.Query().Get("from"))
+
+		page := map[string]interface{}{
+			"events":      []map[string]string{{"id": "1", "user_id": "user-1", "event_type": "COURSE_COMPLETED"}},
+			"next_cursor": "page-2",
+		}
+		if r.URL.Query().Get("cursor") == "page-2" {
+			page = map[string]interface{}{
+				"events": []map[string]string{{"id": "2", "user_id": "user-2", "event_type": "COURSE_COMPLETED"}},
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	source := &replay.ArchiveSource{BaseURL: server.URL + "/", APIKey: "archive-key", From: from}
+
+	var ids []string
+	err := source.Events(context.Background(), func(ctx context.Context, event models.UserEvent) error {
+		ids = append(ids, event.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+}
+
+func TestArchiveSourceFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	source := &replay.ArchiveSource{BaseURL: server.URL}
+	err := source.Events(context.Background(), func(ctx context.Context, event models.UserEvent) error { return nil })
+	assert.Error(t, err)
+}
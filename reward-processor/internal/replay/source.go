@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/kafka"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+// KafkaSource replays the events retained in the learning events topic
+type KafkaSource struct {
+	Reader  *kafka.Reader
+	Options kafka.ReadOptions
+
+	ends map[int32]int64
+}
+
+// Events implements Source
+func (s *KafkaSource) Events(ctx context.Context, fn func(ctx context.Context, event models.UserEvent) error) error {
+	ends, err := s.Reader.Read(ctx, s.Options, fn)
+	s.ends = ends
+	return err
+}
+
+// EndOffsets returns the offset each partition was replayed up to, once
+// Events returned
+func (s *KafkaSource) EndOffsets() map[int32]int64 {
+	return s.ends
+}
+
+// archivePageSize is the page size requested from the archive, the maximum
+// accepted by event-processor
+const archivePageSize = 1000
+
+// ArchiveSource replays the event audit log of event-processor, read through
+// its GET /events endpoint
+type ArchiveSource struct {
+	// BaseURL is the address of event-processor, e.g. http://event-processor:8081
+	BaseURL string
+	// APIKey must belong to a client allowed to read events
+	APIKey string
+	// From and To bound the event timestamps; From is inclusive, To exclusive
+	From time.Time
+	To   time.Time
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// archivePage mirrors the response of GET /events
+type archivePage struct {
+	Events []struct {
+		ID         string    `json:"id"`
+		UserID     string    `json:"user_id"`
+		EventType  string    `json:"event_type"`
+		Category   string    `json:"category"`
+		CourseID   string    `json:"course_id"`
+		ClientID   string    `json:"client_id"`
+		Timestamp  time.Time `json:"timestamp"`
+		ReceivedAt time.Time `json:"received_at"`
+	} `json:"events"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// Events implements Source. Events come in timestamp order.
+func (s *ArchiveSource) Events(ctx context.Context, fn func(ctx context.Context, event models.UserEvent) error) error {
+	cursor := ""
+	for {
+		page, err := s.fetch(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, e := range page.Events {
+			event := models.UserEvent{
+				ID:        e.ID,
+				UserID:    e.UserID,
+				EventType: e.EventType,
+				Category:  e.Category,
+				CourseID:  e.CourseID,
+				ClientID:  e.ClientID,
+				Timestamp: e.Timestamp,
+				CreatedAt: e.ReceivedAt,
+			}
+			if err := fn(ctx, event); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (s *ArchiveSource) fetch(ctx context.Context, cursor string) (*archivePage, error) {
+	query := url.Values{"limit": {strconv.Itoa(archivePageSize)}}
+	if !s.From.IsZero() {
+		query.Set("from", s.From.Format(time.RFC3339Nano))
+	}
+	if !s.To.IsZero() {
+		query.Set("to", s.To.Format(time.RFC3339Nano))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.BaseURL, "/")+"/events?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch events: unexpected status %s", resp.Status)
+	}
+
+	var page archivePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode events: %w", err)
+	}
+	return &page, nil
+}
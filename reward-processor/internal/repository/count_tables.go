@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// UserEventCountsTable is the table of the live event counts
+	UserEventCountsTable = "user_event_counts"
+	// ShadowUserEventCountsTable is where counts are rebuilt before being
+	// swapped in
+	ShadowUserEventCountsTable = "user_event_counts_replay"
+
+	// userEventCountsKey is the unique key of the counts table, and
+	// shadowUserEventCountsKey that of the shadow table until it is swapped in
+	userEventCountsKey       = "idx_user_event_counts_key"
+	shadowUserEventCountsKey = "idx_user_event_counts_replay_key"
+)
+
+// CreateShadowCounts (re)creates an empty shadow copy of the event counts
+// table and returns a repository writing to it
+func CreateShadowCounts(ctx context.Context, db *gorm.DB) (*GormUserEventRepository, error) {
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP TABLE IF EXISTS " + ShadowUserEventCountsTable).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)", ShadowUserEventCountsTable, UserEventCountsTable)).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (user_id, event_type, category)", shadowUserEventCountsKey, ShadowUserEventCountsTable)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shadow counts table: %w", err)
+	}
+	return &GormUserEventRepository{db: db, table: ShadowUserEventCountsTable}, nil
+}
+
+// SwapShadowCounts atomically replaces the live event counts with the shadow
+// ones. Readers see either the old or the new counts, never a mix. It joins
+// the transaction carried by ctx, if any.
+func SwapShadowCounts(ctx context.Context, db *gorm.DB) error {
+	old := UserEventCountsTable + "_old"
+	return conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range []string{
+			"LOCK TABLE " + UserEventCountsTable + " IN ACCESS EXCLUSIVE MODE",
+			"DROP TABLE IF EXISTS " + old,
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", UserEventCountsTable, old),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", ShadowUserEventCountsTable, UserEventCountsTable),
+			"DROP TABLE " + old,
+			// Keep the key name AutoMigrate expects
+			fmt.Sprintf("ALTER INDEX %s RENAME TO %s", shadowUserEventCountsKey, userEventCountsKey),
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to swap counts tables: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DropShadowCounts removes the shadow counts table
+func DropShadowCounts(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Exec("DROP TABLE IF EXISTS " + ShadowUserEventCountsTable).Error
+}
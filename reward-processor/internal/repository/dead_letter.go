@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeadLetterRepository stores the consumed events the processor gave up on
+type DeadLetterRepository interface {
+	// Add stores a dead letter. A message already stored is left as is.
+	Add(ctx context.Context, letter models.DeadLetterEvent) error
+}
+
+// Ensure GormDeadLetterRepository implements DeadLetterRepository
+var _ DeadLetterRepository = (*GormDeadLetterRepository)(nil)
+
+// GormDeadLetterRepository implements DeadLetterRepository using GORM
+type GormDeadLetterRepository struct {
+	db *gorm.DB
+}
+
+// NewGormDeadLetterRepository creates a new GORM-based dead letter repository
+func NewGormDeadLetterRepository(db *gorm.DB) *GormDeadLetterRepository {
+	return &GormDeadLetterRepository{db: db}
+}
+
+// Add implements DeadLetterRepository
+func (r *GormDeadLetterRepository) Add(ctx context.Context, letter models.DeadLetterEvent) error {
+	return conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&letter).Error
+}
+
+// IsTransient reports whether err comes from the database being unavailable,
+// overloaded or in conflict with a concurrent transaction, so that the same
+// statement may succeed later
+func IsTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch errorClass(pgErr) {
+		case "08", "40", "53", "57", "58":
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err) || errors.As(err, &netErr)
+}
+
+// IsPermanent reports whether err is a database error caused by the data
+// written, such as a constraint violation, which the same statement repeats
+func IsPermanent(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch errorClass(pgErr) {
+	case "22", "23", "42":
+		return true
+	}
+	return false
+}
+
+// errorClass returns the class of a SQLSTATE code, its first two characters
+func errorClass(pgErr *pgconn.PgError) string {
+	if len(pgErr.Code) < 2 {
+		return ""
+	}
+	return pgErr.Code[:2]
+}
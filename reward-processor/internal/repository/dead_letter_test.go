@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClasses(t *testing.T) {
+	uniqueViolation := fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"})
+	serializationFailure := &pgconn.PgError{Code: "40001"}
+	adminShutdown := &pgconn.PgError{Code: "57P01"}
+
+	assert.True(t, IsPermanent(uniqueViolation))
+	assert.False(t, IsTransient(uniqueViolation))
+	assert.True(t, IsTransient(serializationFailure))
+	assert.False(t, IsPermanent(serializationFailure))
+	assert.True(t, IsTransient(adminShutdown))
+	assert.False(t, IsTransient(errors.New("unexpected")))
+	assert.False(t, IsPermanent(errors.New("unexpected")))
+}
+
+func TestAddDeadLetterKeepsFirst(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.DeadLetterEvent{}))
+	repo := NewGormDeadLetterRepository(db)
+	ctx := context.Background()
+
+	letter := models.DeadLetterEvent{GroupID: "g", Topic: "learning-events", Partition: 1, Offset: 7, UserID: "user-001", Payload: "{}", Error: "first", Attempts: 1}
+	require.NoError(t, repo.Add(ctx, letter))
+	letter.Error = "second"
+	require.NoError(t, repo.Add(ctx, letter))
+
+	var stored []models.DeadLetterEvent
+	require.NoError(t, db.Find(&stored).Error)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "first", stored[0].Error)
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OffsetRepository stores consumer offsets next to the data they cover, so
+// that both are committed atomically
+type OffsetRepository interface {
+	// MarkProcessed records a message as processed. It returns false if the
+	// message was already processed.
+	MarkProcessed(ctx context.Context, groupID, topic string, partition int32, offset int64) (bool, error)
+	// Save moves the watermark of a partition forward to nextOffset and
+	// forgets the processed messages before it. A lower offset is ignored.
+	Save(ctx context.Context, groupID, topic string, partition int32, nextOffset int64) error
+	// Offsets returns the watermark of each partition of a topic that has one
+	Offsets(ctx context.Context, groupID, topic string) (map[int32]int64, error)
+	// Processed returns the processed messages after the watermark of each
+	// partition of a topic
+	Processed(ctx context.Context, groupID, topic string) (map[int32][]int64, error)
+	// ClearProcessed forgets the processed messages of a topic, so that the
+	// messages after the watermarks are processed again
+	ClearProcessed(ctx context.Context, groupID, topic string) error
+}
+
+// Ensure GormOffsetRepository implements OffsetRepository
+var _ OffsetRepository = (*GormOffsetRepository)(nil)
+
+// GormOffsetRepository implements OffsetRepository using GORM
+type GormOffsetRepository struct {
+	db *gorm.DB
+}
+
+// NewGormOffsetRepository creates a new GORM-based offset repository
+func NewGormOffsetRepository(db *gorm.DB) *GormOffsetRepository {
+	return &GormOffsetRepository{db: db}
+}
+
+// MarkProcessed implements OffsetRepository
+func (r *GormOffsetRepository) MarkProcessed(ctx context.Context, groupID, topic string, partition int32, offset int64) (bool, error) {
+	result := conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ProcessedMessage{
+			GroupID:     groupID,
+			Topic:       topic,
+			Partition:   partition,
+			Offset:      offset,
+			ProcessedAt: time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Save implements OffsetRepository
+func (r *GormOffsetRepository) Save(ctx context.Context, groupID, topic string, partition int32, nextOffset int64) error {
+	db := conn(ctx, r.db)
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "group_id"}, {Name: "topic"}, {Name: "partition"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"next_offset": gorm.Expr("GREATEST(consumer_offsets.next_offset, EXCLUDED.next_offset)"),
+			"updated_at":  gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(&models.ConsumerOffset{
+		GroupID:    groupID,
+		Topic:      topic,
+		Partition:  partition,
+		NextOffset: nextOffset,
+		UpdatedAt:  time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("group_id = ? AND topic = ? AND partition = ? AND \"offset\" < ?", groupID, topic, partition, nextOffset).
+		Delete(&models.ProcessedMessage{}).Error
+}
+
+// Offsets implements OffsetRepository
+func (r *GormOffsetRepository) Offsets(ctx context.Context, groupID, topic string) (map[int32]int64, error) {
+	var rows []models.ConsumerOffset
+	if err := conn(ctx, r.db).Where("group_id = ? AND topic = ?", groupID, topic).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	offsets := make(map[int32]int64, len(rows))
+	for _, row := range rows {
+		offsets[row.Partition] = row.NextOffset
+	}
+	return offsets, nil
+}
+
+// Processed implements OffsetRepository. Rows before the watermark are
+// deleted as it moves, so every remaining row is after it.
+func (r *GormOffsetRepository) Processed(ctx context.Context, groupID, topic string) (map[int32][]int64, error) {
+	var rows []models.ProcessedMessage
+	if err := conn(ctx, r.db).Where("group_id = ? AND topic = ?", groupID, topic).
+		Order("partition, \"offset\"").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	processed := make(map[int32][]int64)
+	for _, row := range rows {
+		processed[row.Partition] = append(processed[row.Partition], row.Offset)
+	}
+	return processed, nil
+}
+
+// ClearProcessed implements OffsetRepository
+func (r *GormOffsetRepository) ClearProcessed(ctx context.Context, groupID, topic string) error {
+	return conn(ctx, r.db).Where("group_id = ? AND topic = ?", groupID, topic).
+		Delete(&models.ProcessedMessage{}).Error
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxRelayLockKey is the advisory lock held while a batch is relayed, so
+// that rewards are published in order by a single relay at a time
+const outboxRelayLockKey = 0x6f7574626f78 // "outbox"
+
+// OutboxRepository stores rewards waiting to be published
+type OutboxRepository interface {
+	// Add queues a reward for publishing
+	Add(ctx context.Context, reward models.RewardTriggered) error
+	// ProcessPending passes up to limit unsent rewards, oldest first, to
+	// send and marks them sent. It stops at the first reward send fails on,
+	// records the failure and returns its error. It returns no reward while
+	// another caller is processing a batch.
+	ProcessPending(ctx context.Context, limit int, send func(models.OutboxReward) error) (int, error)
+	// DeleteSentBefore deletes rewards sent before t
+	DeleteSentBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// Ensure GormOutboxRepository implements OutboxRepository
+var _ OutboxRepository = (*GormOutboxRepository)(nil)
+
+// GormOutboxRepository implements OutboxRepository using GORM
+type GormOutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewGormOutboxRepository creates a new GORM-based outbox repository
+func NewGormOutboxRepository(db *gorm.DB) *GormOutboxRepository {
+	return &GormOutboxRepository{db: db}
+}
+
+// Add implements OutboxRepository
+func (r *GormOutboxRepository) Add(ctx context.Context, reward models.RewardTriggered) error {
+	triggeredAt := reward.Timestamp
+	if triggeredAt.IsZero() {
+		triggeredAt = time.Now()
+	}
+	return conn(ctx, r.db).Create(&models.OutboxReward{
+		UserID:       reward.UserID,
+		RuleID:       reward.RuleID,
+		RuleVersion:  reward.RuleVersion,
+		Reward:       reward.Reward,
+		TriggeredAt:  triggeredAt,
+		TraceContext: tracing.Serialize(ctx),
+	}).Error
+}
+
+// ProcessPending implements OutboxRepository
+func (r *GormOutboxRepository) ProcessPending(ctx context.Context, limit int, send func(models.OutboxReward) error) (int, error) {
+	sent := 0
+	var sendErr error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxRelayLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var pending []models.OutboxReward
+		if err := tx.Where("sent_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var sentIDs []uint64
+		for _, reward := range pending {
+			if sendErr = send(reward); sendErr != nil {
+				if err := tx.Model(&models.OutboxReward{}).
+					Where("id = ?", reward.ID).
+					Updates(map[string]interface{}{
+						"attempts":   gorm.Expr("attempts + 1"),
+						"last_error": sendErr.Error(),
+					}).Error; err != nil {
+					return err
+				}
+				break
+			}
+			sentIDs = append(sentIDs, reward.ID)
+		}
+
+		if len(sentIDs) > 0 {
+			if err := tx.Model(&models.OutboxReward{}).
+				Where("id IN ?", sentIDs).
+				Updates(map[string]interface{}{
+					"sent_at":  now,
+					"attempts": gorm.Expr("attempts + 1"),
+				}).Error; err != nil {
+				return err
+			}
+		}
+		sent = len(sentIDs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, sendErr
+}
+
+// DeleteSentBefore implements OutboxRepository
+func (r *GormOutboxRepository) DeleteSentBefore(ctx context.Context, t time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("sent_at < ?", t).Delete(&models.OutboxReward{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RewardLedgerRepository records the rewards sent to users
+type RewardLedgerRepository interface {
+	// Record stores a granted reward. It returns false, and changes nothing,
+	// if the reward was already granted.
+	Record(ctx context.Context, reward models.RewardTriggered) (bool, error)
+	// Has reports whether a rule's reward was already sent to a user
+	Has(ctx context.Context, userID, ruleID string) (bool, error)
+	// Get returns the reward a rule granted to a user, or nil if it did not
+	Get(ctx context.Context, userID, ruleID string) (*models.GrantedReward, error)
+}
+
+// Ensure GormRewardLedgerRepository implements RewardLedgerRepository
+var _ RewardLedgerRepository = (*GormRewardLedgerRepository)(nil)
+
+// GormRewardLedgerRepository implements RewardLedgerRepository using GORM
+type GormRewardLedgerRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRewardLedgerRepository creates a new GORM-based reward ledger
+func NewGormRewardLedgerRepository(db *gorm.DB) *GormRewardLedgerRepository {
+	return &GormRewardLedgerRepository{db: db}
+}
+
+// Record implements RewardLedgerRepository
+func (r *GormRewardLedgerRepository) Record(ctx context.Context, reward models.RewardTriggered) (bool, error) {
+	grantedAt := reward.Timestamp
+	if grantedAt.IsZero() {
+		grantedAt = time.Now()
+	}
+	result := conn(ctx, r.db).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.GrantedReward{
+			UserID:      reward.UserID,
+			RuleID:      reward.RuleID,
+			RuleVersion: reward.RuleVersion,
+			GrantedAt:   grantedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Has implements RewardLedgerRepository
+func (r *GormRewardLedgerRepository) Has(ctx context.Context, userID, ruleID string) (bool, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&models.GrantedReward{}).
+		Where("user_id = ? AND rule_id = ?", userID, ruleID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Get implements RewardLedgerRepository
+func (r *GormRewardLedgerRepository) Get(ctx context.Context, userID, ruleID string) (*models.GrantedReward, error) {
+	var granted models.GrantedReward
+	err := conn(ctx, r.db).First(&granted, "user_id = ? AND rule_id = ?", userID, ruleID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &granted, nil
+}
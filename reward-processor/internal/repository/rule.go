@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"github.com/google/uuid"
@@ -10,14 +12,36 @@ import (
 
 // RuleRepository defines the interface for rule operations
 type RuleRepository interface {
-	// GetEnabledRules returns all enabled rules
-	GetEnabledRules(ctx context.Context) ([]models.Rule, error)
+	// GetActiveRules returns the rules the engine evaluates, the ACTIVE ones
+	GetActiveRules(ctx context.Context) ([]models.Rule, error)
 	// GetRuleByID returns a rule by its ID
 	GetRuleByID(ctx context.Context, id string) (*models.Rule, error)
 	// CreateRule creates a new rule
 	CreateRule(ctx context.Context, rule *models.Rule) error
-	// UpdateRule updates an existing rule
-	UpdateRule(ctx context.Context, id string, rule *models.Rule) error
+	// UpdateRule writes the fields set in update to an existing rule. A rule
+	// pending review or approved goes back to draft; an active rule is
+	// changed only if allowActive is set.
+	UpdateRule(ctx context.Context, id string, allowActive bool, update RuleUpdate) error
+	// TransitionRule moves a rule through its review lifecycle with a
+	// submit, approve, reject or publish change, and returns it
+	TransitionRule(ctx context.Context, id string, change models.RuleChange, comment string) (*models.Rule, error)
+	// ListRules returns a page of the rules matching filter, in the order of
+	// sort, after the rule of cursor after if not empty
+	ListRules(ctx context.Context, filter RuleFilter, sort RuleSort, first int, after string) (RulePage, error)
+	// DeleteRule deletes a rule
+	DeleteRule(ctx context.Context, id string) error
+	// ArchiveRule disables a rule and marks it archived, and returns it
+	ArchiveRule(ctx context.Context, id string) (*models.Rule, error)
+	// GetRuleHistory returns the versions of a rule, newest first
+	GetRuleHistory(ctx context.Context, id string) ([]models.RuleVersion, error)
+	// RevertRule restores the definition of a rule at version as a new
+	// version, and returns the rule
+	RevertRule(ctx context.Context, id string, version int) (*models.Rule, error)
+	// GetUnarchivedRules returns the rules that are not archived, by ID
+	GetUnarchivedRules(ctx context.Context) ([]models.Rule, error)
+	// SyncRules makes the rules of the database match rules, or with dryRun
+	// only returns the changes that would
+	SyncRules(ctx context.Context, rules []models.Rule, dryRun bool, comment string) ([]RuleSyncChange, error)
 }
 
 // Ensure GormRuleRepository implements RuleRepository
@@ -33,15 +57,23 @@ func NewGormRuleRepository(db *gorm.DB) *GormRuleRepository {
 	return &GormRuleRepository{db: db}
 }
 
-// GetEnabledRules implements RuleRepository
-func (r *GormRuleRepository) GetEnabledRules(ctx context.Context) ([]models.Rule, error) {
+// GetActiveRules implements RuleRepository
+func (r *GormRuleRepository) GetActiveRules(ctx context.Context) ([]models.Rule, error) {
 	var rules []models.Rule
 	err := r.db.WithContext(ctx).
-		Where("enabled = ?", true).
+		Where("status = ?", models.RuleActive).
 		Find(&rules).Error
 	return rules, err
 }
 
+// CountRules returns the number of rules, and of enabled (ACTIVE) rules
+func (r *GormRuleRepository) CountRules(ctx context.Context) (total, enabled int64, err error) {
+	err = r.db.WithContext(ctx).Model(&models.Rule{}).
+		Select("COUNT(*), COUNT(*) FILTER (WHERE enabled)").
+		Row().Scan(&total, &enabled)
+	return total, enabled, err
+}
+
 // GetRuleByID implements RuleRepository
 func (r *GormRuleRepository) GetRuleByID(ctx context.Context, id string) (*models.Rule, error) {
 	var rule models.Rule
@@ -55,20 +87,178 @@ func (r *GormRuleRepository) GetRuleByID(ctx context.Context, id string) (*model
 	return &rule, nil
 }
 
-// CreateRule implements RuleRepository
+// CreateRule implements RuleRepository. Rules without an ID get a new one,
+// and rules without a status are created as drafts. A rule re-created with the
+// ID of a deleted one continues its history.
 func (r *GormRuleRepository) CreateRule(ctx context.Context, rule *models.Rule) error {
-	rule.ID = uuid.New().String()
-	return r.db.WithContext(ctx).Create(rule).Error
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	if rule.Status == "" {
+		rule.Status = models.RuleDraft
+	}
+	rule.Enabled = rule.Status == models.RuleActive
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		version, err := nextVersion(tx, rule.ID)
+		if err != nil {
+			return err
+		}
+		rule.Version = version
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return recordVersion(ctx, tx, *rule, models.RuleCreated, diffRules(nil, *rule), "")
+	})
 }
 
-// UpdateRule implements RuleRepository
-func (r *GormRuleRepository) UpdateRule(ctx context.Context, id string, rule *models.Rule) error {
-	result := r.db.WithContext(ctx).Model(&models.Rule{}).Where("id = ?", id).Updates(rule)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+// UpdateRule implements RuleRepository. The status is checked on the locked
+// rule, so that a rule published meanwhile is not changed unless allowed. The
+// columns are written from a map so that zero values and nulls are written
+// too. An update changing nothing creates no version.
+func (r *GormRuleRepository) UpdateRule(ctx context.Context, id string, allowActive bool, update RuleUpdate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rule, err := lockRule(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkUpdate(rule, allowActive); err != nil {
+			return err
+		}
+		before := rule
+		update.apply(&rule)
+		reviewAgain(before, &rule)
+		return saveVersion(ctx, tx, before, &rule, models.RuleUpdated, "")
+	})
+}
+
+// checkUpdate returns why rule cannot be updated, if it cannot
+func checkUpdate(rule models.Rule, allowActive bool) error {
+	switch {
+	case rule.Status == models.RuleRetired:
+		return ErrRuleArchived
+	case rule.Status == models.RuleActive && !allowActive:
+		return ErrRuleActive
 	}
 	return nil
 }
+
+// Optional is a value that may be left unset, to tell a field that is not
+// updated from a field updated to its zero value
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+// Some returns an Optional set to v
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Set: true}
+}
+
+// RuleUpdate is a partial update of the definition of a rule. Only the
+// fields that are set are written, so Some[*string](nil) clears the category
+// condition. Counts are not validated here; the API rejects counts below 1
+// and sets a null count to 1.
+type RuleUpdate struct {
+	EventType          Optional[string]
+	Count              Optional[int]
+	ConditionsCategory Optional[*string]
+	Reward             Optional[models.Reward]
+}
+
+// apply sets the fields of the update on rule
+func (u RuleUpdate) apply(rule *models.Rule) {
+	if u.EventType.Set {
+		rule.EventType = u.EventType.Value
+	}
+	if u.Count.Set {
+		rule.Count = u.Count.Value
+	}
+	if u.ConditionsCategory.Set {
+		rule.ConditionsCategory = u.ConditionsCategory.Value
+	}
+	if u.Reward.Set {
+		rule.Reward = u.Reward.Value
+	}
+}
+
+// ListRules implements RuleRepository
+func (r *GormRuleRepository) ListRules(ctx context.Context, filter RuleFilter, sort RuleSort, first int, after string) (RulePage, error) {
+	query := filter.apply(r.db.WithContext(ctx).Model(&models.Rule{}))
+
+	var page RulePage
+	if err := query.Session(&gorm.Session{}).Count(&page.TotalCount).Error; err != nil {
+		return RulePage{}, err
+	}
+
+	column, err := sort.column()
+	if err != nil {
+		return RulePage{}, err
+	}
+	op, direction := ">", "ASC"
+	if sort.Desc {
+		op, direction = "<", "DESC"
+	}
+	if after != "" {
+		cursor, err := decodeRuleCursor(after, sort)
+		if err != nil {
+			return RulePage{}, err
+		}
+		// Row comparison keeps the order stable between rules sharing a
+		// sort value
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, op), cursor.value, cursor.ID)
+	}
+
+	// One more rule than asked tells whether there is a next page
+	var rules []models.Rule
+	if err := query.
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(first + 1).
+		Find(&rules).Error; err != nil {
+		return RulePage{}, err
+	}
+	if len(rules) > first {
+		rules = rules[:first]
+		page.HasNextPage = true
+	}
+	page.Rules = rules
+	return page, nil
+}
+
+// DeleteRule implements RuleRepository. The history of the rule is kept,
+// ending with a version recording the deletion.
+func (r *GormRuleRepository) DeleteRule(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rule, err := lockRule(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return err
+		}
+		rule.Version++
+		return recordVersion(ctx, tx, rule, models.RuleDeleted, nil, "")
+	})
+}
+
+// ArchiveRule implements RuleRepository. Archived rules are RETIRED, from
+// any status. Archiving an archived rule changes nothing.
+func (r *GormRuleRepository) ArchiveRule(ctx context.Context, id string) (*models.Rule, error) {
+	var rule models.Rule
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rule, err = lockRule(tx, id); err != nil {
+			return err
+		}
+		before := rule
+		now := time.Now()
+		if rule.ArchivedAt == nil {
+			rule.ArchivedAt = &now
+		}
+		rule.Status = models.RuleRetired
+		return saveVersion(ctx, tx, before, &rule, models.RuleArchived, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
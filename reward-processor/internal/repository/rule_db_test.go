@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestDB returns an in-memory database with the rule tables
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Rule{}, &models.RuleVersion{}))
+	return db
+}
+
+func TestRecreateDeletedRule(t *testing.T) {
+	ctx := context.Background()
+	repo := NewGormRuleRepository(newTestDB(t))
+
+	rule := models.Rule{
+		ID:        "rule-001",
+		EventType: "COURSE_COMPLETED",
+		Count:     1,
+		Reward:    models.Reward{Type: models.BadgeReward, Description: "Badge"},
+	}
+	created := rule
+	require.NoError(t, repo.CreateRule(ctx, &created))
+	require.NoError(t, repo.DeleteRule(ctx, "rule-001"))
+
+	recreated := rule
+	require.NoError(t, repo.CreateRule(ctx, &recreated))
+	assert.Equal(t, 3, recreated.Version)
+
+	require.NoError(t, repo.DeleteRule(ctx, "rule-001"))
+	changes, err := repo.SyncRules(ctx, []models.Rule{rule}, false, "")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, RuleSyncCreate, changes[0].Action)
+
+	history, err := repo.GetRuleHistory(ctx, "rule-001")
+	require.NoError(t, err)
+	versions := make([]int, len(history))
+	changed := make([]models.RuleChange, len(history))
+	for i, v := range history {
+		versions[i], changed[i] = v.Version, v.Change
+	}
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, versions)
+	assert.Equal(t, []models.RuleChange{models.RuleCreated, models.RuleDeleted, models.RuleCreated, models.RuleDeleted, models.RuleCreated}, changed)
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the
+// requested sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// RuleFilter selects rules. Nil fields match every rule.
+type RuleFilter struct {
+	EventType  *string
+	Enabled    *bool
+	Status     *models.RuleStatus
+	RewardType *models.RewardType
+	Category   *string
+	Archived   *bool
+}
+
+func (f RuleFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.EventType != nil {
+		query = query.Where("event_type = ?", *f.EventType)
+	}
+	if f.Enabled != nil {
+		query = query.Where("enabled = ?", *f.Enabled)
+	}
+	if f.Status != nil {
+		query = query.Where("status = ?", *f.Status)
+	}
+	if f.RewardType != nil {
+		query = query.Where("type = ?", *f.RewardType)
+	}
+	if f.Category != nil {
+		query = query.Where("conditions_category = ?", *f.Category)
+	}
+	if f.Archived != nil {
+		if *f.Archived {
+			query = query.Where("archived_at IS NOT NULL")
+		} else {
+			query = query.Where("archived_at IS NULL")
+		}
+	}
+	return query
+}
+
+// RuleSortField is a field rules can be sorted by
+type RuleSortField string
+
+const (
+	RuleSortCreatedAt RuleSortField = "created_at"
+	RuleSortEventType RuleSortField = "event_type"
+	RuleSortCount     RuleSortField = "count"
+)
+
+// RuleSort orders rules by a field, then by ID
+type RuleSort struct {
+	Field RuleSortField
+	Desc  bool
+}
+
+func (s RuleSort) column() (string, error) {
+	switch s.Field {
+	case RuleSortCreatedAt, RuleSortEventType, RuleSortCount:
+		return string(s.Field), nil
+	case "":
+		return string(RuleSortCreatedAt), nil
+	default:
+		return "", fmt.Errorf("unknown rule sort field %q", s.Field)
+	}
+}
+
+// RulePage is a page of rules
+type RulePage struct {
+	Rules       []models.Rule
+	HasNextPage bool
+	// TotalCount is the number of rules matching the filter in every page
+	TotalCount int64
+}
+
+// ruleCursor is the position of a rule in a sort order
+type ruleCursor struct {
+	Field RuleSortField   `json:"f"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+	value any
+}
+
+// RuleCursor returns the cursor of rule in the order of sort, to pass to
+// ListRules to list the rules after it
+func RuleCursor(rule models.Rule, sort RuleSort) string {
+	field := sort.Field
+	if field == "" {
+		field = RuleSortCreatedAt
+	}
+	var value any
+	switch field {
+	case RuleSortEventType:
+		value = rule.EventType
+	case RuleSortCount:
+		value = rule.Count
+	default:
+		value = rule.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(ruleCursor{Field: field, Value: raw, ID: rule.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeRuleCursor(cursor string, sort RuleSort) (ruleCursor, error) {
+	field := sort.Field
+	if field == "" {
+		field = RuleSortCreatedAt
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ruleCursor{}, ErrInvalidCursor
+	}
+	var c ruleCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Field != field || c.ID == "" {
+		return ruleCursor{}, ErrInvalidCursor
+	}
+
+	switch field {
+	case RuleSortEventType:
+		var v string
+		err = json.Unmarshal(c.Value, &v)
+		c.value = v
+	case RuleSortCount:
+		var v int
+		err = json.Unmarshal(c.Value, &v)
+		c.value = v
+	default:
+		var v time.Time
+		err = json.Unmarshal(c.Value, &v)
+		c.value = v
+	}
+	if err != nil {
+		return ruleCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
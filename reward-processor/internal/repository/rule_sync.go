@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RuleSyncAction is what syncing does to a rule
+type RuleSyncAction string
+
+const (
+	// RuleSyncCreate creates a rule of the file missing from the database
+	RuleSyncCreate RuleSyncAction = "CREATE"
+	// RuleSyncUpdate changes a rule to match the file
+	RuleSyncUpdate RuleSyncAction = "UPDATE"
+	// RuleSyncDisable archives a rule missing from the file
+	RuleSyncDisable RuleSyncAction = "DISABLE"
+)
+
+// RuleSyncChange is a change made, or to be made, by syncing the rules
+type RuleSyncChange struct {
+	RuleID string
+	Action RuleSyncAction
+	Diff   []models.FieldChange
+}
+
+// GetUnarchivedRules returns the rules that are not archived, by ID
+func (r *GormRuleRepository) GetUnarchivedRules(ctx context.Context) ([]models.Rule, error) {
+	var rules []models.Rule
+	err := r.db.WithContext(ctx).
+		Where("archived_at IS NULL").
+		Order("id").
+		Find(&rules).Error
+	return rules, err
+}
+
+// SyncRules makes the rules of the database match rules, the full set of
+// rules wanted, and returns the changes. Rules are matched by ID:
+//   - rules missing from the database are created
+//   - rules that differ are updated, and restored if they were archived
+//   - rules missing from rules are archived
+//
+// Syncing does not skip review: rules created, changed or restored are
+// DRAFT, and go through the review mutations like any other rule. Enabled
+// only publishes an unchanged APPROVED rule, and a disabled rule that was
+// ACTIVE goes back to DRAFT. Syncing is idempotent: syncing the same rules
+// again changes nothing. With dryRun, the changes are returned but not made.
+func (r *GormRuleRepository) SyncRules(ctx context.Context, rules []models.Rule, dryRun bool, comment string) ([]RuleSyncChange, error) {
+	var changes []RuleSyncChange
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current []models.Rule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&current).Error; err != nil {
+			return err
+		}
+		byID := make(map[string]models.Rule, len(current))
+		for _, rule := range current {
+			byID[rule.ID] = rule
+		}
+
+		wanted := make(map[string]bool, len(rules))
+		for _, rule := range rules {
+			wanted[rule.ID] = true
+			before, exists := byID[rule.ID]
+			if !exists {
+				change, err := syncCreate(ctx, tx, rule, dryRun, comment)
+				if err != nil {
+					return err
+				}
+				changes = append(changes, change)
+				continue
+			}
+
+			after := syncedRule(before, rule)
+			diff := diffRules(&before, after)
+			if len(diff) == 0 {
+				continue
+			}
+			changes = append(changes, RuleSyncChange{RuleID: rule.ID, Action: RuleSyncUpdate, Diff: diff})
+			if !dryRun {
+				change := models.RuleUpdated
+				if before.Status == models.RuleApproved && after.Status == models.RuleActive {
+					change = models.RulePublished
+				}
+				if err := saveVersion(ctx, tx, before, &after, change, comment); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Archived last, in ID order, so that changes are listed stably
+		sort.Slice(current, func(i, j int) bool { return current[i].ID < current[j].ID })
+		now := time.Now()
+		for _, before := range current {
+			if wanted[before.ID] || before.ArchivedAt != nil {
+				continue
+			}
+			after := before
+			after.ArchivedAt = &now
+			after.Status = models.RuleRetired
+			after.Enabled = false
+			changes = append(changes, RuleSyncChange{RuleID: before.ID, Action: RuleSyncDisable, Diff: diffRules(&before, after)})
+			if !dryRun {
+				if err := saveVersion(ctx, tx, before, &after, models.RuleArchived, comment); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// syncCreate creates rule with its own ID, as a DRAFT. A rule re-created
+// after being deleted continues its history.
+func syncCreate(ctx context.Context, tx *gorm.DB, rule models.Rule, dryRun bool, comment string) (RuleSyncChange, error) {
+	version, err := nextVersion(tx, rule.ID)
+	if err != nil {
+		return RuleSyncChange{}, err
+	}
+	rule.Version = version
+	rule.Status = models.RuleDraft
+	rule.Enabled = false
+	diff := diffRules(nil, rule)
+	if !dryRun {
+		if err := tx.Create(&rule).Error; err != nil {
+			return RuleSyncChange{}, err
+		}
+		if err := recordVersion(ctx, tx, rule, models.RuleCreated, diff, comment); err != nil {
+			return RuleSyncChange{}, err
+		}
+	}
+	return RuleSyncChange{RuleID: rule.ID, Action: RuleSyncCreate, Diff: diff}, nil
+}
+
+// syncedRule returns before with the definition of want. A changed or
+// restored rule is a DRAFT; otherwise want.Enabled publishes an APPROVED rule
+// and disabling an ACTIVE rule makes it a DRAFT.
+func syncedRule(before, want models.Rule) models.Rule {
+	after := before
+	after.EventType = want.EventType
+	after.Count = want.Count
+	after.ConditionsCategory = want.ConditionsCategory
+	after.Reward = want.Reward
+	changed := len(diffRules(&before, after)) > 0
+	after.ArchivedAt = nil
+
+	switch {
+	case changed, before.Status == models.RuleRetired:
+		after.Status = models.RuleDraft
+	case want.Enabled && before.Status == models.RuleApproved:
+		after.Status = models.RuleActive
+	case !want.Enabled && before.Status == models.RuleActive:
+		after.Status = models.RuleDraft
+	}
+	after.Enabled = after.Status == models.RuleActive
+	return after
+}
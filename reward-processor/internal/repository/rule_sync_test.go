@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncedRule(t *testing.T) {
+	archivedAt := time.Date(2025, 7, 1, 8, 30, 0, 0, time.UTC)
+	definition := models.Rule{
+		ID:        "rule-001",
+		EventType: "COURSE_COMPLETED",
+		Count:     5,
+		Reward:    models.Reward{Type: models.PointsReward, Amount: 100, Description: "Points"},
+	}
+
+	tests := []struct {
+		name       string
+		status     models.RuleStatus
+		archivedAt *time.Time
+		count      int
+		enabled    bool
+		expected   models.RuleStatus
+	}{
+		{name: "draft enabled", status: models.RuleDraft, count: 5, enabled: true, expected: models.RuleDraft},
+		{name: "pending review enabled", status: models.RulePendingReview, count: 5, enabled: true, expected: models.RulePendingReview},
+		{name: "approved published", status: models.RuleApproved, count: 5, enabled: true, expected: models.RuleActive},
+		{name: "approved changed", status: models.RuleApproved, count: 10, enabled: true, expected: models.RuleDraft},
+		{name: "approved disabled", status: models.RuleApproved, count: 5, expected: models.RuleApproved},
+		{name: "active disabled", status: models.RuleActive, count: 5, expected: models.RuleDraft},
+		{name: "active kept", status: models.RuleActive, count: 5, enabled: true, expected: models.RuleActive},
+		{name: "active changed", status: models.RuleActive, count: 10, enabled: true, expected: models.RuleDraft},
+		{name: "pending review unchanged", status: models.RulePendingReview, count: 5, expected: models.RulePendingReview},
+		{name: "pending review changed", status: models.RulePendingReview, count: 10, expected: models.RuleDraft},
+		{name: "retired restored", status: models.RuleRetired, archivedAt: &archivedAt, count: 5, enabled: true, expected: models.RuleDraft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := definition
+			before.Status = tt.status
+			before.Enabled = tt.status == models.RuleActive
+			before.ArchivedAt = tt.archivedAt
+
+			want := definition
+			want.Count = tt.count
+			want.Enabled = tt.enabled
+
+			after := syncedRule(before, want)
+			assert.Equal(t, tt.expected, after.Status)
+			assert.Equal(t, tt.expected == models.RuleActive, after.Enabled)
+			assert.Nil(t, after.ArchivedAt)
+			assert.Equal(t, tt.count, after.Count)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleColumns(t *testing.T) {
+	rule := models.Rule{
+		ID:        "rule-001",
+		EventType: "COURSE_COMPLETED",
+		Count:     1,
+		Reward:    models.Reward{Type: models.BadgeReward, Description: "Badge"},
+		Status:    models.RuleDraft,
+		Version:   2,
+	}
+
+	assert.Equal(t, map[string]any{
+		"event_type":          "COURSE_COMPLETED",
+		"count":               1,
+		"conditions_category": (*string)(nil),
+		"type":                models.BadgeReward,
+		"amount":              0,
+		"description":         "Badge",
+		"status":              models.RuleDraft,
+		"enabled":             false,
+		"archived_at":         (*time.Time)(nil),
+		"version":             2,
+	}, ruleColumns(rule))
+}
+
+func TestDiffRules(t *testing.T) {
+	str := func(s string) *string { return &s }
+	rule := models.Rule{
+		ID:                 "rule-001",
+		EventType:          "COURSE_COMPLETED",
+		Count:              5,
+		ConditionsCategory: str("MATH"),
+		Reward:             models.Reward{Type: models.PointsReward, Amount: 100, Description: "Math"},
+		Status:             models.RuleActive,
+		Enabled:            true,
+	}
+	archivedAt := time.Date(2025, 7, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from     *models.Rule
+		change   func(*models.Rule)
+		expected []models.FieldChange
+	}{
+		{
+			name:   "created",
+			change: func(*models.Rule) {},
+			expected: []models.FieldChange{
+				{Field: "eventType", To: str("COURSE_COMPLETED")},
+				{Field: "count", To: str("5")},
+				{Field: "conditions.category", To: str("MATH")},
+				{Field: "reward.type", To: str("POINTS")},
+				{Field: "reward.amount", To: str("100")},
+				{Field: "reward.description", To: str("Math")},
+				{Field: "status", To: str("ACTIVE")},
+				{Field: "enabled", To: str("true")},
+			},
+		},
+		{
+			name:     "unchanged",
+			from:     &rule,
+			change:   func(*models.Rule) {},
+			expected: nil,
+		},
+		{
+			name: "amount zeroed and rule sent back to draft",
+			from: &rule,
+			change: func(r *models.Rule) {
+				r.Reward.Amount = 0
+				r.Status = models.RuleDraft
+				r.Enabled = false
+			},
+			expected: []models.FieldChange{
+				{Field: "reward.amount", From: str("100"), To: str("0")},
+				{Field: "status", From: str("ACTIVE"), To: str("DRAFT")},
+				{Field: "enabled", From: str("true"), To: str("false")},
+			},
+		},
+		{
+			name:     "category cleared",
+			from:     &rule,
+			change:   func(r *models.Rule) { r.ConditionsCategory = nil },
+			expected: []models.FieldChange{{Field: "conditions.category", From: str("MATH")}},
+		},
+		{
+			name:     "archived",
+			from:     &rule,
+			change:   func(r *models.Rule) { r.ArchivedAt = &archivedAt },
+			expected: []models.FieldChange{{Field: "archivedAt", To: str("2025-07-01T08:30:00Z")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := rule
+			tt.change(&to)
+			assert.Equal(t, tt.expected, diffRules(tt.from, to))
+		})
+	}
+}
+
+func TestRuleUpdateApply(t *testing.T) {
+	category := "MATH"
+	rule := models.Rule{
+		EventType:          "COURSE_COMPLETED",
+		Count:              5,
+		ConditionsCategory: &category,
+		Reward:             models.Reward{Type: models.PointsReward, Amount: 100, Description: "Math"},
+	}
+
+	RuleUpdate{
+		Count:              Some(0),
+		ConditionsCategory: Some[*string](nil),
+	}.apply(&rule)
+
+	assert.Equal(t, models.Rule{
+		EventType: "COURSE_COMPLETED",
+		Reward:    models.Reward{Type: models.PointsReward, Amount: 100, Description: "Math"},
+	}, rule)
+}
+
+func TestReviewAgain(t *testing.T) {
+	rule := models.Rule{EventType: "COURSE_COMPLETED", Count: 5}
+
+	tests := []struct {
+		name     string
+		status   models.RuleStatus
+		changed  bool
+		expected models.RuleStatus
+	}{
+		{name: "pending review changed", status: models.RulePendingReview, changed: true, expected: models.RuleDraft},
+		{name: "approved changed", status: models.RuleApproved, changed: true, expected: models.RuleDraft},
+		{name: "approved unchanged", status: models.RuleApproved, expected: models.RuleApproved},
+		{name: "draft changed", status: models.RuleDraft, changed: true, expected: models.RuleDraft},
+		{name: "active changed", status: models.RuleActive, changed: true, expected: models.RuleActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := rule
+			before.Status = tt.status
+			after := before
+			if tt.changed {
+				after.Count = 10
+			}
+			reviewAgain(before, &after)
+			assert.Equal(t, tt.expected, after.Status)
+		})
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      models.RuleStatus
+		allowActive bool
+		expected    error
+	}{
+		{name: "draft", status: models.RuleDraft},
+		{name: "approved", status: models.RuleApproved},
+		{name: "active", status: models.RuleActive, expected: ErrRuleActive},
+		{name: "active allowed", status: models.RuleActive, allowActive: true},
+		{name: "retired", status: models.RuleRetired, allowActive: true, expected: ErrRuleArchived},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUpdate(models.Rule{ID: "rule-001", Status: tt.status}, tt.allowActive)
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/audit"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRuleVersionNotFound is returned when reverting to a version a rule
+	// does not have
+	ErrRuleVersionNotFound = errors.New("rule version not found")
+	// ErrRuleArchived is returned when changing an archived rule
+	ErrRuleArchived = errors.New("rule is archived")
+	// ErrRuleActive is returned when changing an active rule without being
+	// allowed to
+	ErrRuleActive = errors.New("rule is active")
+	// ErrSelfApproval is returned when the user who submitted a rule for
+	// review approves it
+	ErrSelfApproval = errors.New("a rule must be approved by another user than the one who submitted it")
+)
+
+// TransitionError is returned for a review change that does not apply to
+// the status of a rule
+type TransitionError struct {
+	Change models.RuleChange
+	Status models.RuleStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot %s a rule that is %s", strings.ToLower(string(e.Change)), e.Status)
+}
+
+// GetRuleHistory implements RuleRepository
+func (r *GormRuleRepository) GetRuleHistory(ctx context.Context, id string) ([]models.RuleVersion, error) {
+	var versions []models.RuleVersion
+	err := r.db.WithContext(ctx).
+		Where("rule_id = ?", id).
+		Order("version DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+// RevertRule implements RuleRepository. The archive state is not part of the
+// definition, so archived rules cannot be reverted. Reverting to a version
+// with the current definition changes nothing.
+func (r *GormRuleRepository) RevertRule(ctx context.Context, id string, version int) (*models.Rule, error) {
+	var rule models.Rule
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rule, err = lockRule(tx, id); err != nil {
+			return err
+		}
+		if rule.ArchivedAt != nil {
+			return ErrRuleArchived
+		}
+
+		var target models.RuleVersion
+		if err := tx.First(&target, "rule_id = ? AND version = ?", id, version).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRuleVersionNotFound
+			}
+			return err
+		}
+
+		before := rule
+		update := RuleUpdate{
+			EventType:          Some(target.EventType),
+			Count:              Some(target.Count),
+			ConditionsCategory: Some(target.ConditionsCategory),
+			Reward:             Some(target.Reward),
+		}
+		update.apply(&rule)
+		reviewAgain(before, &rule)
+		return saveVersion(ctx, tx, before, &rule, models.RuleReverted, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// TransitionRule implements RuleRepository. A rule is approved by another
+// user than the one who last submitted it.
+func (r *GormRuleRepository) TransitionRule(ctx context.Context, id string, change models.RuleChange, comment string) (*models.Rule, error) {
+	var rule models.Rule
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rule, err = lockRule(tx, id); err != nil {
+			return err
+		}
+		status, ok := rule.Status.Transition(change)
+		if !ok {
+			return &TransitionError{Change: change, Status: rule.Status}
+		}
+
+		if change == models.RuleApproval {
+			var submitted models.RuleVersion
+			if err := tx.Where("rule_id = ? AND change = ?", id, models.RuleSubmitted).
+				Order("version DESC").
+				First(&submitted).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if submitted.Author == audit.Author(ctx) {
+				return ErrSelfApproval
+			}
+		}
+
+		before := rule
+		rule.Status = status
+		return saveVersion(ctx, tx, before, &rule, change, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// reviewAgain sends a rule whose definition changed while it was pending
+// review or approved back to draft, since the review was of the previous
+// definition
+func reviewAgain(before models.Rule, after *models.Rule) {
+	if before.Status != models.RulePendingReview && before.Status != models.RuleApproved {
+		return
+	}
+	if len(diffRules(&before, *after)) > 0 {
+		after.Status = models.RuleDraft
+	}
+}
+
+// lockRule reads a rule, locking it until the end of the transaction so that
+// its versions are numbered in order
+func lockRule(tx *gorm.DB, id string) (models.Rule, error) {
+	var rule models.Rule
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&rule, "id = ?", id).Error
+	return rule, err
+}
+
+// nextVersion returns the version of a rule being created: 1, or the version
+// following the history kept of a deleted rule with the same ID
+func nextVersion(tx *gorm.DB, ruleID string) (int, error) {
+	var last int
+	err := tx.Model(&models.RuleVersion{}).
+		Where("rule_id = ?", ruleID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&last).Error
+	return last + 1, err
+}
+
+// saveVersion writes the rule changed from before to after, and records
+// after as a new version. Nothing is written if nothing changed.
+func saveVersion(ctx context.Context, tx *gorm.DB, before models.Rule, after *models.Rule, change models.RuleChange, comment string) error {
+	after.Enabled = after.Status == models.RuleActive
+	diff := diffRules(&before, *after)
+	if len(diff) == 0 {
+		*after = before
+		return nil
+	}
+
+	after.Version = before.Version + 1
+	if err := tx.Model(&models.Rule{}).Where("id = ?", after.ID).Updates(ruleColumns(*after)).Error; err != nil {
+		return err
+	}
+	return recordVersion(ctx, tx, *after, change, diff, comment)
+}
+
+// ruleColumns returns the columns of the rule that change. They are written
+// from a map so that zero values and nulls are written too.
+func ruleColumns(rule models.Rule) map[string]any {
+	return map[string]any{
+		"event_type":          rule.EventType,
+		"count":               rule.Count,
+		"conditions_category": rule.ConditionsCategory,
+		"type":                rule.Reward.Type,
+		"amount":              rule.Reward.Amount,
+		"description":         rule.Reward.Description,
+		"status":              rule.Status,
+		"enabled":             rule.Enabled,
+		"archived_at":         rule.ArchivedAt,
+		"version":             rule.Version,
+	}
+}
+
+// recordVersion stores rule as a version made by the author of ctx
+func recordVersion(ctx context.Context, tx *gorm.DB, rule models.Rule, change models.RuleChange, diff []models.FieldChange, comment string) error {
+	version := models.NewRuleVersion(rule, change, audit.Author(ctx), diff)
+	version.Comment = comment
+	return tx.Create(&version).Error
+}
+
+// diffRules returns the fields of the definition of a rule that differ
+// between from and to. A nil from is a rule that does not exist yet.
+func diffRules(from *models.Rule, to models.Rule) []models.FieldChange {
+	var diff []models.FieldChange
+	add := func(field string, from, to *string) {
+		if from == nil && to == nil || from != nil && to != nil && *from == *to {
+			return
+		}
+		diff = append(diff, models.FieldChange{Field: field, From: from, To: to})
+	}
+
+	var old ruleFields
+	if from != nil {
+		old = fieldsOf(*from)
+	}
+	for i, field := range fieldsOf(to) {
+		add(field.name, old[i].value, field.value)
+	}
+	return diff
+}
+
+// ruleFields are the fields of a rule compared by diffRules, named as in the
+// GraphQL API
+type ruleFields [9]struct {
+	name  string
+	value *string
+}
+
+func fieldsOf(rule models.Rule) ruleFields {
+	str := func(s string) *string { return &s }
+	var archivedAt *string
+	if rule.ArchivedAt != nil {
+		archivedAt = str(rule.ArchivedAt.UTC().Format(time.RFC3339))
+	}
+	return ruleFields{
+		{"eventType", str(rule.EventType)},
+		{"count", str(strconv.Itoa(rule.Count))},
+		{"conditions.category", rule.ConditionsCategory},
+		{"reward.type", str(string(rule.Reward.Type))},
+		{"reward.amount", str(strconv.Itoa(rule.Reward.Amount))},
+		{"reward.description", str(rule.Reward.Description)},
+		{"status", str(string(rule.Status))},
+		{"enabled", str(strconv.FormatBool(rule.Enabled))},
+		{"archivedAt", archivedAt},
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transactor runs functions in a database transaction
+type Transactor interface {
+	// RunInTx runs fn in a transaction, committed if fn returns nil.
+	// Repositories called with the context passed to fn take part in the
+	// transaction. Nested calls join the outer transaction.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Ensure GormTransactor implements Transactor
+var _ Transactor = (*GormTransactor)(nil)
+
+// GormTransactor implements Transactor using GORM
+type GormTransactor struct {
+	db *gorm.DB
+}
+
+// NewGormTransactor creates a new GORM-based transactor
+func NewGormTransactor(db *gorm.DB) *GormTransactor {
+	return &GormTransactor{db: db}
+}
+
+// RunInTx implements Transactor
+func (t *GormTransactor) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction carried by ctx, or db outside of one
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
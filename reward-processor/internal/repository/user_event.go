@@ -2,95 +2,154 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// EventCounts are the counts of a user's event type right after an increment
+type EventCounts struct {
+	// Category is the count of the incremented category
+	Category int
+	// Total is the count across every category
+	Total int
+}
+
 // UserEventRepository defines the interface for user event count operations
 type UserEventRepository interface {
-	// Increment increments the count for a user's event
-	Increment(ctx context.Context, userID, eventType, category string) error
+	// Increment atomically increments the count for a user's event and
+	// returns the new counts
+	Increment(ctx context.Context, userID, eventType, category string) (EventCounts, error)
 	// GetCount returns the current count for a user's event
 	// If category is provided, it will count events with that specific category
-	// If category is empty, it will count all events of that type using GROUP BY
+	// If category is empty, it will count all events of that type
 	GetCount(ctx context.Context, userID, eventType, category string) (int, error)
 }
 
-// Ensure GormUserEventRepository implements UserEventRepository
-var _ UserEventRepository = (*GormUserEventRepository)(nil)
+// CountDelta is an amount to add to the count of a user's event category
+type CountDelta struct {
+	UserID    string
+	EventType string
+	Category  string
+	Delta     int
+}
+
+// CountStore is the storage behind a count cache
+type CountStore interface {
+	UserEventRepository
+	// Counts returns the count of each category of a user's event type
+	Counts(ctx context.Context, userID, eventType string) (map[string]int, error)
+	// AddCounts adds deltas to the counts, creating missing ones. Deltas
+	// must not repeat a user, event type and category.
+	AddCounts(ctx context.Context, deltas []CountDelta) error
+}
+
+// Ensure GormUserEventRepository implements CountStore
+var _ CountStore = (*GormUserEventRepository)(nil)
 
 // GormUserEventRepository implements UserEventRepository using GORM
 type GormUserEventRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	table string
 }
 
 // NewGormUserEventRepository creates a new GORM-based user event count repository
 func NewGormUserEventRepository(db *gorm.DB) *GormUserEventRepository {
-	return &GormUserEventRepository{db: db}
+	return &GormUserEventRepository{db: db, table: UserEventCountsTable}
 }
 
-// Increment implements UserEventRepository
-func (r *GormUserEventRepository) Increment(ctx context.Context, userID, eventType, category string) error {
-	var count models.UserEventCount
-
-	// Use a transaction to ensure atomicity
-	err := r.db.Transaction(func(tx *gorm.DB) error {
-		// Create or update the event count
-		result := tx.WithContext(ctx).
-			Where("user_id = ? AND event_type = ? AND category = ?", userID, eventType, category).
-			First(&count)
-
-		if result.Error == gorm.ErrRecordNotFound {
-			// Create new record
-			count = models.UserEventCount{
-				UserID:    userID,
-				EventType: eventType,
-				Category:  category,
-				Count:     1,
-				UpdatedAt: time.Now(),
-			}
-			return tx.Create(&count).Error
-		}
-
-		if result.Error != nil {
-			return result.Error
-		}
-
-		count.UpdatedAt = time.Now()
-		return tx.Model(&models.UserEventCount{}).
-			Where("user_id = ? AND event_type = ? AND category = ?", userID, eventType, category).
-			Updates(map[string]interface{}{
-				"count":      count.Count + 1,
-				"updated_at": count.UpdatedAt,
-			}).Error
-	})
-
-	return err
+// Increment implements UserEventRepository. The upsert relies on the unique
+// key of the counts table, so concurrent increments never create duplicate
+// rows nor lose updates. The total is computed in the same statement: the
+// subquery sees the counts as they were before it, so the other categories
+// are added to the new count of the incremented one.
+func (r *GormUserEventRepository) Increment(ctx context.Context, userID, eventType, category string) (EventCounts, error) {
+	var counts EventCounts
+	err := conn(ctx, r.db).Raw(fmt.Sprintf(`
+		WITH upsert AS (
+			INSERT INTO %[1]s (user_id, event_type, category, count, updated_at)
+			VALUES (@user_id, @event_type, @category, 1, @now)
+			ON CONFLICT (user_id, event_type, category)
+			DO UPDATE SET count = %[1]s.count + 1, updated_at = EXCLUDED.updated_at
+			RETURNING count
+		)
+		SELECT upsert.count AS category,
+			upsert.count + COALESCE((
+				SELECT SUM(count) FROM %[1]s
+				WHERE user_id = @user_id AND event_type = @event_type AND category <> @category
+			), 0) AS total
+		FROM upsert`, r.table),
+		sql.Named("user_id", userID),
+		sql.Named("event_type", eventType),
+		sql.Named("category", category),
+		sql.Named("now", time.Now()),
+	).Scan(&counts).Error
+	return counts, err
 }
 
 // GetCount implements UserEventRepository
 func (r *GormUserEventRepository) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
 	var count int64
 
-	query := r.db.WithContext(ctx).Model(&models.UserEventCount{}).
+	query := conn(ctx, r.db).Table(r.table).
 		Where("user_id = ? AND event_type = ?", userID, eventType)
-
 	if category != "" {
 		// For category-specific rules, get count for that category
 		query = query.Where("category = ?", category)
-		err := query.Select("count").Scan(&count).Error
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		// For generic rules, sum up all counts for this event type
-		err := query.Select("COALESCE(SUM(count), 0) as count").Scan(&count).Error
-		if err != nil {
-			return 0, err
-		}
 	}
 
+	// Without a category, sum up all counts for this event type
+	if err := query.Select("COALESCE(SUM(count), 0)").Scan(&count).Error; err != nil {
+		return 0, err
+	}
 	return int(count), nil
 }
+
+// Counts implements CountStore
+func (r *GormUserEventRepository) Counts(ctx context.Context, userID, eventType string) (map[string]int, error) {
+	var rows []models.UserEventCount
+	if err := conn(ctx, r.db).Table(r.table).
+		Where("user_id = ? AND event_type = ?", userID, eventType).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Category] = row.Count
+	}
+	return counts, nil
+}
+
+// addCountsBatchSize is the number of counts upserted per statement
+const addCountsBatchSize = 500
+
+// AddCounts implements CountStore
+func (r *GormUserEventRepository) AddCounts(ctx context.Context, deltas []CountDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rows := make([]models.UserEventCount, len(deltas))
+	for i, d := range deltas {
+		rows[i] = models.UserEventCount{
+			UserID:    d.UserID,
+			EventType: d.EventType,
+			Category:  d.Category,
+			Count:     d.Delta,
+			UpdatedAt: now,
+		}
+	}
+	return conn(ctx, r.db).Table(r.table).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "event_type"}, {Name: "category"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":      gorm.Expr(r.table + ".count + EXCLUDED.count"),
+				"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+			}),
+		}).
+		CreateInBatches(rows, addCountsBatchSize).Error
+}
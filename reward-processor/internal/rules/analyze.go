@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/catalog"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+// FindingKind is the kind of problem Analyze finds in a rule
+type FindingKind string
+
+const (
+	// FindingDuplicate is a rule with the trigger and the reward of another:
+	// users get the reward twice
+	FindingDuplicate FindingKind = "DUPLICATE"
+	// FindingShadowed is a rule with the trigger of another but another
+	// reward: the rules always fire together
+	FindingShadowed FindingKind = "SHADOWED"
+	// FindingUnreachableCount is a rule whose count users cannot reach
+	FindingUnreachableCount FindingKind = "UNREACHABLE_COUNT"
+	// FindingUnknownCategory is a rule whose category is not in the catalog
+	FindingUnknownCategory FindingKind = "UNKNOWN_CATEGORY"
+)
+
+// Finding is a problem Analyze found in a rule
+type Finding struct {
+	Kind   FindingKind
+	RuleID string
+	// RelatedRuleIDs are the other rules involved, for duplicates and
+	// shadowed rules
+	RelatedRuleIDs []string
+	Message        string
+}
+
+// trigger is what makes a rule fire: the engine evaluates every rule, and a
+// rule fires on the event of its type, in its category, that brings the count
+// to its own
+type trigger struct {
+	eventType string
+	category  string
+	count     int
+}
+
+func triggerOf(rule models.Rule) trigger {
+	t := trigger{eventType: rule.EventType, count: rule.Count}
+	if rule.ConditionsCategory != nil {
+		t.category = *rule.ConditionsCategory
+	}
+	return t
+}
+
+// Analyze inspects rules, the rules that are not archived, and returns the
+// problems found, by rule ID. Categories are checked against cat; they are
+// not checked if cat is nil.
+func Analyze(rules []models.Rule, cat *catalog.Catalog) []Finding {
+	sorted := append([]models.Rule(nil), rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	byTrigger := make(map[trigger][]models.Rule)
+	for _, rule := range sorted {
+		t := triggerOf(rule)
+		byTrigger[t] = append(byTrigger[t], rule)
+	}
+
+	var findings []Finding
+	for _, rule := range sorted {
+		findings = append(findings, overlaps(rule, byTrigger[triggerOf(rule)])...)
+		findings = append(findings, checkCount(rule, cat)...)
+	}
+	return findings
+}
+
+// AnalyzeRule returns the problems found in rule alone, compared with others,
+// the rules that are not archived. Unlike Analyze, it does not report the
+// other rules, so a pair of duplicates is reported once, by rule.
+func AnalyzeRule(rule models.Rule, others []models.Rule, cat *catalog.Catalog) []Finding {
+	t := triggerOf(rule)
+	var same []models.Rule
+	for _, other := range others {
+		if other.ID != rule.ID && triggerOf(other) == t {
+			same = append(same, other)
+		}
+	}
+	sort.Slice(same, func(i, j int) bool { return same[i].ID < same[j].ID })
+	return append(overlaps(rule, same), checkCount(rule, cat)...)
+}
+
+// overlaps reports rule as a duplicate, or as shadowed, if other rules share
+// its trigger
+func overlaps(rule models.Rule, same []models.Rule) []Finding {
+	var duplicates, others []string
+	for _, other := range same {
+		switch {
+		case other.ID == rule.ID:
+		case other.Reward.Type == rule.Reward.Type && other.Reward.Amount == rule.Reward.Amount:
+			duplicates = append(duplicates, other.ID)
+		default:
+			others = append(others, other.ID)
+		}
+	}
+
+	var findings []Finding
+	if len(duplicates) > 0 {
+		findings = append(findings, Finding{
+			Kind:           FindingDuplicate,
+			RuleID:         rule.ID,
+			RelatedRuleIDs: duplicates,
+			Message: fmt.Sprintf("%s has the trigger (%s) and the reward of %s: users get the reward twice",
+				rule.ID, describeTrigger(rule), strings.Join(duplicates, ", ")),
+		})
+	}
+	if len(others) > 0 {
+		findings = append(findings, Finding{
+			Kind:           FindingShadowed,
+			RuleID:         rule.ID,
+			RelatedRuleIDs: others,
+			Message: fmt.Sprintf("%s has the trigger (%s) of %s: they always fire together, granting every reward; merge them into one rule",
+				rule.ID, describeTrigger(rule), strings.Join(others, ", ")),
+		})
+	}
+	return findings
+}
+
+// checkCount reports the count of rule if users cannot reach it, and its
+// category if the catalog does not have it.
+//
+// Counts are not bounded by the courses of the catalog: the engine counts
+// every COURSE_COMPLETED event, including a course completed again.
+func checkCount(rule models.Rule, cat *catalog.Catalog) []Finding {
+	if rule.Count < 1 {
+		return []Finding{{
+			Kind:    FindingUnreachableCount,
+			RuleID:  rule.ID,
+			Message: fmt.Sprintf("%s requires a count of %d, but counts start at 1", rule.ID, rule.Count),
+		}}
+	}
+	if cat == nil || rule.ConditionsCategory == nil {
+		return nil
+	}
+	if _, _, ok := cat.Category(*rule.ConditionsCategory); !ok {
+		return []Finding{{
+			Kind:   FindingUnknownCategory,
+			RuleID: rule.ID,
+			Message: fmt.Sprintf("%s requires category %s, which is not a category of the catalog (%s)",
+				rule.ID, *rule.ConditionsCategory, strings.Join(cat.Categories(), ", ")),
+		}}
+	}
+	return nil
+}
+
+func describeTrigger(rule models.Rule) string {
+	if rule.ConditionsCategory == nil {
+		return fmt.Sprintf("%d %s events", rule.Count, rule.EventType)
+	}
+	return fmt.Sprintf("%d %s events in category %s", rule.Count, rule.EventType, *rule.ConditionsCategory)
+}
@@ -0,0 +1,160 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/catalog"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	cat := &catalog.Catalog{Courses: map[string]int{"Math": 3, "Science": 1}}
+	points := func(amount int) models.Reward {
+		return models.Reward{Type: models.PointsReward, Amount: amount, Description: "Points"}
+	}
+	rule := func(id, eventType string, category *string, count int, reward models.Reward) models.Rule {
+		return models.Rule{ID: id, EventType: eventType, ConditionsCategory: category, Count: count, Reward: reward}
+	}
+
+	tests := []struct {
+		name     string
+		rules    []models.Rule
+		catalog  *catalog.Catalog
+		expected []rules.Finding
+	}{
+		{
+			name: "no problem",
+			rules: []models.Rule{
+				rule("rule-001", "COURSE_COMPLETED", ptrString("MATH"), 1, points(10)),
+				rule("rule-002", "COURSE_COMPLETED", ptrString("MATH"), 3, points(10)),
+				rule("rule-003", "COURSE_COMPLETED", nil, 1, points(10)),
+				rule("rule-004", "CHAPTER_COMPLETED", nil, 50, points(10)),
+			},
+			catalog: cat,
+		},
+		{
+			name: "duplicates",
+			rules: []models.Rule{
+				rule("rule-002", "COURSE_COMPLETED", ptrString("MATH"), 1, points(10)),
+				rule("rule-001", "COURSE_COMPLETED", ptrString("MATH"), 1, models.Reward{Type: models.PointsReward, Amount: 10, Description: "Other"}),
+			},
+			expected: []rules.Finding{
+				{
+					Kind:           rules.FindingDuplicate,
+					RuleID:         "rule-001",
+					RelatedRuleIDs: []string{"rule-002"},
+					Message:        "rule-001 has the trigger (1 COURSE_COMPLETED events in category MATH) and the reward of rule-002: users get the reward twice",
+				},
+				{
+					Kind:           rules.FindingDuplicate,
+					RuleID:         "rule-002",
+					RelatedRuleIDs: []string{"rule-001"},
+					Message:        "rule-002 has the trigger (1 COURSE_COMPLETED events in category MATH) and the reward of rule-001: users get the reward twice",
+				},
+			},
+		},
+		{
+			name: "shadowed",
+			rules: []models.Rule{
+				rule("rule-001", "CHAPTER_COMPLETED", nil, 10, points(10)),
+				rule("rule-002", "CHAPTER_COMPLETED", nil, 10, models.Reward{Type: models.BadgeReward, Description: "Badge"}),
+			},
+			expected: []rules.Finding{
+				{
+					Kind:           rules.FindingShadowed,
+					RuleID:         "rule-001",
+					RelatedRuleIDs: []string{"rule-002"},
+					Message:        "rule-001 has the trigger (10 CHAPTER_COMPLETED events) of rule-002: they always fire together, granting every reward; merge them into one rule",
+				},
+				{
+					Kind:           rules.FindingShadowed,
+					RuleID:         "rule-002",
+					RelatedRuleIDs: []string{"rule-001"},
+					Message:        "rule-002 has the trigger (10 CHAPTER_COMPLETED events) of rule-001: they always fire together, granting every reward; merge them into one rule",
+				},
+			},
+		},
+		{
+			name: "other categories or counts do not overlap",
+			rules: []models.Rule{
+				rule("rule-001", "COURSE_COMPLETED", ptrString("MATH"), 1, points(10)),
+				rule("rule-002", "COURSE_COMPLETED", ptrString("SCIENCE"), 1, points(10)),
+				rule("rule-003", "COURSE_COMPLETED", nil, 1, points(10)),
+				rule("rule-004", "COURSE_COMPLETED", ptrString("MATH"), 2, points(10)),
+			},
+		},
+		{
+			// Completions are counted per event, not per course, so
+			// counts above the courses of the catalog are reachable
+			name: "unreachable counts",
+			rules: []models.Rule{
+				rule("rule-001", "COURSE_COMPLETED", ptrString("math"), 5, points(10)),
+				rule("rule-002", "COURSE_COMPLETED", nil, 30, points(10)),
+				rule("rule-003", "CHAPTER_COMPLETED", nil, 0, points(10)),
+			},
+			catalog: cat,
+			expected: []rules.Finding{
+				{
+					Kind:    rules.FindingUnreachableCount,
+					RuleID:  "rule-003",
+					Message: "rule-003 requires a count of 0, but counts start at 1",
+				},
+			},
+		},
+		{
+			name: "unknown category",
+			rules: []models.Rule{
+				rule("rule-001", "COURSE_COMPLETED", ptrString("PROGRAMMING"), 1, points(10)),
+			},
+			catalog: cat,
+			expected: []rules.Finding{
+				{
+					Kind:    rules.FindingUnknownCategory,
+					RuleID:  "rule-001",
+					Message: "rule-001 requires category PROGRAMMING, which is not a category of the catalog (Math, Science)",
+				},
+			},
+		},
+		{
+			name: "catalog checks skipped without a catalog",
+			rules: []models.Rule{
+				rule("rule-001", "COURSE_COMPLETED", ptrString("PROGRAMMING"), 100, points(10)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rules.Analyze(tt.rules, tt.catalog))
+		})
+	}
+}
+
+func TestAnalyzeRule(t *testing.T) {
+	cat := &catalog.Catalog{Courses: map[string]int{"Math": 3}}
+	reward := models.Reward{Type: models.PointsReward, Amount: 10, Description: "Points"}
+	changed := models.Rule{ID: "rule-007", EventType: "COURSE_COMPLETED", ConditionsCategory: ptrString("ART"), Count: 1, Reward: reward}
+	others := []models.Rule{
+		{ID: "rule-003", EventType: "COURSE_COMPLETED", ConditionsCategory: ptrString("ART"), Count: 1, Reward: reward},
+		{ID: "rule-001", EventType: "COURSE_COMPLETED", ConditionsCategory: ptrString("ART"), Count: 1, Reward: reward},
+		// The other rules are not analyzed: their problems are left out
+		{ID: "rule-002", EventType: "CHAPTER_COMPLETED", Count: 0, Reward: reward},
+		changed,
+	}
+
+	assert.Equal(t, []rules.Finding{
+		{
+			Kind:           rules.FindingDuplicate,
+			RuleID:         "rule-007",
+			RelatedRuleIDs: []string{"rule-001", "rule-003"},
+			Message:        "rule-007 has the trigger (1 COURSE_COMPLETED events in category ART) and the reward of rule-001, rule-003: users get the reward twice",
+		},
+		{
+			Kind:    rules.FindingUnknownCategory,
+			RuleID:  "rule-007",
+			Message: "rule-007 requires category ART, which is not a category of the catalog (Math)",
+		},
+	}, rules.AnalyzeRule(changed, others, cat))
+}
@@ -2,46 +2,119 @@ package rules
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/metrics"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // Engine handles rule evaluation and milestone tracking
 type Engine struct {
+	mu        sync.RWMutex
 	rules     []models.Rule
+	status    LoadStatus
 	eventRepo repository.UserEventRepository
 	logger    *zap.Logger
 }
 
+// LoadStatus is the outcome of the loads of the rules of an engine
+type LoadStatus struct {
+	// Rules and Active count the rules loaded, and the ACTIVE ones among
+	// them
+	Rules  int
+	Active int
+	// LoadedAt is when the rules were last loaded
+	LoadedAt time.Time
+	// Err is the error of the last load, nil if it succeeded. The engine
+	// keeps the rules it had when a load fails.
+	Err      error
+	FailedAt time.Time
+}
+
 // NewEngine creates a new rules engine with the given rules
 func NewEngine(rules []models.Rule, eventRepo repository.UserEventRepository, logger *zap.Logger) *Engine {
-	return &Engine{
-		rules:     rules,
+	e := &Engine{
 		eventRepo: eventRepo,
 		logger:    logger,
 	}
+	e.SetRules(rules)
+	return e
 }
 
-// SetRules sets the rules for the engine (used for testing)
+// SetRules sets the rules for the engine
 func (e *Engine) SetRules(rules []models.Rule) {
+	active := 0
+	for _, rule := range rules {
+		if rule.Status == models.RuleActive {
+			active++
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.rules = rules
+	e.status = LoadStatus{Rules: len(rules), Active: active, LoadedAt: time.Now()}
+}
+
+// Reload replaces the rules of the engine with those load returns. If load
+// fails, the engine keeps its rules and Status reports the failure.
+func (e *Engine) Reload(ctx context.Context, load func(ctx context.Context) ([]models.Rule, error)) error {
+	rules, err := load(ctx)
+	if err != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.status.Err = err
+		e.status.FailedAt = time.Now()
+		return err
+	}
+	e.SetRules(rules)
+	return nil
+}
+
+// Status returns the outcome of the loads of the rules
+func (e *Engine) Status() LoadStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.status
+}
+
+// loaded returns the rules of the engine
+func (e *Engine) loaded() []models.Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
 }
 
 // EvaluateEvent processes a user event against all rules
-func (e *Engine) EvaluateEvent(ctx context.Context, event models.UserEvent) ([]models.RewardTriggered, error) {
+func (e *Engine) EvaluateEvent(ctx context.Context, event models.UserEvent) (_ []models.RewardTriggered, err error) {
 	var triggered []models.RewardTriggered
+	rules := e.loaded()
+
+	ctx, span := tracing.Tracer().Start(ctx, "rules evaluate", trace.WithAttributes(
+		attribute.String("event.type", event.EventType),
+		attribute.String("event.category", event.Category),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("rules.triggered", len(triggered)))
+		tracing.End(span, err)
+	}()
 
 	e.logger.Info("Starting event evaluation",
 		zap.String("user_id", event.UserID),
 		zap.String("event_type", event.EventType),
 		zap.String("category", event.Category),
-		zap.Int("total_rules", len(e.rules)))
+		zap.Int("total_rules", len(rules)))
 
-	// First, increment the event count with its category
-	if err := e.eventRepo.Increment(ctx, event.UserID, event.EventType, event.Category); err != nil {
+	// First, increment the event count with its category. The new counts
+	// are all the rules need.
+	counts, err := e.eventRepo.Increment(ctx, event.UserID, event.EventType, event.Category)
+	if err != nil {
 		e.logger.Error("Failed to increment event count",
 			zap.String("user_id", event.UserID),
 			zap.String("event_type", event.EventType),
@@ -51,7 +124,7 @@ func (e *Engine) EvaluateEvent(ctx context.Context, event models.UserEvent) ([]m
 	}
 
 	// Then evaluate each rule
-	for _, rule := range e.rules {
+	for _, rule := range rules {
 		if !rule.Enabled {
 			e.logger.Debug("Skipping disabled rule",
 				zap.String("rule_id", rule.ID),
@@ -68,69 +141,88 @@ func (e *Engine) EvaluateEvent(ctx context.Context, event models.UserEvent) ([]m
 			continue
 		}
 
-		// Check conditions
-		if !e.matchesConditions(event, rule) {
-			e.logger.Debug("Rule conditions not met",
-				zap.String("rule_id", rule.ID),
-				zap.String("user_id", event.UserID),
-				zap.Any("conditions", rule.ConditionsCategory),
-				zap.Any("event_data", event))
-			continue
+		if reward, ok := e.evaluateRule(ctx, event, rule, counts); ok {
+			triggered = append(triggered, reward)
 		}
+	}
+
+	e.logger.Info("Completed event evaluation",
+		zap.String("user_id", event.UserID),
+		zap.String("event_type", event.EventType),
+		zap.String("category", event.Category),
+		zap.Int("rules_triggered", len(triggered)))
+
+	return triggered, nil
+}
 
-		e.logger.Debug("Rule conditions met, evaluating rule",
+// evaluateRule returns the reward of rule if event reaches its milestone.
+// The rule's event type must match the event's.
+func (e *Engine) evaluateRule(ctx context.Context, event models.UserEvent, rule models.Rule, counts repository.EventCounts) (models.RewardTriggered, bool) {
+	_, span := tracing.Tracer().Start(ctx, "rule "+rule.ID, trace.WithAttributes(
+		attribute.String("rule.id", rule.ID),
+		attribute.Int("rule.count", rule.Count),
+	))
+	defer span.End()
+
+	// Check conditions
+	if !e.matchesConditions(event, rule) {
+		e.logger.Debug("Rule conditions not met",
 			zap.String("rule_id", rule.ID),
 			zap.String("user_id", event.UserID),
-		)
+			zap.Any("conditions", rule.ConditionsCategory),
+			zap.Any("event_data", event))
+		span.SetAttributes(attribute.Bool("rule.matched", false))
+		return models.RewardTriggered{}, false
+	}
 
-		var ruleCategory string
+	metrics.RulesEvaluated.WithLabelValues(rule.ID).Inc()
 
-		if rule.ConditionsCategory != nil {
-			ruleCategory = *rule.ConditionsCategory
-		}
+	e.logger.Debug("Rule conditions met, evaluating rule",
+		zap.String("rule_id", rule.ID),
+		zap.String("user_id", event.UserID),
+	)
 
-		// Get the appropriate count based on rule type
-		count, err := e.eventRepo.GetCount(ctx, event.UserID, event.EventType, ruleCategory)
-		if err != nil {
-			e.logger.Error("Failed to get count",
-				zap.String("user_id", event.UserID),
-				zap.String("rule_id", rule.ID),
-				zap.String("event_type", event.EventType),
-				zap.Error(err))
-			return nil, err
-		}
+	var ruleCategory string
 
-		e.logger.Debug("Current count for rule",
-			zap.String("user_id", event.UserID),
-			zap.String("rule_id", rule.ID),
-			zap.Int("current_count", count),
-			zap.Int("required_count", rule.Count),
-			zap.String("category", ruleCategory))
-
-		if count == rule.Count {
-			triggered = append(triggered, models.RewardTriggered{
-				UserID:    event.UserID,
-				RuleID:    rule.ID,
-				Reward:    rule.Reward,
-				Timestamp: time.Now(),
-			})
-			e.logger.Info("Rule triggered",
-				zap.String("user_id", event.UserID),
-				zap.String("rule_id", rule.ID),
-				zap.String("event", event.EventType),
-				zap.String("category", ruleCategory),
-				zap.Int("count", rule.Count),
-				zap.Any("reward", rule.Reward))
-		}
+	// Category rules only match events of their category, so the count
+	// of the event's category is theirs; other rules count every category
+	count := counts.Total
+	if rule.ConditionsCategory != nil {
+		ruleCategory = *rule.ConditionsCategory
+		count = counts.Category
 	}
 
-	e.logger.Info("Completed event evaluation",
+	e.logger.Debug("Current count for rule",
 		zap.String("user_id", event.UserID),
-		zap.String("event_type", event.EventType),
-		zap.String("category", event.Category),
-		zap.Int("rules_triggered", len(triggered)))
+		zap.String("rule_id", rule.ID),
+		zap.Int("current_count", count),
+		zap.Int("required_count", rule.Count),
+		zap.String("category", ruleCategory))
+
+	span.SetAttributes(
+		attribute.Bool("rule.matched", true),
+		attribute.Int("rule.current_count", count),
+		attribute.Bool("rule.triggered", count == rule.Count),
+	)
+	if count != rule.Count {
+		return models.RewardTriggered{}, false
+	}
 
-	return triggered, nil
+	metrics.RulesTriggered.WithLabelValues(rule.ID).Inc()
+	e.logger.Info("Rule triggered",
+		zap.String("user_id", event.UserID),
+		zap.String("rule_id", rule.ID),
+		zap.String("event", event.EventType),
+		zap.String("category", ruleCategory),
+		zap.Int("count", rule.Count),
+		zap.Any("reward", rule.Reward))
+	return models.RewardTriggered{
+		UserID:      event.UserID,
+		RuleID:      rule.ID,
+		RuleVersion: rule.Version,
+		Reward:      rule.Reward,
+		Timestamp:   time.Now(),
+	}, true
 }
 
 // matchesConditions checks if an event matches all conditions in a rule
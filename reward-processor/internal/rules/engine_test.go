@@ -5,23 +5,32 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
 	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
-// stubUserEventRepository is a simple stub implementation
+// stubUserEventRepository is a simple stub implementation. Increment returns
+// getCount for the category, and total when set or getCount otherwise.
 type stubUserEventRepository struct {
-	getCount int
-	err      error
+	getCount      int
+	total         int
+	err           error
+	getCountCalls int
 }
 
-func (s *stubUserEventRepository) Increment(ctx context.Context, userID, eventType, category string) error {
-	return s.err
+func (s *stubUserEventRepository) Increment(ctx context.Context, userID, eventType, category string) (repository.EventCounts, error) {
+	total := s.total
+	if total == 0 {
+		total = s.getCount
+	}
+	return repository.EventCounts{Category: s.getCount, Total: total}, s.err
 }
 
 func (s *stubUserEventRepository) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
+	s.getCountCalls++
 	return s.getCount, s.err
 }
 
@@ -43,6 +52,7 @@ func TestEvaluateEvent_SingleEventRule(t *testing.T) {
 			Description: "Math Course Completed",
 		},
 		Enabled: true,
+		Version: 3,
 	}
 	engine.SetRules([]models.Rule{rule})
 
@@ -107,6 +117,7 @@ func TestEvaluateEvent_SingleEventRule(t *testing.T) {
 
 			if tt.expectedCount > 0 {
 				assert.Equal(t, rule.ID, triggered[0].RuleID)
+				assert.Equal(t, rule.Version, triggered[0].RuleVersion)
 				assert.Equal(t, tt.event.UserID, triggered[0].UserID)
 				assert.Equal(t, rule.Reward, triggered[0].Reward)
 			}
@@ -204,6 +215,40 @@ func TestEvaluateEvent_MilestoneRule(t *testing.T) {
 	}
 }
 
+func TestEvaluateEvent_UsesIncrementedCounts(t *testing.T) {
+	logger := zap.NewNop()
+
+	mathRule := models.Rule{
+		ID:                 "math-3",
+		EventType:          "COURSE_COMPLETED",
+		Count:              3,
+		ConditionsCategory: ptrString("MATH"),
+		Enabled:            true,
+	}
+	anyRule := models.Rule{
+		ID:        "any-5",
+		EventType: "COURSE_COMPLETED",
+		Count:     5,
+		Enabled:   true,
+	}
+
+	// 3 MATH courses out of 5 courses overall
+	stubRepo := &stubUserEventRepository{getCount: 3, total: 5}
+	engine := rules.NewEngine([]models.Rule{mathRule, anyRule}, stubRepo, logger)
+
+	triggered, err := engine.EvaluateEvent(context.Background(), models.UserEvent{
+		UserID:    "user-001",
+		EventType: "COURSE_COMPLETED",
+		Category:  "MATH",
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, triggered, 2) {
+		assert.Equal(t, "math-3", triggered[0].RuleID)
+		assert.Equal(t, "any-5", triggered[1].RuleID)
+	}
+	assert.Zero(t, stubRepo.getCountCalls, "rules must not query counts again")
+}
+
 func TestEvaluateEvent_DisabledRule(t *testing.T) {
 	logger, err := zap.NewDevelopment()
 	assert.NoError(t, err)
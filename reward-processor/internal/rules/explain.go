@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+)
+
+// Conditions reported by Explain. The enabled condition holds when the rule
+// is ACTIVE, the only status the engine evaluates.
+const (
+	ConditionEnabled   = "enabled"
+	ConditionEventType = "eventType"
+	ConditionCategory  = "category"
+	ConditionCount     = "count"
+)
+
+// ConditionCheck is the state of a rule condition for a user
+type ConditionCheck struct {
+	Condition string
+	Expected  string
+	Actual    string
+	Satisfied bool
+}
+
+// Explanation tells why a rule did or did not grant its reward to a user
+type Explanation struct {
+	Rule   models.Rule
+	UserID string
+	// Count is the user's count for the rule: events of its type, in its
+	// category if it has one
+	Count int
+	// EventTypeCount is the user's count of events of the rule's type, in
+	// every category
+	EventTypeCount int
+	Conditions     []ConditionCheck
+	// TriggeredAt is when the rule granted its reward, nil if it did not
+	TriggeredAt *time.Time
+	// Missing describes what keeps the rule from triggering; it is empty
+	// once it triggered
+	Missing []string
+}
+
+// Triggered reports whether the rule granted its reward to the user
+func (x Explanation) Triggered() bool {
+	return x.TriggeredAt != nil
+}
+
+// Explain evaluates rule against the current counts of a user. grantedAt is
+// when the ledger recorded the rule's reward for the user, nil if it did not.
+func (e *Engine) Explain(ctx context.Context, rule models.Rule, userID string, grantedAt *time.Time) (Explanation, error) {
+	var category string
+	if rule.ConditionsCategory != nil {
+		category = *rule.ConditionsCategory
+	}
+
+	total, err := e.GetMilestoneCount(ctx, userID, rule.EventType, "")
+	if err != nil {
+		return Explanation{}, fmt.Errorf("failed to get count of %s events: %w", rule.EventType, err)
+	}
+	count := total
+	if category != "" {
+		if count, err = e.GetMilestoneCount(ctx, userID, rule.EventType, category); err != nil {
+			return Explanation{}, fmt.Errorf("failed to get count of %s events in category %s: %w", rule.EventType, category, err)
+		}
+	}
+
+	x := Explanation{
+		Rule:           rule,
+		UserID:         userID,
+		Count:          count,
+		EventTypeCount: total,
+		TriggeredAt:    grantedAt,
+		Conditions: []ConditionCheck{
+			{
+				Condition: ConditionEnabled,
+				Expected:  string(models.RuleActive),
+				Actual:    string(rule.Status),
+				Satisfied: rule.Status == models.RuleActive,
+			},
+			{
+				Condition: ConditionEventType,
+				Expected:  rule.EventType,
+				Actual:    fmt.Sprintf("%d %s events", total, rule.EventType),
+				Satisfied: total > 0,
+			},
+		},
+	}
+	if category != "" {
+		// The category holds once the user's stored counts have an event
+		// of the rule's type in it
+		x.Conditions = append(x.Conditions, ConditionCheck{
+			Condition: ConditionCategory,
+			Expected:  category,
+			Actual:    fmt.Sprintf("%d of %d events in category %s", count, total, category),
+			Satisfied: count > 0,
+		})
+	}
+	x.Conditions = append(x.Conditions, ConditionCheck{
+		Condition: ConditionCount,
+		Expected:  strconv.Itoa(rule.Count),
+		Actual:    strconv.Itoa(count),
+		Satisfied: count >= rule.Count,
+	})
+
+	if x.Triggered() {
+		return x, nil
+	}
+	if rule.Status != models.RuleActive {
+		x.Missing = append(x.Missing, fmt.Sprintf("the rule is %s, not %s", rule.Status, models.RuleActive))
+	}
+	switch {
+	case count < rule.Count && category != "":
+		x.Missing = append(x.Missing, fmt.Sprintf("%d more %s events in category %s", rule.Count-count, rule.EventType, category))
+	case count < rule.Count:
+		x.Missing = append(x.Missing, fmt.Sprintf("%d more %s events", rule.Count-count, rule.EventType))
+	default:
+		// Rules trigger on the event that brings the count to their
+		// threshold, not on later ones
+		x.Missing = append(x.Missing, fmt.Sprintf(
+			"the count reached %d without triggering the rule, which was probably created, changed or enabled afterwards",
+			rule.Count))
+	}
+	return x, nil
+}
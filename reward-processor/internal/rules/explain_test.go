@@ -0,0 +1,101 @@
+package rules_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// categoryCounts returns the count of each category, and their sum without
+// a category
+type categoryCounts map[string]int
+
+func (c categoryCounts) Increment(ctx context.Context, userID, eventType, category string) (repository.EventCounts, error) {
+	return repository.EventCounts{}, nil
+}
+
+func (c categoryCounts) GetCount(ctx context.Context, userID, eventType, category string) (int, error) {
+	if category != "" {
+		return c[category], nil
+	}
+	total := 0
+	for _, count := range c {
+		total += count
+	}
+	return total, nil
+}
+
+func TestExplain(t *testing.T) {
+	engine := rules.NewEngine(nil, categoryCounts{"MATH": 2, "ART": 4}, zap.NewNop())
+	rule := models.Rule{
+		ID:                 "rule-001",
+		EventType:          "COURSE_COMPLETED",
+		Count:              5,
+		ConditionsCategory: ptrString("MATH"),
+		Status:             models.RuleActive,
+		Enabled:            true,
+	}
+
+	t.Run("missing events", func(t *testing.T) {
+		x, err := engine.Explain(context.Background(), rule, "user-001", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, x.Count)
+		assert.Equal(t, 6, x.EventTypeCount)
+		assert.False(t, x.Triggered())
+		assert.Equal(t, []rules.ConditionCheck{
+			{Condition: rules.ConditionEnabled, Expected: "ACTIVE", Actual: "ACTIVE", Satisfied: true},
+			{Condition: rules.ConditionEventType, Expected: "COURSE_COMPLETED", Actual: "6 COURSE_COMPLETED events", Satisfied: true},
+			{Condition: rules.ConditionCategory, Expected: "MATH", Actual: "2 of 6 events in category MATH", Satisfied: true},
+			{Condition: rules.ConditionCount, Expected: "5", Actual: "2", Satisfied: false},
+		}, x.Conditions)
+		assert.Equal(t, []string{"3 more COURSE_COMPLETED events in category MATH"}, x.Missing)
+	})
+
+	t.Run("events in another category", func(t *testing.T) {
+		artOnly := rules.NewEngine(nil, categoryCounts{"ART": 4}, zap.NewNop())
+		x, err := artOnly.Explain(context.Background(), rule, "user-001", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, x.Count)
+		assert.Equal(t, 4, x.EventTypeCount)
+		assert.Equal(t, rules.ConditionCheck{Condition: rules.ConditionEventType, Expected: "COURSE_COMPLETED", Actual: "4 COURSE_COMPLETED events", Satisfied: true}, x.Conditions[1])
+		assert.Equal(t, rules.ConditionCheck{Condition: rules.ConditionCategory, Expected: "MATH", Actual: "0 of 4 events in category MATH"}, x.Conditions[2])
+		assert.Equal(t, []string{"5 more COURSE_COMPLETED events in category MATH"}, x.Missing)
+	})
+
+	t.Run("rule pending review past its threshold", func(t *testing.T) {
+		disabled := rule
+		disabled.Status = models.RulePendingReview
+		disabled.Enabled = false
+		disabled.ConditionsCategory = nil
+
+		x, err := engine.Explain(context.Background(), disabled, "user-001", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 6, x.Count)
+		assert.Equal(t, rules.ConditionCheck{Condition: rules.ConditionEnabled, Expected: "ACTIVE", Actual: "PENDING_REVIEW"}, x.Conditions[0])
+		assert.Len(t, x.Conditions, 3)
+		assert.Len(t, x.Missing, 2)
+		assert.Equal(t, "the rule is PENDING_REVIEW, not ACTIVE", x.Missing[0])
+		assert.Contains(t, x.Missing[1], "reached 5 without triggering")
+	})
+
+	t.Run("triggered", func(t *testing.T) {
+		grantedAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+
+		x, err := engine.Explain(context.Background(), rule, "user-001", &grantedAt)
+		require.NoError(t, err)
+
+		assert.True(t, x.Triggered())
+		assert.Equal(t, &grantedAt, x.TriggeredAt)
+		assert.Empty(t, x.Missing)
+	})
+}
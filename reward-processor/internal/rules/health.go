@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
+)
+
+// Check reports the rules loaded in engine. It fails when the last load
+// failed, or when no ACTIVE rule is loaded and no event can grant a reward.
+func Check(engine *Engine) health.Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		status := engine.Status()
+		details := map[string]any{
+			"loaded":    status.Rules,
+			"active":    status.Active,
+			"loaded_at": status.LoadedAt,
+		}
+		if status.Err != nil {
+			details["failed_at"] = status.FailedAt
+			return details, fmt.Errorf("failed to reload rules: %w", status.Err)
+		}
+		if status.Active == 0 {
+			return details, errors.New("no ACTIVE rule loaded")
+		}
+		return details, nil
+	}
+}
+
+// RuleCounter counts the rules of a store
+type RuleCounter interface {
+	// CountRules returns the number of rules, and of ACTIVE rules
+	CountRules(ctx context.Context) (total, active int64, err error)
+}
+
+// StoreCheck reports the rules of a store. It fails when they cannot be
+// counted, or when none is ACTIVE.
+func StoreCheck(store RuleCounter) health.Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		total, active, err := store.CountRules(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count rules: %w", err)
+		}
+		details := map[string]any{"total": total, "enabled": active}
+		if active == 0 {
+			return details, errors.New("no ACTIVE rule")
+		}
+		return details, nil
+	}
+}
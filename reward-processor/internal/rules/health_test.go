@@ -0,0 +1,77 @@
+package rules_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/rules"
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheck(t *testing.T) {
+	active := models.Rule{ID: "rule-001", Status: models.RuleActive, Enabled: true}
+	draft := models.Rule{ID: "rule-002", Status: models.RuleDraft}
+	load := func(rules ...models.Rule) func(context.Context) ([]models.Rule, error) {
+		return func(context.Context) ([]models.Rule, error) { return rules, nil }
+	}
+
+	t.Run("active rules loaded", func(t *testing.T) {
+		engine := rules.NewEngine([]models.Rule{active, draft}, &stubUserEventRepository{}, zap.NewNop())
+
+		details, err := rules.Check(engine)(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, details["loaded"])
+		assert.Equal(t, 1, details["active"])
+	})
+
+	t.Run("no active rule", func(t *testing.T) {
+		engine := rules.NewEngine([]models.Rule{draft}, &stubUserEventRepository{}, zap.NewNop())
+
+		details, err := rules.Check(engine)(context.Background())
+		assert.EqualError(t, err, "no ACTIVE rule loaded")
+		assert.Equal(t, 0, details["active"])
+	})
+
+	t.Run("failed reload", func(t *testing.T) {
+		engine := rules.NewEngine([]models.Rule{active}, &stubUserEventRepository{}, zap.NewNop())
+		failure := errors.New("connection refused")
+
+		err := engine.Reload(context.Background(), func(context.Context) ([]models.Rule, error) { return nil, failure })
+		require.ErrorIs(t, err, failure)
+
+		details, err := rules.Check(engine)(context.Background())
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, 1, details["active"], "the previous rules are kept")
+		assert.Contains(t, details, "failed_at")
+
+		require.NoError(t, engine.Reload(context.Background(), load(active, draft)))
+		_, err = rules.Check(engine)(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, engine.Status().Rules)
+	})
+}
+
+type ruleCounts struct {
+	total, active int64
+	err           error
+}
+
+func (c ruleCounts) CountRules(ctx context.Context) (int64, int64, error) {
+	return c.total, c.active, c.err
+}
+
+func TestStoreCheck(t *testing.T) {
+	details, err := rules.StoreCheck(ruleCounts{total: 3, active: 2})(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"total": int64(3), "enabled": int64(2)}, details)
+
+	_, err = rules.StoreCheck(ruleCounts{total: 3})(context.Background())
+	assert.EqualError(t, err, "no ACTIVE rule")
+
+	_, err = rules.StoreCheck(ruleCounts{err: errors.New("connection refused")})(context.Background())
+	assert.EqualError(t, err, "failed to count rules: connection refused")
+}
@@ -0,0 +1,229 @@
+// Package rulesfile reads and writes rules files, the declarative form of the
+// rules kept in git and applied to the database:
+//
+//	rules:
+//	  - id: rule-001
+//	    eventType: COURSE_COMPLETED
+//	    count: 5
+//	    conditions:
+//	      category: MATH
+//	    reward:
+//	      type: POINTS
+//	      amount: 100
+//	      description: Completed 5 math courses
+//	    enabled: true
+package rulesfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a rules file
+type Format string
+
+const (
+	YAML Format = "yaml"
+	JSON Format = "json"
+)
+
+// ParseFormat parses a format name, case-insensitively
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case YAML, JSON:
+		return f, nil
+	case "yml":
+		return YAML, nil
+	default:
+		return "", fmt.Errorf("unknown rules file format %q, must be yaml or json", s)
+	}
+}
+
+// FormatOf returns the format of a file from its extension, JSON for .json and
+// YAML otherwise
+func FormatOf(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return JSON
+	}
+	return YAML
+}
+
+// File is the content of a rules file
+type File struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is a rule of a rules file. Its ID is stable: applying the file again
+// updates the rule rather than creating another one.
+type Rule struct {
+	ID         string      `json:"id" yaml:"id"`
+	EventType  string      `json:"eventType" yaml:"eventType"`
+	Count      int         `json:"count,omitempty" yaml:"count,omitempty"`
+	Conditions *Conditions `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Reward     Reward      `json:"reward" yaml:"reward"`
+	// Enabled publishes the rule once it was approved through the review
+	// mutations; it never skips review. A rule left disabled keeps its review
+	// status, unless it was active, in which case it goes back to draft.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
+
+// Conditions are the conditions of a rule
+type Conditions struct {
+	Category *string `json:"category,omitempty" yaml:"category,omitempty"`
+}
+
+// Reward is the reward of a rule
+type Reward struct {
+	Type        models.RewardType `json:"type" yaml:"type"`
+	Amount      int               `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Description string            `json:"description" yaml:"description"`
+}
+
+// Parse decodes a rules file. Unknown fields are errors, so that a typo does
+// not silently drop a setting.
+func Parse(data []byte, format Format) (File, error) {
+	var file File
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return File{}, fmt.Errorf("invalid JSON rules file: %w", err)
+		}
+	case YAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return File{}, fmt.Errorf("invalid YAML rules file: %w", err)
+		}
+	default:
+		return File{}, fmt.Errorf("unknown rules file format %q", format)
+	}
+	return file, nil
+}
+
+// Marshal encodes a rules file
+func Marshal(file File, format Format) ([]byte, error) {
+	switch format {
+	case JSON:
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case YAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(file); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown rules file format %q", format)
+	}
+}
+
+// Validate returns every problem of the file, or nil if it can be applied
+func (f File) Validate() error {
+	var errs []error
+	seen := make(map[string]int, len(f.Rules))
+	for i, rule := range f.Rules {
+		invalid := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("rules[%d] (%s): %s", i, rule.ID, fmt.Sprintf(format, args...)))
+		}
+
+		if strings.TrimSpace(rule.ID) == "" {
+			invalid("id is required")
+		} else if first, ok := seen[rule.ID]; ok {
+			invalid("id is already used by rules[%d]", first)
+		} else {
+			seen[rule.ID] = i
+		}
+		if strings.TrimSpace(rule.EventType) == "" {
+			invalid("eventType is required")
+		}
+		if rule.Count < 0 {
+			invalid("count must be at least 1")
+		}
+		if rule.Conditions != nil && rule.Conditions.Category != nil && strings.TrimSpace(*rule.Conditions.Category) == "" {
+			invalid("conditions.category cannot be empty; leave it out to match every category")
+		}
+		switch rule.Reward.Type {
+		case models.BadgeReward, models.PointsReward:
+		default:
+			invalid("reward.type must be %s or %s", models.BadgeReward, models.PointsReward)
+		}
+		if rule.Reward.Amount < 0 {
+			invalid("reward.amount cannot be negative")
+		}
+		if strings.TrimSpace(rule.Reward.Description) == "" {
+			invalid("reward.description is required")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Models returns the rules of the file as they are stored. A count left out
+// is 1.
+func (f File) Models() []models.Rule {
+	rules := make([]models.Rule, len(f.Rules))
+	for i, r := range f.Rules {
+		count := r.Count
+		if count == 0 {
+			count = 1
+		}
+		var category *string
+		if r.Conditions != nil {
+			category = r.Conditions.Category
+		}
+		rules[i] = models.Rule{
+			ID:                 r.ID,
+			EventType:          r.EventType,
+			Count:              count,
+			ConditionsCategory: category,
+			Reward: models.Reward{
+				Type:        r.Reward.Type,
+				Amount:      r.Reward.Amount,
+				Description: r.Reward.Description,
+			},
+			Enabled: r.Enabled,
+		}
+	}
+	return rules
+}
+
+// FromModels returns the rules file of rules
+func FromModels(rules []models.Rule) File {
+	file := File{Rules: make([]Rule, len(rules))}
+	for i, r := range rules {
+		var conditions *Conditions
+		if r.ConditionsCategory != nil {
+			conditions = &Conditions{Category: r.ConditionsCategory}
+		}
+		file.Rules[i] = Rule{
+			ID:         r.ID,
+			EventType:  r.EventType,
+			Count:      r.Count,
+			Conditions: conditions,
+			Reward: Reward{
+				Type:        r.Reward.Type,
+				Amount:      r.Reward.Amount,
+				Description: r.Reward.Description,
+			},
+			Enabled: r.Status == models.RuleActive,
+		}
+	}
+	return file
+}
@@ -0,0 +1,135 @@
+package rulesfile
+
+import (
+	"testing"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	category := "MATH"
+	rules := []models.Rule{
+		{
+			ID:                 "rule-001",
+			EventType:          "COURSE_COMPLETED",
+			Count:              5,
+			ConditionsCategory: &category,
+			Reward:             models.Reward{Type: models.PointsReward, Amount: 100, Description: "Completed 5 math courses"},
+			Status:             models.RuleActive,
+		},
+		{
+			ID:        "rule-002",
+			EventType: "CHAPTER_COMPLETED",
+			Count:     1,
+			Reward:    models.Reward{Type: models.BadgeReward, Description: "First chapter"},
+			Status:    models.RulePendingReview,
+		},
+	}
+
+	for _, format := range []Format{YAML, JSON} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := Marshal(FromModels(rules), format)
+			require.NoError(t, err)
+
+			file, err := Parse(data, format)
+			require.NoError(t, err)
+			require.NoError(t, file.Validate())
+
+			parsed := file.Models()
+			require.Len(t, parsed, 2)
+			assert.Equal(t, "rule-001", parsed[0].ID)
+			assert.Equal(t, &category, parsed[0].ConditionsCategory)
+			assert.Equal(t, rules[0].Reward, parsed[0].Reward)
+			assert.True(t, parsed[0].Enabled)
+			assert.Nil(t, parsed[1].ConditionsCategory)
+			assert.False(t, parsed[1].Enabled)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		err    string
+		count  int
+	}{
+		{name: "count defaults to 1", data: "rules:\n  - id: r\n    eventType: E\n    reward: {type: BADGE, description: d}\n", format: YAML, count: 1},
+		{name: "empty file", data: "", format: YAML},
+		{name: "unknown YAML field", data: "rules:\n  - id: r\n    enabld: true\n", format: YAML, err: "field enabld not found"},
+		{name: "unknown JSON field", data: `{"rules": [{"id": "r", "enabld": true}]}`, format: JSON, err: `unknown field "enabld"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse([]byte(tt.data), tt.format)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.count > 0 {
+				assert.Equal(t, tt.count, file.Models()[0].Count)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Rule{
+		ID:        "rule-001",
+		EventType: "COURSE_COMPLETED",
+		Reward:    Reward{Type: models.BadgeReward, Description: "Badge"},
+	}
+	empty := " "
+
+	tests := []struct {
+		name   string
+		change func(*Rule)
+		err    string
+	}{
+		{name: "valid", change: func(*Rule) {}},
+		{name: "missing id", change: func(r *Rule) { r.ID = "" }, err: "rules[1] (): id is required"},
+		{name: "duplicate id", change: func(*Rule) {}, err: "rules[1] (rule-001): id is already used by rules[0]"},
+		{name: "missing event type", change: func(r *Rule) { r.EventType = "" }, err: "rules[1] (rule-002): eventType is required"},
+		{name: "negative count", change: func(r *Rule) { r.Count = -1 }, err: "rules[1] (rule-002): count must be at least 1"},
+		{name: "empty category", change: func(r *Rule) { r.Conditions = &Conditions{Category: &empty} }, err: "conditions.category cannot be empty"},
+		{name: "unknown reward type", change: func(r *Rule) { r.Reward.Type = "COINS" }, err: "reward.type must be BADGE or POINTS"},
+		{name: "negative amount", change: func(r *Rule) { r.Reward.Amount = -5 }, err: "reward.amount cannot be negative"},
+		{name: "missing description", change: func(r *Rule) { r.Reward.Description = "" }, err: "reward.description is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := valid
+			if tt.name != "duplicate id" {
+				second.ID = "rule-002"
+			}
+			tt.change(&second)
+
+			err := File{Rules: []Rule{valid, second}}.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for input, expected := range map[string]Format{"yaml": YAML, "YML": YAML, "json": JSON} {
+		format, err := ParseFormat(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+	_, err := ParseFormat("toml")
+	assert.Error(t, err)
+
+	assert.Equal(t, JSON, FormatOf("rules.JSON"))
+	assert.Equal(t, YAML, FormatOf("rules.yml"))
+	assert.Equal(t, YAML, FormatOf(""))
+}
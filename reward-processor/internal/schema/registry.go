@@ -0,0 +1,186 @@
+// Package schema is an embedded, read-only schema registry for the Avro
+// contracts exchanged over Kafka. Schemas are defined once in the repository's
+// top-level schemas directory and copied into registry/ by schemas/generate.sh.
+package schema
+
+import (
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+// Subjects follow the <topic>-value naming convention
+const (
+	LearningEventsSubject = "learning-events-value"
+	UserRewardsSubject    = "user-rewards-value"
+)
+
+//go:embed registry
+var embedded embed.FS
+
+// ErrUnknownSchema is returned when a payload references a schema that is not
+// registered
+var ErrUnknownSchema = errors.New("unknown schema fingerprint")
+
+// Version is a registered version of a subject's schema
+type Version struct {
+	Subject string
+	Version int
+	Schema  avro.Schema
+	// Fingerprint is the CRC-64-AVRO fingerprint of the canonical schema, as
+	// used by the Avro single object encoding
+	Fingerprint uint64
+}
+
+// Registry holds every version of every subject. Versions of a subject must
+// be backward compatible with all previous versions, so consumers using the
+// latest schema can read anything that was ever produced.
+type Registry struct {
+	subjects      map[string][]*Version
+	byFingerprint map[uint64]*Version
+
+	mu       sync.Mutex
+	resolved map[[2]uint64]avro.Schema
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+	defaultErr      error
+)
+
+// Default returns the registry built from the embedded schemas
+func Default() (*Registry, error) {
+	defaultOnce.Do(func() {
+		sub, err := fs.Sub(embedded, "registry")
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultRegistry, defaultErr = Load(sub)
+	})
+	return defaultRegistry, defaultErr
+}
+
+// Load reads a registry laid out as <subject>/v<version>.avsc and checks that
+// every subject's versions are backward compatible
+func Load(fsys fs.FS) (*Registry, error) {
+	r := &Registry{
+		subjects:      make(map[string][]*Version),
+		byFingerprint: make(map[uint64]*Version),
+		resolved:      make(map[[2]uint64]avro.Schema),
+	}
+
+	files, err := fs.Glob(fsys, "*/v*.avsc")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("schema registry is empty")
+	}
+
+	for _, file := range files {
+		subject := path.Dir(file)
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path.Base(file), "v"), ".avsc"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema file name %s: %w", file, err)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		// Use a dedicated cache so versions of the same named type don't clash
+		parsed, err := avro.ParseBytesWithCache(data, "", &avro.SchemaCache{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", file, err)
+		}
+		fingerprint, err := parsed.FingerprintUsing(avro.CRC64AvroLE)
+		if err != nil {
+			return nil, err
+		}
+
+		v := &Version{
+			Subject:     subject,
+			Version:     version,
+			Schema:      parsed,
+			Fingerprint: binary.LittleEndian.Uint64(fingerprint),
+		}
+		r.subjects[subject] = append(r.subjects[subject], v)
+		r.byFingerprint[v.Fingerprint] = v
+	}
+
+	for subject, versions := range r.subjects {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+		for i := 1; i < len(versions); i++ {
+			if err := r.CheckCompatibility(subject, versions[i].Schema, versions[:i]...); err != nil {
+				return nil, fmt.Errorf("%s v%d: %w", subject, versions[i].Version, err)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// CheckCompatibility checks that a candidate schema can read data written with
+// the given versions (all versions of the subject when none are given), i.e.
+// that it is backward transitive compatible.
+func (r *Registry) CheckCompatibility(subject string, candidate avro.Schema, against ...*Version) error {
+	if len(against) == 0 {
+		against = r.subjects[subject]
+	}
+
+	compat := avro.NewSchemaCompatibility()
+	for _, v := range against {
+		if err := compat.Compatible(candidate, v.Schema); err != nil {
+			return fmt.Errorf("incompatible with %s v%d: %w", v.Subject, v.Version, err)
+		}
+	}
+	return nil
+}
+
+// Latest returns the latest version of a subject
+func (r *Registry) Latest(subject string) (*Version, error) {
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown subject %s", subject)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Lookup returns the version with the given fingerprint
+func (r *Registry) Lookup(fingerprint uint64) (*Version, bool) {
+	v, ok := r.byFingerprint[fingerprint]
+	return v, ok
+}
+
+// resolve returns a schema that decodes data written with writer into values
+// of reader, caching the result
+func (r *Registry) resolve(reader, writer *Version) (avro.Schema, error) {
+	if reader.Fingerprint == writer.Fingerprint {
+		return reader.Schema, nil
+	}
+
+	key := [2]uint64{reader.Fingerprint, writer.Fingerprint}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.resolved[key]; ok {
+		return s, nil
+	}
+
+	s, err := avro.NewSchemaCompatibility().Resolve(reader.Schema, writer.Schema)
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[key] = s
+	return s, nil
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/contracts"
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pointV1 = `{"type":"record","name":"Point","fields":[{"name":"x","type":"int"}]}`
+
+func TestDefault(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+
+	for _, subject := range []string{LearningEventsSubject, UserRewardsSubject} {
+		latest, err := r.Latest(subject)
+		require.NoError(t, err, subject)
+		assert.Equal(t, subject, latest.Subject)
+
+		found, ok := r.Lookup(latest.Fingerprint)
+		require.True(t, ok)
+		assert.Same(t, latest, found)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+
+	event := contracts.LearningEvent{
+		ID:        "3f1c2a9e-6a4b-4c6e-9d1a-0b7f4f1e2d3c",
+		UserID:    "user-001",
+		EventType: "COURSE_COMPLETED",
+		Category:  "MATH",
+		CourseID:  "course-123",
+		Timestamp: time.Date(2025, 6, 3, 14, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2025, 6, 3, 14, 0, 1, 0, time.UTC),
+		ClientID:  "lms",
+	}
+
+	data, version, err := r.Marshal(LearningEventsSubject, event)
+	require.NoError(t, err)
+	assert.True(t, IsSingleObject(data))
+	latest, err := r.Latest(LearningEventsSubject)
+	require.NoError(t, err)
+	assert.Same(t, latest, version)
+	assert.Equal(t, fmt.Sprintf("urn:learning-rewards:schema:learning-events-value:%d", latest.Version), version.DataSchema())
+
+	var decoded contracts.LearningEvent
+	require.NoError(t, r.Unmarshal(LearningEventsSubject, data, &decoded))
+	assert.Equal(t, event, decoded)
+
+	err = r.Unmarshal(UserRewardsSubject, data, &decoded)
+	assert.Error(t, err, "payload of another subject must be rejected")
+}
+
+func TestUnmarshal_ResolvesOlderVersions(t *testing.T) {
+	r, err := Load(fstest.MapFS{
+		"point-value/v1.avsc": {Data: []byte(pointV1)},
+		"point-value/v2.avsc": {Data: []byte(`{"type":"record","name":"Point","fields":[
+			{"name":"x","type":"int"},
+			{"name":"label","type":"string","default":"origin"}
+		]}`)},
+	})
+	require.NoError(t, err)
+
+	// Encode with v1 the way an outdated producer would
+	latest, err := r.Latest("point-value")
+	require.NoError(t, err)
+	require.Equal(t, 2, latest.Version)
+	old := r.subjects["point-value"][0]
+	body, err := avro.Marshal(old.Schema, map[string]any{"x": 7})
+	require.NoError(t, err)
+	data := append([]byte{0xC3, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}, body...)
+	binary.LittleEndian.PutUint64(data[2:], old.Fingerprint)
+
+	var point struct {
+		X     int    `avro:"x"`
+		Label string `avro:"label"`
+	}
+	require.NoError(t, r.Unmarshal("point-value", data, &point))
+	assert.Equal(t, 7, point.X)
+	assert.Equal(t, "origin", point.Label)
+}
+
+func TestLoad_RejectsIncompatibleVersions(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"point-value/v1.avsc": {Data: []byte(pointV1)},
+		// A new required field without a default cannot read v1 data
+		"point-value/v2.avsc": {Data: []byte(`{"type":"record","name":"Point","fields":[
+			{"name":"x","type":"int"},
+			{"name":"y","type":"int"}
+		]}`)},
+	})
+	assert.ErrorContains(t, err, "point-value v2")
+}
+
+func TestUnmarshal_UnknownFingerprint(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+
+	data := []byte{0xC3, 0x01, 1, 2, 3, 4, 5, 6, 7, 8, 0}
+	var event contracts.LearningEvent
+	assert.ErrorIs(t, r.Unmarshal(LearningEventsSubject, data, &event), ErrUnknownSchema)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = ParseFormat("protobuf")
+	assert.Error(t, err)
+}
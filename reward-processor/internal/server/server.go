@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"time"
@@ -10,9 +11,13 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/alexandredsa/learning-rewards/observability/gqlext"
+	"github.com/alexandredsa/learning-rewards/observability/metrics"
+	"github.com/alexandredsa/learning-rewards/observability/tracing"
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/generated"
 	"github.com/alexandredsa/learning-rewards/reward-processor/graph/resolver"
-	"github.com/alexandredsa/learning-rewards/reward-processor/internal/repository"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/auth"
+	"github.com/alexandredsa/learning-rewards/reward-processor/internal/health"
 	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/logger"
 	"github.com/gorilla/mux"
 	"github.com/vektah/gqlparser/v2/gqlerror"
@@ -22,6 +27,11 @@ import (
 // Config holds the server configuration
 type Config struct {
 	Port string
+	// Health serves the liveness and readiness probes when set
+	Health *health.Checker
+	// Auth authenticates the requests of the GraphQL endpoint. When nil,
+	// authentication is disabled and every request is an admin.
+	Auth *auth.Authenticator
 }
 
 // Server represents the HTTP server
@@ -39,14 +49,12 @@ func New(cfg Config) *Server {
 	}
 }
 
-// Start starts the HTTP server
-func (s *Server) Start(db *repository.GormRuleRepository) error {
-	// Create resolver
-	resolver := resolver.NewResolver(db, s.log)
-
+// Start starts the HTTP server, serving the GraphQL API with res
+func (s *Server) Start(res *resolver.Resolver) error {
 	// Create GraphQL server
 	srv := handler.New(generated.NewExecutableSchema(generated.Config{
-		Resolvers: resolver,
+		Resolvers:  res,
+		Directives: resolver.Directives(),
 	}))
 
 	// Configure server with HTTP transport
@@ -57,11 +65,21 @@ func (s *Server) Start(db *repository.GormRuleRepository) error {
 
 	// Enable introspection
 	srv.Use(extension.Introspection{})
+	srv.Use(gqlext.Metrics{})
+	srv.Use(gqlext.Tracing{})
 
 	// Set custom error presenter
 	srv.SetErrorPresenter(func(ctx context.Context, err error) *gqlerror.Error {
 		s.log.Error("GraphQL error", zap.Error(err))
-		return gqlerror.Errorf("%s", err.Error())
+		gqlErr := gqlerror.Errorf("%s", err.Error())
+		var forbidden *auth.ForbiddenError
+		switch {
+		case errors.As(err, &forbidden):
+			gqlErr.Extensions = map[string]any{"code": "FORBIDDEN"}
+		case errors.Is(err, auth.ErrUnauthenticated):
+			gqlErr.Extensions = map[string]any{"code": "UNAUTHENTICATED"}
+		}
+		return gqlErr
 	})
 
 	// Create router
@@ -97,6 +115,8 @@ func (s *Server) Start(db *repository.GormRuleRepository) error {
 	// Apply middleware
 	router.Use(loggingMiddleware)
 	router.Use(recoveryMiddleware)
+	router.Use(metrics.Middleware)
+	router.Use(tracing.Middleware)
 
 	// GraphQL playground for development
 	if os.Getenv("ENV") != "production" {
@@ -104,7 +124,21 @@ func (s *Server) Start(db *repository.GormRuleRepository) error {
 	}
 
 	// GraphQL endpoint
-	router.Handle("/query", srv)
+	authenticate := auth.AllowAll
+	if s.config.Auth != nil {
+		authenticate = s.config.Auth.Middleware
+	} else {
+		s.log.Warn("Authentication is disabled, every request is an admin")
+	}
+	router.Handle("/query", authenticate(srv))
+
+	// Prometheus metrics
+	router.Handle("/metrics", metrics.Handler())
+
+	// Liveness and readiness probes
+	if s.config.Health != nil {
+		s.config.Health.Register(router)
+	}
 
 	// Create HTTP server
 	s.server = &http.Server{
@@ -0,0 +1,42 @@
+// Code generated by avro/gen. DO NOT EDIT.
+package contracts
+
+import (
+	"time"
+)
+
+// A learner activity published by event-processor to the learning-events topic.
+type LearningEvent struct {
+	// Unique event ID assigned by event-processor.
+	ID     string `avro:"id" json:"id"`
+	UserID string `avro:"user_id" json:"user_id"`
+	// Event type such as COURSE_COMPLETED.
+	EventType string `avro:"event_type" json:"event_type"`
+	Category  string `avro:"category" json:"category"`
+	CourseID  string `avro:"course_id" json:"course_id"`
+	// When the activity happened.
+	Timestamp time.Time `avro:"timestamp" json:"timestamp"`
+	// When event-processor accepted the event.
+	CreatedAt time.Time `avro:"created_at" json:"created_at"`
+	// ID of the API client that submitted the event; empty for events ingested without authentication.
+	ClientID string `avro:"client_id" json:"client_id"`
+}
+
+// Reward is a generated struct.
+type Reward struct {
+	Type string `avro:"type" json:"type"`
+	// Only set for POINTS rewards.
+	Amount      int    `avro:"amount" json:"amount"`
+	Description string `avro:"description" json:"description"`
+}
+
+// A reward granted by reward-processor, published to the user-rewards topic.
+type RewardTriggered struct {
+	UserID string `avro:"user_id" json:"user_id"`
+	// ID of the rule that fired.
+	RuleID    string    `avro:"rule_id" json:"rule_id"`
+	Reward    Reward    `avro:"reward" json:"reward"`
+	Timestamp time.Time `avro:"timestamp" json:"timestamp"`
+	// Version of the rule that fired; 0 for rewards granted before rules were versioned.
+	RuleVersion int `avro:"rule_version" json:"rule_version"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"github.com/alexandredsa/learning-rewards/reward-processor/pkg/contracts"
 )
 
 // RewardType represents the type of reward
@@ -19,7 +21,129 @@ type Rule struct {
 	Count              int     `json:"count,omitempty"`
 	ConditionsCategory *string `json:"conditions_category" gorm:"column:conditions_category"`
 	Reward             Reward  `json:"reward" gorm:"embedded"`
-	Enabled            bool    `json:"enabled"`
+	// Status is the stage of the rule in its review lifecycle
+	Status RuleStatus `json:"status" gorm:"not null;default:'DRAFT';index"`
+	// Enabled is true while Status is RuleActive; the engine only evaluates
+	// enabled rules
+	Enabled bool `json:"enabled"`
+	// ArchivedAt is set once the rule is archived. Archived rules are
+	// retired and kept only for history.
+	ArchivedAt *time.Time `json:"archived_at,omitempty" gorm:"index"`
+	// Version is incremented on every change; see RuleVersion
+	Version   int       `json:"version" gorm:"not null;default:1"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+// RuleStatus is the stage of a rule in its review lifecycle: rules are
+// drafted, submitted for review, approved by another user, then published.
+type RuleStatus string
+
+const (
+	RuleDraft         RuleStatus = "DRAFT"
+	RulePendingReview RuleStatus = "PENDING_REVIEW"
+	RuleApproved      RuleStatus = "APPROVED"
+	RuleActive        RuleStatus = "ACTIVE"
+	RuleRetired       RuleStatus = "RETIRED"
+)
+
+// RuleChange is the kind of change that created a rule version
+type RuleChange string
+
+const (
+	RuleCreated   RuleChange = "CREATE"
+	RuleUpdated   RuleChange = "UPDATE"
+	RuleArchived  RuleChange = "ARCHIVE"
+	RuleReverted  RuleChange = "REVERT"
+	RuleDeleted   RuleChange = "DELETE"
+	RuleSubmitted RuleChange = "SUBMIT"
+	RuleApproval  RuleChange = "APPROVE"
+	RuleRejected  RuleChange = "REJECT"
+	RulePublished RuleChange = "PUBLISH"
+)
+
+// ruleTransitions are the status changes of the review lifecycle. Archiving
+// retires a rule from any status.
+var ruleTransitions = map[RuleChange]struct{ from, to RuleStatus }{
+	RuleSubmitted: {RuleDraft, RulePendingReview},
+	RuleApproval:  {RulePendingReview, RuleApproved},
+	RuleRejected:  {RulePendingReview, RuleDraft},
+	RulePublished: {RuleApproved, RuleActive},
+}
+
+// Transition returns the status a change of the review lifecycle moves a
+// rule in status to, or false if the change does not apply to that status
+func (s RuleStatus) Transition(change RuleChange) (RuleStatus, bool) {
+	t, ok := ruleTransitions[change]
+	if !ok || t.from != s {
+		return "", false
+	}
+	return t.to, true
+}
+
+// FieldChange is a field of a rule changed by a version
+type FieldChange struct {
+	Field string `json:"field"`
+	// From and To are nil when the field is unset, e.g. a cleared category
+	From *string `json:"from"`
+	To   *string `json:"to"`
+}
+
+// RuleVersion is a rule as it was after a change, with who made the change
+// and what it changed. Versions are kept after the rule is deleted.
+type RuleVersion struct {
+	ID                 uint64     `json:"id" gorm:"primaryKey"`
+	RuleID             string     `json:"rule_id" gorm:"not null;uniqueIndex:idx_rule_versions_rule_version,priority:1"`
+	Version            int        `json:"version" gorm:"not null;uniqueIndex:idx_rule_versions_rule_version,priority:2"`
+	Change             RuleChange `json:"change" gorm:"not null"`
+	EventType          string     `json:"event_type"`
+	Count              int        `json:"count,omitempty"`
+	ConditionsCategory *string    `json:"conditions_category"`
+	Reward             Reward     `json:"reward" gorm:"embedded"`
+	Status             RuleStatus `json:"status" gorm:"not null;default:'DRAFT'"`
+	Enabled            bool       `json:"enabled"`
+	ArchivedAt         *time.Time `json:"archived_at,omitempty"`
+	// Author is who made the change, as given to the API
+	Author string `json:"author" gorm:"not null"`
+	// Comment is the reviewer's comment of a review change
+	Comment string `json:"comment,omitempty"`
+	// Diff lists the fields changed from the previous version
+	Diff      []FieldChange `json:"diff" gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// NewRuleVersion returns the version of rule created by a change
+func NewRuleVersion(rule Rule, change RuleChange, author string, diff []FieldChange) RuleVersion {
+	return RuleVersion{
+		RuleID:             rule.ID,
+		Version:            rule.Version,
+		Change:             change,
+		EventType:          rule.EventType,
+		Count:              rule.Count,
+		ConditionsCategory: rule.ConditionsCategory,
+		Reward:             rule.Reward,
+		Status:             rule.Status,
+		Enabled:            rule.Enabled,
+		ArchivedAt:         rule.ArchivedAt,
+		Author:             author,
+		Diff:               diff,
+	}
+}
+
+// Rule returns the rule as it was at this version
+func (v RuleVersion) Rule() Rule {
+	return Rule{
+		ID:                 v.RuleID,
+		EventType:          v.EventType,
+		Count:              v.Count,
+		ConditionsCategory: v.ConditionsCategory,
+		Reward:             v.Reward,
+		Status:             v.Status,
+		Enabled:            v.Enabled,
+		ArchivedAt:         v.ArchivedAt,
+		Version:            v.Version,
+		UpdatedAt:          v.CreatedAt,
+	}
 }
 
 // Reward represents a reward definition
@@ -29,28 +153,110 @@ type Reward struct {
 	Description string     `json:"description"`
 }
 
-// UserEvent represents an incoming user event
-type UserEvent struct {
-	UserID    string    `json:"user_id"`
-	EventType string    `json:"event_type"`
-	CourseID  string    `json:"course_id"`
-	Category  string    `json:"category"`
-	Timestamp time.Time `json:"timestamp"`
-}
+// UserEvent represents an incoming user event. It is generated from the
+// learning-events-value schema; see schemas/ at the repository root.
+type UserEvent = contracts.LearningEvent
 
 // RewardTriggered represents a triggered reward event
 type RewardTriggered struct {
-	UserID    string    `json:"user_id"`
-	RuleID    string    `json:"rule_id"`
-	Reward    Reward    `json:"reward"`
-	Timestamp time.Time `json:"timestamp"`
+	UserID string `json:"user_id"`
+	RuleID string `json:"rule_id"`
+	// RuleVersion is the version of the rule that fired
+	RuleVersion int       `json:"rule_version"`
+	Reward      Reward    `json:"reward"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// GrantedReward records a reward sent to a user. Since rules fire when a
+// count reaches their threshold, a rule grants its reward at most once per
+// user.
+type GrantedReward struct {
+	UserID string `json:"user_id" gorm:"primaryKey"`
+	RuleID string `json:"rule_id" gorm:"primaryKey"`
+	// RuleVersion is the version of the rule that granted the reward; 0 for
+	// rewards granted before rules were versioned
+	RuleVersion int       `json:"rule_version" gorm:"not null;default:0"`
+	GrantedAt   time.Time `json:"granted_at"`
+}
+
+// OutboxReward is a triggered reward waiting to be published. It is written
+// in the transaction of the event that triggered it and published by the
+// outbox relay.
+type OutboxReward struct {
+	ID          uint64    `json:"id" gorm:"primaryKey;index:idx_reward_outbox_pending,where:sent_at IS NULL"`
+	UserID      string    `json:"user_id" gorm:"not null"`
+	RuleID      string    `json:"rule_id" gorm:"not null"`
+	RuleVersion int       `json:"rule_version" gorm:"not null;default:0"`
+	Reward      Reward    `json:"reward" gorm:"embedded;embeddedPrefix:reward_"`
+	TriggeredAt time.Time `json:"triggered_at" gorm:"not null"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	// TraceContext links the publish to the trace of the triggering event
+	TraceContext string     `json:"trace_context"`
+	SentAt       *time.Time `json:"sent_at" gorm:"index"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName overrides the table name used by OutboxReward
+func (OutboxReward) TableName() string {
+	return "reward_outbox"
+}
+
+// Triggered returns the reward as it is published
+func (o OutboxReward) Triggered() RewardTriggered {
+	return RewardTriggered{
+		UserID:      o.UserID,
+		RuleID:      o.RuleID,
+		RuleVersion: o.RuleVersion,
+		Reward:      o.Reward,
+		Timestamp:   o.TriggeredAt,
+	}
+}
+
+// ConsumerOffset is the watermark of a partition: every message before
+// NextOffset was processed. Messages are processed concurrently, so some
+// after it may have been processed too; those are in ProcessedMessage.
+type ConsumerOffset struct {
+	GroupID    string    `json:"group_id" gorm:"primaryKey"`
+	Topic      string    `json:"topic" gorm:"primaryKey"`
+	Partition  int32     `json:"partition" gorm:"primaryKey;autoIncrement:false"`
+	NextOffset int64     `json:"next_offset" gorm:"not null"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ProcessedMessage marks a message at or after the watermark of its partition
+// as processed, so that it is skipped when the partition is consumed again
+// from the watermark. Rows below the watermark are deleted.
+type ProcessedMessage struct {
+	GroupID     string    `json:"group_id" gorm:"primaryKey"`
+	Topic       string    `json:"topic" gorm:"primaryKey"`
+	Partition   int32     `json:"partition" gorm:"primaryKey;autoIncrement:false"`
+	Offset      int64     `json:"offset" gorm:"primaryKey;autoIncrement:false"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+// DeadLetterEvent is a consumed event the processor gave up on, after a
+// permanent error or too many failed attempts. Its offset is committed, so it
+// is only handled again if replayed.
+type DeadLetterEvent struct {
+	GroupID   string `json:"group_id" gorm:"primaryKey"`
+	Topic     string `json:"topic" gorm:"primaryKey"`
+	Partition int32  `json:"partition" gorm:"primaryKey;autoIncrement:false"`
+	Offset    int64  `json:"offset" gorm:"primaryKey;autoIncrement:false"`
+	EventID   string `json:"event_id" gorm:"index"`
+	UserID    string `json:"user_id" gorm:"not null"`
+	// Payload is the event encoded as JSON
+	Payload   string    `json:"payload" gorm:"not null"`
+	Error     string    `json:"error" gorm:"not null"`
+	Attempts  int       `json:"attempts" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // UserEventCount represents a user's event count in the database
 type UserEventCount struct {
-	UserID    string    `json:"user_id" db:"user_id"`
-	EventType string    `json:"event_type" db:"event_type"`
-	Category  string    `json:"category" db:"category"`
-	Count     int       `json:"count" db:"count"`
+	UserID    string    `json:"user_id" db:"user_id" gorm:"not null;uniqueIndex:idx_user_event_counts_key,priority:1"`
+	EventType string    `json:"event_type" db:"event_type" gorm:"not null;uniqueIndex:idx_user_event_counts_key,priority:2"`
+	Category  string    `json:"category" db:"category" gorm:"not null;default:'';uniqueIndex:idx_user_event_counts_key,priority:3"`
+	Count     int       `json:"count" db:"count" gorm:"not null"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,48 @@
+#!/bin/sh
+# Generates the Go contracts and embedded schema registries of every service
+# from the Avro schemas in this directory. Run from anywhere:
+#
+#   ./schemas/generate.sh
+#
+# Schemas live in <subject>/v<version>.avsc. Never edit a released version;
+# add a new one instead. Each new version must be backward compatible with all
+# previous versions of its subject, which the services check when loading
+# their registry (and `go test ./internal/schema/...` checks in CI).
+set -eu
+
+root=$(cd "$(dirname "$0")/.." && pwd)
+schemas="$root/schemas"
+
+# latest prints the newest version file of a subject
+latest() {
+	ls "$schemas/$1" | sed -n 's/^v\([0-9]*\)\.avsc$/\1/p' | sort -n | tail -1 | sed "s|.*|$schemas/$1/v&.avsc|"
+}
+
+subjects=$(cd "$schemas" && ls -d */ | tr -d /)
+sources=""
+for subject in $subjects; do
+	sources="$sources $(latest "$subject")"
+done
+
+# generate <module dir> <contracts package dir>
+generate() {
+	module="$root/$1"
+	registry="$module/internal/schema/registry"
+
+	rm -rf "$registry"
+	for subject in $subjects; do
+		mkdir -p "$registry/$subject"
+		cp "$schemas/$subject"/v*.avsc "$registry/$subject/"
+	done
+
+	mkdir -p "$module/$2"
+	(cd "$module" && go run github.com/hamba/avro/v2/cmd/avrogen \
+		-pkg contracts \
+		-tags json:snake \
+		-o "$2/contracts.go" \
+		$sources)
+	echo "generated $1"
+}
+
+generate event-processor internal/contracts
+generate reward-processor pkg/contracts